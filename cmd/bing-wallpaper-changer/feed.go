@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/core"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+)
+
+// feed writes the Atom, RSS and JSON feeds of the wallpapers saved in the download directory.
+func feed(config *core.Config, args ...string) {
+	opts := newFlagSet(config, "feed")
+	output := opts.String("output", "", "the directory to write feed.atom, feed.rss and feed.json into, defaults to the download directory")
+	baseURL := opts.String("base-url", "", "the URL the download directory is published at, the enclosures are linked relative to it (e.g. https://share.example.com/wallpapers/),\nif not provided, the enclosures are linked as file URLs")
+	title := opts.String("title", core.DefaultGalleryTitle, "the title of the feeds")
+	limit := opts.Int("limit", core.DefaultFeedLimit, "the number of most recent wallpapers in the feeds")
+	parseFlags(opts, config, args...)
+
+	if *output == "" {
+		*output = config.DownloadDirectory
+	}
+
+	archive, err := core.LoadArchive(config.DownloadDirectory)
+	if err != nil {
+		logger.Logger.Fatalln(err)
+	}
+
+	if err := os.MkdirAll(*output, os.ModePerm); err != nil {
+		logger.Logger.Fatalln(err)
+	}
+
+	for _, format := range core.AllowedFeedFormats {
+		feedOptions := core.FeedOptions{Title: *title, BaseURL: *baseURL, Limit: *limit}
+		// the feeds are published along with the wallpapers only if written into the download directory
+		if *baseURL != "" && filepath.Clean(*output) == filepath.Clean(config.DownloadDirectory) {
+			feedOptions.SelfURL = strings.TrimSuffix(*baseURL, "/") + "/" + format.FileName()
+		}
+
+		var buffer bytes.Buffer
+		if err := core.WriteFeed(&buffer, archive, config.DownloadDirectory, format, feedOptions); err != nil {
+			logger.Logger.Fatalln(err)
+		}
+
+		path := filepath.Join(*output, format.FileName())
+		if err := os.WriteFile(path, buffer.Bytes(), 0644); err != nil {
+			logger.Logger.Fatalln(err)
+		}
+
+		fmt.Printf("Feed of %d wallpaper(s) written to: %s\n", min(len(archive), *limit), path)
+	}
+}
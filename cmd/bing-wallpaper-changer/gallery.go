@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/core"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+)
+
+// gallery builds a static website of the wallpapers saved in the download directory.
+func gallery(config *core.Config, args ...string) {
+	opts := newFlagSet(config, "gallery")
+	output := opts.String("output", "", "the directory to build the gallery into, defaults to the \"gallery\" subdirectory of the download directory")
+	templates := opts.String("templates", "", "the directory with templates (index.html, day.html, layout.html) and assets (style.css, gallery.js) replacing the embedded ones of the same name")
+	title := opts.String("title", core.DefaultGalleryTitle, "the title of the gallery")
+	parseFlags(opts, config, args...)
+
+	if *output == "" {
+		*output = filepath.Join(config.DownloadDirectory, "gallery")
+	}
+
+	built, err := core.BuildGallery(config.DownloadDirectory, *output, core.GalleryOptions{Title: *title, TemplateDirectory: *templates})
+	if err != nil {
+		logger.Logger.Fatalln(err)
+	}
+
+	fmt.Printf("Gallery of %d wallpaper(s) built in: %s\n", len(built.Wallpapers), filepath.Join(*output, "index.html"))
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/blang/semver"
 	"github.com/creativeprojects/go-selfupdate"
@@ -27,6 +29,22 @@ var Version = "v1.1.9"
 
 func main() {
 	var config core.Config
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "prune":
+			prune(&config, os.Args[2:]...)
+			return
+
+		case "gallery":
+			gallery(&config, os.Args[2:]...)
+			return
+
+		case "feed":
+			feed(&config, os.Args[2:]...)
+			return
+		}
+	}
+
 	checkVersionOrUpdate()
 	parseArgs(&config, os.Args[1:]...)
 	core.Run(execute, &config)
@@ -78,61 +96,32 @@ func checkVersionOrUpdate() {
 }
 
 // execute fetches the wallpaper, processes it, and sets it as the desktop wallpaper.
-func execute(config *core.Config) *core.Image {
+// The steps are recorded in the refresh job, if any.
+func execute(config *core.Config, job *core.Job) *core.Image {
 	img, err := core.DownloadAndDecode(
 		config.Day.Value(), config.Region.Value(), config.Resolution.Value(),
 		core.WithFuriganaApiAppId(config.FuriganaApiAppId),
 		core.WithGoogleAppCredentials(config.GoogleAppCredentials),
 		core.WithUseGoogleText2SpeechService(config.UseGoogleText2SpeechService),
 		core.WithUseGoogleTranslateService(config.UseGoogleTranslateService),
+		core.WithJob(job),
 	)
 	if err != nil {
 		logger.Logger.Println(err)
 		return nil
 	}
 
-	if config.DimImage > 0.0 {
-		if err := img.Dim(config.DimImage); err != nil {
-			logger.Logger.Println(err)
-			return img
-		}
-	}
-
-	if config.Watermark != "" {
-		if err := img.DrawWatermark(config.Watermark, config.RotateCounterClockwise); err != nil {
-			logger.Logger.Println(err)
-			return img
-		}
-	}
-
-	if config.DrawDescription {
-		if err := img.DrawDescription(types.PositionTopCenter, extras.DefaultFontName); err != nil {
-			logger.Logger.Println(err)
-			return img
-		}
-	}
-
-	if config.DrawQRCode {
-		if err := img.DrawQRCode(config.Resolution.Value(), types.PositionTopRight); err != nil {
-			logger.Logger.Println(err)
-			return img
-		}
-	}
-
-	path, err := img.EncodeAndDump(config.DownloadDirectory)
-	if err != nil {
+	if err := job.Step(core.JobStepRender, func() error { return render(img, config) }); err != nil {
 		logger.Logger.Println(err)
 		return img
 	}
 
-	logger.Logger.Printf("Wallpaper saved to: %s", path)
-	if !config.DownloadOnly {
-		if err := core.SetWallpaper(path, config.Mode.Value()); err != nil {
-			logger.Logger.Println(err)
-			return img
-		}
-
-		logger.Logger.Printf("Wallpaper set to: %s", path)
+	if err := job.Step(core.JobStepSet, func() error {
+		_, err := img.Publish(config)
+		return err
+	}); err != nil {
+		logger.Logger.Println(err)
+		return img
 	}
 
 	if img.Audio == nil || !config.AutoPlayAudio {
@@ -149,8 +138,73 @@ func execute(config *core.Config) *core.Image {
 	return img
 }
 
+// render dims the wallpaper and draws the watermarks, the description, the QR code and the overlays.
+func render(img *core.Image, config *core.Config) error {
+	if config.DimImage > 0.0 {
+		if err := img.Dim(config.DimImage); err != nil {
+			return err
+		}
+	}
+
+	if config.Watermark != "" {
+		if err := img.DrawWatermark(config.Watermark, core.WatermarkOptions{
+			Mode:                   config.WatermarkMode.Value(),
+			Position:               config.WatermarkPosition.Value(),
+			Opacity:                config.WatermarkOpacity,
+			Size:                   config.WatermarkSize,
+			Margin:                 config.WatermarkMargin,
+			Rotation:               config.WatermarkRotation,
+			RotateCounterClockwise: config.RotateCounterClockwise,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if config.TextWatermark != "" {
+		if err := img.DrawTextWatermark(config.TextWatermark, core.TextWatermarkOptions{
+			Font:     config.TextWatermarkFont,
+			Size:     config.TextWatermarkSize,
+			Color:    config.TextWatermarkColor,
+			Opacity:  config.TextWatermarkOpacity,
+			Rotation: config.TextWatermarkRotation,
+			Position: config.TextWatermarkPosition.Value(),
+			Margin:   config.WatermarkMargin,
+			Repeat:   config.TextWatermarkRepeat,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if config.DrawDescription {
+		if err := img.DrawDescription(types.PositionTopCenter, extras.DefaultFontName); err != nil {
+			return err
+		}
+	}
+
+	if config.DrawQRCode {
+		if err := img.DrawQRCode(core.QRCodeOptions{
+			Position:   config.QRCodePosition.Value(),
+			Size:       config.QRCodeSize,
+			Level:      config.QRCodeLevel.Value(),
+			Foreground: config.QRCodeForeground,
+			Background: config.QRCodeBackground,
+			Fade:       config.QRCodeFade,
+			Payload:    config.QRCodePayload,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return img.DrawOverlays(config)
+}
+
 // parseArgs parses the command line arguments and sets the configuration accordingly.
 func parseArgs(config *core.Config, args ...string) {
+	parseFlags(newFlagSet(config, ""), config, args...)
+}
+
+// newFlagSet sets the configuration defaults and returns the flags of the given subcommand bound to the configuration.
+func newFlagSet(config *core.Config, subcommand string) *pflag.FlagSet {
 	config.Day.SetDefault(types.DayToday)
 	config.Day.SetValues(types.AllowedDays...)
 
@@ -164,9 +218,54 @@ func parseArgs(config *core.Config, args ...string) {
 	config.Resolution.SetDefault(types.HighDefinition)
 	config.Resolution.SetValues(types.AllowedResolutions...)
 
-	opts := pflag.NewFlagSet("bing-wallpaper-changer", pflag.ContinueOnError)
+	config.QRCodePosition.SetDefault(types.PositionTopRight)
+	config.QRCodePosition.SetValues(types.AllowedPositions...)
+
+	config.QRCodeLevel.SetDefault(core.QRCodeLevelMedium)
+	config.QRCodeLevel.SetValues(core.AllowedQRCodeLevels...)
+
+	config.QRCodeSize = 15
+	config.QRCodeForeground = types.Color{A: 0xff}
+	config.QRCodeBackground = types.Color{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	config.QRCodeFade = 5
+
+	config.WatermarkMode.SetDefault(core.WatermarkModeStretch)
+	config.WatermarkMode.SetValues(core.AllowedWatermarkModes...)
+
+	config.WatermarkPosition.SetDefault(types.PositionBottomRight)
+	config.WatermarkPosition.SetValues(types.AllowedPositions...)
+
+	config.WatermarkOpacity = 100
+	config.WatermarkSize = 20
+
+	config.TextWatermarkPosition.SetDefault(types.PositionBottomLeft)
+	config.TextWatermarkPosition.SetValues(types.AllowedPositions...)
+
+	config.SystemInfoPosition.SetDefault(types.PositionBottomLeft)
+	config.SystemInfoPosition.SetValues(types.AllowedPositions...)
+
+	config.CalendarView.SetDefault(core.CalendarViewAgenda)
+	config.CalendarView.SetValues(core.AllowedCalendarViews...)
+
+	config.CalendarPosition.SetDefault(types.PositionTopRight)
+	config.CalendarPosition.SetValues(types.AllowedPositions...)
+
+	config.WeatherPosition.SetDefault(types.PositionTopLeft)
+	config.WeatherPosition.SetValues(types.AllowedPositions...)
+
+	config.TextWatermarkColor = types.Color{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	config.TextWatermarkOpacity = 50
+
+	config.OutputFormat.SetDefault(core.OutputFormatPNG)
+	config.OutputFormat.SetValues(core.AllowedOutputFormats...)
+
+	config.PNGCompression.SetDefault(core.PNGCompressionDefault)
+	config.PNGCompression.SetValues(core.AllowedPNGCompressions...)
+
+	name := strings.TrimSpace("bing-wallpaper-changer " + subcommand)
+	opts := pflag.NewFlagSet(name, pflag.ContinueOnError)
 	opts.Usage = func() {
-		_, _ = fmt.Fprintf(os.Stderr, "Usage of bing-wallpaper-changer [Version: %s, BuildDate: %s]:\n\n", Version, BuildDate)
+		_, _ = fmt.Fprintf(os.Stderr, "Usage of %s [Version: %s, BuildDate: %s]:\n\n", name, Version, BuildDate)
 		_, _ = fmt.Fprintf(os.Stderr, "Flags:\n\n")
 		opts.PrintDefaults()
 		_, _ = fmt.Fprintln(os.Stderr, "")
@@ -176,6 +275,13 @@ func parseArgs(config *core.Config, args ...string) {
 	defaultDownloadDirectory = filepath.Join(defaultDownloadDirectory, "Pictures", "BingWallpapers")
 
 	opts.IntVar(&config.ApiPort, "api-port", 44244, "the port number of the API server")
+	opts.StringVar(&config.ApiBind, "api-bind", core.DefaultApiBind, "the address the API server listens on, use 0.0.0.0 to expose the API to the network")
+	opts.StringVar(&config.ApiPublicURL, "api-public-url", "", "the public base URL of the API server linked by the feeds and the OpenAPI specification (e.g. behind a reverse proxy), defaults to the address the API server listens on")
+	opts.DurationVar(&config.ApiReadyMaxAge, "api-ready-max-age", 0, "the maximum age of the last successful refresh reported as ready by /readyz, 0 disables the check")
+	opts.StringVar(&config.ApiSocket, "api-socket", "", "the path of the Unix domain socket the API server listens on instead of the TCP address (accessible by the owner only)")
+	opts.StringVar(&config.ApiTokenFile, "api-token-file", "", "the path to the file holding the bearer token required by the API server, a random token is generated with permissions 0600 if the file does not exist")
+	opts.StringVar(&config.ApiTLSCert, "api-tls-cert", "", "the path to the TLS certificate of the API server, requires --api-tls-key")
+	opts.StringVar(&config.ApiTLSKey, "api-tls-key", "", "the path to the TLS private key of the API server, requires --api-tls-cert")
 	opts.BoolVar(&config.AutoPlayAudio, "auto-play-audio", true, "auto play the audio description")
 	opts.Var(&config.Day, "day", fmt.Sprintf("the day to fetch the wallpaper for, allowed values are: %s", config.Day.Values()))
 	opts.Var(&config.Mode, "mode", fmt.Sprintf("the mode of the wallpaper, allowed values are: %s", config.Mode.Values()))
@@ -183,10 +289,41 @@ func parseArgs(config *core.Config, args ...string) {
 	opts.Var(&config.Resolution, "resolution", fmt.Sprintf("the resolution of the wallpaper, allowed values are: %s", config.Resolution.Values()))
 	opts.BoolVar(&config.DrawDescription, "description", true, "draw the description on the wallpaper")
 	opts.BoolVar(&config.DrawQRCode, "qrcode", true, "draw the QR code on the wallpaper")
+	opts.Var(&config.QRCodePosition, "qrcode-position", fmt.Sprintf("the position of the QR code, allowed values are: %s", config.QRCodePosition.Values()))
+	opts.Var(&config.QRCodeSize, "qrcode-size", "the size of the QR code relative to the height of the wallpaper (0.0 to 100.0)")
+	opts.Var(&config.QRCodeLevel, "qrcode-level", fmt.Sprintf("the error correction level of the QR code, allowed values are: %s", config.QRCodeLevel.Values()))
+	opts.Var(&config.QRCodeForeground, "qrcode-foreground", "the foreground color of the QR code (#rrggbb or #rrggbbaa)")
+	opts.Var(&config.QRCodeBackground, "qrcode-background", "the background color of the QR code (#rrggbb or #rrggbbaa)")
+	opts.Var(&config.QRCodeFade, "qrcode-fade", "the width of the transparent fade at the edges of the QR code relative to its size (0.0 to 100.0), 0 disables the fade")
+	opts.StringVar(&config.QRCodePayload, "qrcode-payload", core.DefaultQRCodePayload, "the text/template of the QR code content, executed with the wallpaper (e.g. {{.SearchURL}}, {{.DownloadURL}} or {{.Description}})")
 	opts.StringVar(&config.Watermark, "watermark", extras.DefaultWatermarkName, "draw the watermark on the wallpaper")
+	opts.Var(&config.WatermarkMode, "watermark-mode", fmt.Sprintf("the placement mode of the watermark, allowed values are: %s", config.WatermarkMode.Values()))
+	opts.Var(&config.WatermarkPosition, "watermark-position", fmt.Sprintf("the position of the watermark in corner mode, allowed values are: %s", config.WatermarkPosition.Values()))
+	opts.Var(&config.WatermarkOpacity, "watermark-opacity", "the opacity of the watermark (0.0 to 100.0)")
+	opts.Var(&config.WatermarkSize, "watermark-size", "the size of the watermark relative to the wallpaper in center, corner and tile mode (0.0 to 100.0)")
+	opts.IntVar(&config.WatermarkMargin, "watermark-margin", 50, "the margin of the watermark in pixels in fit, corner and tile mode (applies to the text watermark as well)")
+	opts.Float64Var(&config.WatermarkRotation, "watermark-rotation", 0, "rotate the watermark clockwise by the given angle in degrees")
+	opts.StringVar(&config.TextWatermark, "text-watermark", "", "draw the text watermark on the wallpaper, the placeholders {hostname} and {username} are expanded")
+	opts.StringVar(&config.TextWatermarkFont, "text-watermark-font", extras.DefaultFontName, fmt.Sprintf("the font of the text watermark, either a path to a font file or any of: %s", extras.EmbeddedFonts))
+	opts.Float64Var(&config.TextWatermarkSize, "text-watermark-size", 48, "the font size of the text watermark in points")
+	opts.Var(&config.TextWatermarkColor, "text-watermark-color", "the color of the text watermark (#rrggbb or #rrggbbaa)")
+	opts.Var(&config.TextWatermarkOpacity, "text-watermark-opacity", "the opacity of the text watermark (0.0 to 100.0)")
+	opts.Float64Var(&config.TextWatermarkRotation, "text-watermark-rotation", 0, "rotate the text watermark clockwise by the given angle in degrees")
+	opts.Var(&config.TextWatermarkPosition, "text-watermark-position", fmt.Sprintf("the position of the text watermark, allowed values are: %s", config.TextWatermarkPosition.Values()))
+	opts.BoolVar(&config.TextWatermarkRepeat, "text-watermark-repeat", false, "repeat the text watermark diagonally across the wallpaper")
 	opts.BoolVar(&config.DownloadOnly, "download-only", false, "download the wallpaper only")
 	opts.StringVar(&config.DownloadDirectory, "download-directory", defaultDownloadDirectory, "the directory to download the wallpaper to")
-	opts.BoolVar(&config.RotateCounterClockwise, "rotate-counter-clockwise", false, "rotate the watermark counter-clockwise if necessary (default is clockwise)")
+	opts.IntVar(&config.RetentionKeepLast, "retention-keep-last", 0, "keep only the given number of most recently saved wallpapers in the download directory, 0 disables the rule")
+	opts.IntVar(&config.RetentionKeepDays, "retention-keep-days", 0, "keep only the wallpapers saved within the given number of days in the download directory, 0 disables the rule")
+	opts.Float64Var(&config.RetentionMaxSize, "retention-max-size", 0, "delete the oldest wallpapers once the download directory exceeds the given size in MB, 0 disables the rule,\n"+
+		"the retention rules are applied after each save and by the \"prune\" subcommand, pinned wallpapers (marked by a .pinned file next to them) and the latest one are never deleted")
+	opts.StringVar(&config.FileNameTemplate, "file-name-template", core.DefaultFileNameTemplate, "the name of the saved wallpaper relative to the download directory without extension, the placeholders {id}, {date}, {year}, {month}, {day}, {region}, {title-slug} and {resolution}\nare expanded and slashes create subdirectories (e.g. {year}/{month}/{date}_{title-slug}), existing files of other wallpapers are not overwritten, but numbered (e.g. -2)")
+	opts.Var(&config.OutputFormat, "output-format", fmt.Sprintf("the format of the saved wallpaper, allowed values are: %s", config.OutputFormat.Values()))
+	opts.IntVar(&config.JPEGQuality, "jpeg-quality", core.DefaultJPEGQuality, "the quality of the saved wallpaper in jpeg format (1 to 100)")
+	opts.Var(&config.PNGCompression, "png-compression", fmt.Sprintf("the compression level of the saved wallpaper in png format, allowed values are: %s", config.PNGCompression.Values()))
+	opts.BoolVar(&config.KeepOriginal, "keep-original", false, "keep the original wallpaper as downloaded from Bing next to the saved one (with the suffix \".original\")")
+	opts.BoolVar(&config.WriteSidecar, "sidecar", true, "write a JSON sidecar next to the saved wallpaper describing it (Bing metadata, description, translation, furigana, render settings and file hashes)")
+	opts.BoolVar(&config.RotateCounterClockwise, "rotate-counter-clockwise", false, "rotate portrait watermarks counter-clockwise in stretch mode (default is clockwise)")
 	opts.StringVar(&config.GoogleAppCredentials, "google-app-credentials", "", fmt.Sprintf("the path to the Google App credentials file for the translation service for %s to %s,\nif not provided, the translation service will not be used", types.NonEnglishRegions, types.RegionUnitedStates))
 	opts.StringVar(&config.FuriganaApiAppId, "furigana-api-app-id", "", "the Goo Labs API App ID (labs.goo.ne.jp) for the furigana service, if not provided, Jisho.org (if available) or github.com/sarumaj/go-kakasi will be used")
 	opts.BoolVar(&config.UseGoogleText2SpeechService, "use-google-text2speech-service", false, "use the Google Text2Speech service to record and play the audio description (not supported on darwin, and linux unless compiled with cgo)")
@@ -194,7 +331,35 @@ func parseArgs(config *core.Config, args ...string) {
 	opts.BoolVar(&config.Daemon, "daemon", false, "run the application as a daemon process")
 	opts.BoolVar(&config.Debug, "debug", false, "enable debug mode")
 	opts.Var(&config.DimImage, "dim-image", "dim the image by the given percentage (0.0 to 100.0)")
+	opts.BoolVar(&config.ExtractPalette, "extract-palette", false, "extract the dominant color palette of the wallpaper and export it to the \"palette\" subdirectory of the download directory\n(JSON, pywal colors.json, Xresources, kitty, alacritty and CSS variables)")
+	opts.IntVar(&config.PaletteSize, "palette-size", core.MaxPaletteSize, fmt.Sprintf("the number of colors of the extracted palette (%d to %d)", core.MinPaletteSize, core.MaxPaletteSize))
+	opts.StringVar(&config.PostSetHook, "post-set-hook", "", "the shell command to run after the wallpaper has been set,\nthe wallpaper path and the palette are passed as BING_WALLPAPER* environment variables and the palette as JSON on stdin")
+
+	opts.BoolVar(&config.DrawSystemInfo, "system-info", false, "draw the system information (hostname, IP addresses, OS release, kernel, uptime and disk usage) on the wallpaper")
+	opts.Var(&config.SystemInfoPosition, "system-info-position", fmt.Sprintf("the position of the system information, allowed values are: %s", config.SystemInfoPosition.Values()))
+	opts.StringVar(&config.SystemInfoTemplate, "system-info-template", "", "the text/template used to lay out the system information, if not provided, a built-in template will be used")
+	opts.StringVar(&config.SystemInfoTemplateFile, "system-info-template-file", "", "the path to a file with the text/template used to lay out the system information, it takes precedence over --system-info-template")
+	opts.StringVar(&config.SystemInfoOwner, "system-info-owner", "", "the owner of the machine shown in the system information")
+	opts.StringVar(&config.CalendarFile, "calendar-file", "", "the path to an iCalendar (.ics) file, today's agenda or the month grid is drawn on the wallpaper if provided")
+	opts.Var(&config.CalendarView, "calendar-view", fmt.Sprintf("the layout of the calendar, allowed values are: %s", config.CalendarView.Values()))
+	opts.Var(&config.CalendarPosition, "calendar-position", fmt.Sprintf("the position of the calendar, allowed values are: %s", config.CalendarPosition.Values()))
+	opts.BoolVar(&config.DrawWeather, "weather", false, "draw the current weather conditions and the forecast (icon, temperature and wind) of the weather location on the wallpaper using Open-Meteo")
+	opts.Float64Var(&config.WeatherLatitude, "weather-latitude", 0, "the latitude of the weather location")
+	opts.Float64Var(&config.WeatherLongitude, "weather-longitude", 0, "the longitude of the weather location")
+	opts.StringVar(&config.WeatherLocationName, "weather-location-name", "", "the name of the weather location shown as title of the weather")
+	opts.IntVar(&config.WeatherForecastDays, "weather-forecast-days", 3, fmt.Sprintf("the number of forecast days (1 to %d)", core.MaxWeatherForecastDays))
+	opts.Var(&config.WeatherPosition, "weather-position", fmt.Sprintf("the position of the weather, allowed values are: %s", config.WeatherPosition.Values()))
+	opts.DurationVar(&config.WeatherCacheTTL, "weather-cache-ttl", core.DefaultWeatherCacheTTL, "the time to live of the cached weather, the weather is skipped if it cannot be fetched (e.g. offline)")
+	opts.Var(&config.Messages, "message", "draw a custom text (e.g. message of the day) on the wallpaper, can be repeated, the value is either the text itself, a file path prefixed with @ or an HTTP(S) URL,\n"+
+		"the text is re-read on every render and supports multiple lines, [b]bold[/b] and [color=#rrggbb]colored[/color] text,\n"+
+		"to set the position and the expiry, provide a JSON object, e.g. {\"text\": \"Release freeze\", \"position\": 4, \"expires\": \"2024-12-24T00:00:00Z\"}")
+	opts.DurationVar(&config.OverlayRefreshInterval, "overlay-refresh-interval", 5*time.Minute, "the interval in which the overlays (e.g. system information, calendar, weather, messages) are redrawn in daemon mode without downloading the wallpaper again, 0 disables the refresh")
+
+	return opts
+}
 
+// parseFlags parses the command line arguments with the given flags.
+func parseFlags(opts *pflag.FlagSet, config *core.Config, args ...string) {
 	if err := opts.Parse(args); err != nil {
 		if !errors.Is(err, pflag.ErrHelp) {
 			logger.Logger.Fatalln(err)
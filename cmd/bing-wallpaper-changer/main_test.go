@@ -43,7 +43,7 @@ func TestFlow(t *testing.T) {
 
 				t.Logf("Fetched wallpaper: %#v", img)
 
-				if err := img.DrawWatermark(extras.DefaultWatermarkName, false); err != nil {
+				if err := img.DrawWatermark(extras.DefaultWatermarkName, core.WatermarkOptions{Opacity: 100}); err != nil {
 					return fmt.Errorf("DrawWatermark() failed: %w", err)
 				}
 
@@ -55,13 +55,19 @@ func TestFlow(t *testing.T) {
 
 				t.Logf("Description drawn: %#v", img)
 
-				if err := img.DrawQRCode(tt.args.resolution, tt.args.qrcodePosition); err != nil {
+				if err := img.DrawQRCode(core.QRCodeOptions{
+					Position:   tt.args.qrcodePosition,
+					Size:       15,
+					Foreground: types.Color{A: 0xff},
+					Background: types.Color{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+					Fade:       5,
+				}); err != nil {
 					return fmt.Errorf("DrawQRCode() failed: %w", err)
 				}
 
 				t.Logf("QR code drawn: %#v", img)
 
-				path, err := img.EncodeAndDump(tempDir)
+				path, err := img.EncodeAndDump(tempDir, core.EncodeOptions{})
 				if err != nil {
 					return fmt.Errorf("EncodeAndDump() failed: %w", err)
 				}
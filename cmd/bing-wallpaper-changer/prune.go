@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/core"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+)
+
+// prune deletes the wallpapers violating the retention policy from the download directory and lists them.
+func prune(config *core.Config, args ...string) {
+	opts := newFlagSet(config, "prune")
+	dryRun := opts.Bool("dry-run", false, "list the wallpapers violating the retention policy without deleting them")
+	parseFlags(opts, config, args...)
+
+	policy := config.RetentionPolicy()
+	if !policy.Enabled() {
+		logger.Logger.Fatalln("No retention rule enabled, provide any of: --retention-keep-last, --retention-keep-days, --retention-max-size")
+	}
+
+	pruned, err := core.Prune(config.DownloadDirectory, policy, *dryRun)
+	action := "Deleted"
+	if *dryRun {
+		action = "Would delete"
+	}
+
+	var size int64
+	for _, entry := range pruned {
+		size += entry.Size
+		fmt.Printf("%s: %s\n", action, entry.Path)
+	}
+
+	fmt.Printf("%s %d wallpaper(s), %.2f MB\n", action, len(pruned), float64(size)/1024/1024)
+	if err != nil {
+		logger.Logger.Fatalln(err)
+	}
+}
@@ -0,0 +1,415 @@
+// Package client implements a typed client of the HTTP API of the Bing Wallpaper Changer (see /openapi.json).
+// It depends on the standard library only, the documents of the API are defined in this package.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type (
+	// Client calls the HTTP API of the daemon.
+	Client struct {
+		baseURL *url.URL
+		http    *http.Client
+		token   string
+	}
+
+	// Option configures the client.
+	Option func(*Client) error
+
+	// PatchOptions are the options of a config update.
+	PatchOptions struct {
+		// IfMatch is the entity tag of the config the patch is based on, the update fails if the config has been modified since.
+		IfMatch string
+		// Refresh refreshes the wallpaper, even if no field has changed.
+		Refresh bool
+	}
+
+	// PatchResult is the result of a config update.
+	PatchResult struct {
+		// ETag is the entity tag of the updated config.
+		ETag string
+		// Job is the ID of the refresh job, if any.
+		Job string
+	}
+
+	// Error is the error returned by the API.
+	// Fields lists the invalid fields of a rejected config update.
+	Error struct {
+		StatusCode int                `json:"-"`
+		Message    string             `json:"error"`
+		Fields     []ConfigFieldError `json:"fields,omitempty"`
+	}
+)
+
+// Error returns the status code, the message and the invalid fields of the error.
+func (e *Error) Error() string {
+	message := fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+	for _, field := range e.Fields {
+		message += fmt.Sprintf(", %s: %s", field.Field, field.Error)
+	}
+
+	return message
+}
+
+// New creates a new client of the API at the base URL (e.g. http://127.0.0.1:8080).
+func New(baseURL string, options ...Option) (*Client, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL: %s, expected scheme http or https", baseURL)
+	}
+
+	c := &Client{baseURL: parsed, http: &http.Client{}}
+	for _, option := range options {
+		if err := option(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// WithToken sets the bearer token sent with the requests.
+func WithToken(token string) Option {
+	return func(c *Client) error {
+		c.token = token
+		return nil
+	}
+}
+
+// WithTokenFile reads the bearer token sent with the requests from the file (see flag --api-token-file).
+func WithTokenFile(path string) Option {
+	return func(c *Client) error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		c.token = strings.TrimSpace(string(content))
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to send the requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) error {
+		c.http = client
+		return nil
+	}
+}
+
+// WithUnixSocket connects to the Unix domain socket instead of the host of the base URL (see flag --api-socket).
+func WithUnixSocket(path string) Option {
+	return func(c *Client) error {
+		transport, err := c.transport()
+		if err != nil {
+			return err
+		}
+
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		}
+		return nil
+	}
+}
+
+// WithCACert trusts the PEM encoded certificate of the file, e.g. the self-signed certificate of the daemon (see flag --api-tls-cert).
+func WithCACert(path string) Option {
+	return func(c *Client) error {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found: %s", path)
+		}
+
+		transport, err := c.transport()
+		if err != nil {
+			return err
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		return nil
+	}
+}
+
+// transport returns the transport of the HTTP client, which is created if not set.
+func (c *Client) transport() (*http.Transport, error) {
+	if c.http.Transport == nil {
+		c.http.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	transport, ok := c.http.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("unsupported transport: %T", c.http.Transport)
+	}
+
+	return transport, nil
+}
+
+// Config returns the config and its entity tag.
+func (c *Client) Config(ctx context.Context) (*Config, string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/config", nil, nil, "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	var config Config
+	if err := decode(resp, &config); err != nil {
+		return nil, "", err
+	}
+
+	return &config, resp.Header.Get("ETag"), nil
+}
+
+// PatchConfig updates the config with the merge patch (RFC 7396), e.g. map[string]any{"mode": "fill"}.
+func (c *Client) PatchConfig(ctx context.Context, patch any, options PatchOptions) (*PatchResult, error) {
+	return c.patchConfig(ctx, MediaTypeMergePatch, patch, options)
+}
+
+// ApplyJSONPatch updates the config with the operations of the JSON patch (RFC 6902).
+func (c *Client) ApplyJSONPatch(ctx context.Context, operations []JSONPatchOperation, options PatchOptions) (*PatchResult, error) {
+	return c.patchConfig(ctx, MediaTypeJSONPatch, operations, options)
+}
+
+// patchConfig sends the patch of the given media type.
+func (c *Client) patchConfig(ctx context.Context, contentType string, patch any, options PatchOptions) (*PatchResult, error) {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	if options.Refresh {
+		query.Set("refresh", "true")
+	}
+
+	header := http.Header{"Content-Type": {contentType}}
+	if options.IfMatch != "" {
+		header.Set("If-Match", options.IfMatch)
+	}
+
+	resp, err := c.do(ctx, http.MethodPatch, "/config", query, header, string(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return &PatchResult{
+		ETag: resp.Header.Get("ETag"),
+		Job:  strings.TrimPrefix(resp.Header.Get("Location"), "/jobs/"),
+	}, nil
+}
+
+// Refresh starts a refresh job or returns the running one.
+func (c *Client) Refresh(ctx context.Context) (*Job, error) {
+	var job Job
+	return &job, c.get(ctx, http.MethodPost, "/refresh", nil, &job)
+}
+
+// Job returns the refresh job.
+func (c *Client) Job(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	return &job, c.get(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), nil, &job)
+}
+
+// WaitJob polls the refresh job in the given interval until it has finished.
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (*Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job, err := c.Job(ctx, id)
+		if err != nil || (job.State != JobStateRunning && job.State != JobStateQueued) {
+			return job, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
+		case <-ticker.C:
+		}
+	}
+}
+
+// Wallpaper returns the metadata of the current wallpaper.
+func (c *Client) Wallpaper(ctx context.Context) (*WallpaperDocument, error) {
+	var document WallpaperDocument
+	return &document, c.get(ctx, http.MethodGet, "/wallpaper", nil, &document)
+}
+
+// WallpaperImage returns the rendered image of the current wallpaper, the caller must close it.
+func (c *Client) WallpaperImage(ctx context.Context) (io.ReadCloser, error) {
+	return c.open(ctx, "/wallpaper/image")
+}
+
+// WallpaperOriginal returns the image of the current wallpaper as downloaded from Bing, the caller must close it.
+func (c *Client) WallpaperOriginal(ctx context.Context) (io.ReadCloser, error) {
+	return c.open(ctx, "/wallpaper/original")
+}
+
+// WallpaperAudio returns the audio description of the current wallpaper, the caller must close it.
+func (c *Client) WallpaperAudio(ctx context.Context) (io.ReadCloser, error) {
+	return c.open(ctx, "/wallpaper/audio")
+}
+
+// Description returns the description of the current wallpaper in the language, or as drawn if the language is empty.
+func (c *Client) Description(ctx context.Context, lang string) (*DescriptionDocument, error) {
+	query := url.Values{}
+	if lang != "" {
+		query.Set("lang", lang)
+	}
+
+	var document DescriptionDocument
+	return &document, c.get(ctx, http.MethodGet, "/wallpaper/description", query, &document)
+}
+
+// Palette returns the color palette of the current wallpaper.
+func (c *Client) Palette(ctx context.Context) (*PaletteDocument, error) {
+	var document PaletteDocument
+	return &document, c.get(ctx, http.MethodGet, "/palette", nil, &document)
+}
+
+// Health returns the outcome of the last refresh jobs of the daemon.
+func (c *Client) Health(ctx context.Context) (*HealthDocument, error) {
+	var document HealthDocument
+	return &document, c.get(ctx, http.MethodGet, "/healthz", nil, &document)
+}
+
+// Events streams the events of the given types (all if none) until the context is cancelled or the stream ends.
+// The channel is closed when the stream ends.
+func (c *Client) Events(ctx context.Context, types ...string) (<-chan Event, error) {
+	query := url.Values{}
+	if len(types) > 0 {
+		query.Set("types", strings.Join(types, ","))
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/events", query, http.Header{"Accept": {"text/event-stream"}}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		var data bytes.Buffer
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data.WriteString(strings.TrimPrefix(value, " "))
+				continue
+			}
+
+			// a blank line dispatches the event, comments and the other fields are repeated in the data
+			if line != "" || data.Len() == 0 {
+				continue
+			}
+
+			var event Event
+			err := json.Unmarshal(data.Bytes(), &event)
+			data.Reset()
+			if err != nil {
+				continue
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// get sends the request and decodes the JSON response into the target.
+func (c *Client) get(ctx context.Context, method, path string, query url.Values, target any) error {
+	resp, err := c.do(ctx, method, path, query, nil, "")
+	if err != nil {
+		return err
+	}
+
+	return decode(resp, target)
+}
+
+// open sends the GET request and returns the body of the response.
+func (c *Client) open(ctx context.Context, path string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// do sends the request with the bearer token, if any.
+// Responses with an error status code are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body string) (*http.Response, error) {
+	uri := *c.baseURL
+	uri.Path += path
+	uri.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, uri.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < http.StatusBadRequest {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	content, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(content, apiErr) != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(content))
+	}
+
+	return nil, apiErr
+}
+
+// decode decodes the JSON body of the response and closes it.
+func decode(resp *http.Response, target any) error {
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(target)
+}
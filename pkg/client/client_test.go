@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func setupServer(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = io.WriteString(w, `{"error":"Unauthorized"}`)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(server.URL, WithToken("secret"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestNew(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		args    string
+		wantErr bool
+	}{
+		{"test#1", "http://127.0.0.1:8080", false},
+		{"test#2", "https://localhost:8080/", false},
+		{"test#3", "unix:///tmp/api.sock", true},
+		{"test#4", "://", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New(%q) error = %v, wantErr %t", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	client := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/config":
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = io.WriteString(w, `{"dimImage":25,"mode":{"value":1,"name":"fill","values":[0,1],"alias":""},"region":"ja-JP","messages":[{"text":"Hello","position":4}]}`)
+
+		case r.Method == http.MethodPatch && r.Header.Get("If-Match") == `"v1"` && r.Header.Get("Content-Type") == MediaTypeMergePatch:
+			if body, _ := io.ReadAll(r.Body); string(body) != `{"dimImage":50}` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("ETag", `"v2"`)
+			if r.URL.Query().Get("refresh") == "true" {
+				w.Header().Set("Location", "/jobs/7")
+			}
+			w.WriteHeader(http.StatusAccepted)
+
+		default:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = io.WriteString(w, `{"error":"Invalid config","fields":[{"field":"jpegQuality","error":"out of range"}]}`)
+
+		}
+	})
+
+	config, etag, err := client.Config(context.Background())
+	if err != nil {
+		t.Fatalf("Config() error = %v", err)
+	}
+
+	if etag != `"v1"` || config.DimImage != 25 || config.Mode != "fill" || config.Region != "ja-JP" || len(config.Messages) != 1 || config.Messages[0].Position != 4 {
+		t.Errorf("Config() = %+v, %s, want dimImage 25, mode fill, region ja-JP, a message and entity tag \"v1\"", config, etag)
+	}
+
+	result, err := client.PatchConfig(context.Background(), map[string]any{"dimImage": 50}, PatchOptions{IfMatch: etag, Refresh: true})
+	if err != nil {
+		t.Fatalf("PatchConfig() error = %v", err)
+	}
+
+	if *result != (PatchResult{ETag: `"v2"`, Job: "7"}) {
+		t.Errorf("PatchConfig() = %+v, want entity tag \"v2\" and job 7", result)
+	}
+
+	_, err = client.ApplyJSONPatch(context.Background(), []JSONPatchOperation{{Op: "replace", Path: "/jpegQuality", Value: []byte("0")}}, PatchOptions{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "jpegQuality" {
+		t.Errorf("ApplyJSONPatch() error = %v, want invalid field jpegQuality", err)
+	}
+}
+
+func TestClientJobs(t *testing.T) {
+	var polls atomic.Int32
+	client := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		state := "running"
+		if r.URL.Path == "/jobs/1" && polls.Add(1) > 2 {
+			state = "succeeded"
+		} else if r.URL.Path != "/refresh" && r.URL.Path != "/jobs/1" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"error":"Job not found"}`)
+			return
+		}
+
+		_, _ = fmt.Fprintf(w, `{"id":"1","trigger":"api","state":%q,"steps":[],"coalesced":0}`, state)
+	})
+
+	job, err := client.Refresh(context.Background())
+	if err != nil || job.ID != "1" || job.State != JobStateRunning {
+		t.Fatalf("Refresh() = %+v, %v, want running job 1", job, err)
+	}
+
+	job, err = client.WaitJob(context.Background(), job.ID, time.Millisecond)
+	if err != nil || job.State != JobStateSucceeded {
+		t.Errorf("WaitJob() = %+v, %v, want succeeded job", job, err)
+	}
+
+	if _, err := client.Job(context.Background(), "2"); err == nil || err.Error() != "404 Not Found: Job not found" {
+		t.Errorf("Job() error = %v, want not found", err)
+	}
+
+	client.token = ""
+	if _, err := client.Refresh(context.Background()); err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("Refresh() error = %v, want unauthorized", err)
+	}
+}
+
+func TestClientEvents(t *testing.T) {
+	client := setupServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("types") != "config.changed,refresh.succeeded" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, ": keep-alive\n\n")
+		_, _ = io.WriteString(w, "id: 1\nevent: config.changed\ndata: {\"id\":1,\"type\":\"config.changed\",\"time\":\"2024-01-01T00:00:00Z\",\"data\":{\"dimImage\":{\"old\":0,\"new\":25}}}\n\n")
+		_, _ = io.WriteString(w, "id: 2\nevent: refresh.succeeded\ndata: {\"id\":2,\"type\":\"refresh.succeeded\",\"time\":\"2024-01-01T00:00:01Z\"}\n\n")
+	})
+
+	events, err := client.Events(context.Background(), EventConfigChanged, EventRefreshSucceeded)
+	if err != nil {
+		t.Fatalf("Events() error = %v", err)
+	}
+
+	var got []string
+	for event := range events {
+		got = append(got, fmt.Sprintf("%d %s", event.ID, event.Type))
+	}
+
+	if strings.Join(got, ",") != "1 config.changed,2 refresh.succeeded" {
+		t.Errorf("Events() = %v, want config.changed and refresh.succeeded", got)
+	}
+}
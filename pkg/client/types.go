@@ -0,0 +1,224 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The media types of the config patches.
+const (
+	MediaTypeMergePatch = "application/merge-patch+json"
+	MediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+// The states of a refresh job.
+const (
+	JobStateRunning   = "running"
+	JobStateSucceeded = "succeeded"
+	JobStateFailed    = "failed"
+	JobStateQueued    = "queued"
+)
+
+// The types of the events.
+const (
+	EventWallpaperChanged = "wallpaper.changed"
+	EventConfigChanged    = "config.changed"
+	EventRefreshStarted   = "refresh.started"
+	EventRefreshSucceeded = "refresh.succeeded"
+	EventRefreshFailed    = "refresh.failed"
+	EventAudioStarted     = "audio.started"
+	EventAudioStopped     = "audio.stopped"
+)
+
+// The documents of the API, they mirror the schemas of /openapi.json, so that the client does not depend on the daemon.
+type (
+	// Config is the config document, its fields are described by the Config schema of /openapi.json.
+	// Percentages range from 0 to 100, colors are hex encoded (e.g. #1a2b3c) and durations are given in nanoseconds.
+	Config struct {
+		AutoPlayAudio               bool          `json:"autoPlayAudio"`
+		Day                         Enum          `json:"day"`
+		Mode                        Enum          `json:"mode"`
+		Region                      Enum          `json:"region"`
+		Resolution                  Enum          `json:"resolution"`
+		DrawDescription             bool          `json:"drawDescription"`
+		DrawQRCode                  bool          `json:"drawQRCode"`
+		QRCodePosition              Enum          `json:"qrCodePosition"`
+		QRCodeSize                  float64       `json:"qrCodeSize"`
+		QRCodeLevel                 Enum          `json:"qrCodeLevel"`
+		QRCodeForeground            string        `json:"qrCodeForeground"`
+		QRCodeBackground            string        `json:"qrCodeBackground"`
+		QRCodeFade                  float64       `json:"qrCodeFade"`
+		QRCodePayload               string        `json:"qrCodePayload"`
+		Watermark                   string        `json:"watermark"`
+		WatermarkMode               Enum          `json:"watermarkMode"`
+		WatermarkPosition           Enum          `json:"watermarkPosition"`
+		WatermarkOpacity            float64       `json:"watermarkOpacity"`
+		WatermarkSize               float64       `json:"watermarkSize"`
+		WatermarkMargin             int           `json:"watermarkMargin"`
+		WatermarkRotation           float64       `json:"watermarkRotation"`
+		TextWatermark               string        `json:"textWatermark"`
+		TextWatermarkFont           string        `json:"textWatermarkFont"`
+		TextWatermarkSize           float64       `json:"textWatermarkSize"`
+		TextWatermarkColor          string        `json:"textWatermarkColor"`
+		TextWatermarkOpacity        float64       `json:"textWatermarkOpacity"`
+		TextWatermarkRotation       float64       `json:"textWatermarkRotation"`
+		TextWatermarkPosition       Enum          `json:"textWatermarkPosition"`
+		TextWatermarkRepeat         bool          `json:"textWatermarkRepeat"`
+		DownloadOnly                bool          `json:"downloadOnly"`
+		DownloadDirectory           string        `json:"downloadDirectory"`
+		FileNameTemplate            string        `json:"fileNameTemplate"`
+		RetentionKeepLast           int           `json:"retentionKeepLast"`
+		RetentionKeepDays           int           `json:"retentionKeepDays"`
+		RetentionMaxSize            float64       `json:"retentionMaxSize"`
+		OutputFormat                Enum          `json:"outputFormat"`
+		JPEGQuality                 int           `json:"jpegQuality"`
+		PNGCompression              Enum          `json:"pngCompression"`
+		KeepOriginal                bool          `json:"keepOriginal"`
+		WriteSidecar                bool          `json:"writeSidecar"`
+		RotateCounterClockwise      bool          `json:"rotateCounterClockwise"`
+		GoogleAppCredentials        string        `json:"googleAppCredentials"`
+		FuriganaApiAppId            string        `json:"furiganaApiAppId"`
+		UseGoogleText2SpeechService bool          `json:"useGoogleText2SpeechService"`
+		UseGoogleTranslateService   bool          `json:"useGoogleTranslateService"`
+		Daemon                      bool          `json:"daemon"`
+		Debug                       bool          `json:"debug"`
+		DimImage                    float64       `json:"dimImage"`
+		ExtractPalette              bool          `json:"extractPalette"`
+		PaletteSize                 int           `json:"paletteSize"`
+		DrawSystemInfo              bool          `json:"drawSystemInfo"`
+		SystemInfoPosition          Enum          `json:"systemInfoPosition"`
+		SystemInfoTemplate          string        `json:"systemInfoTemplate"`
+		SystemInfoTemplateFile      string        `json:"systemInfoTemplateFile"`
+		SystemInfoOwner             string        `json:"systemInfoOwner"`
+		CalendarFile                string        `json:"calendarFile"`
+		CalendarView                Enum          `json:"calendarView"`
+		CalendarPosition            Enum          `json:"calendarPosition"`
+		DrawWeather                 bool          `json:"drawWeather"`
+		WeatherLatitude             float64       `json:"weatherLatitude"`
+		WeatherLongitude            float64       `json:"weatherLongitude"`
+		WeatherLocationName         string        `json:"weatherLocationName"`
+		WeatherForecastDays         int           `json:"weatherForecastDays"`
+		WeatherPosition             Enum          `json:"weatherPosition"`
+		WeatherCacheTTL             time.Duration `json:"weatherCacheTTL"`
+		Messages                    []Message     `json:"messages"`
+		OverlayRefreshInterval      time.Duration `json:"overlayRefreshInterval"`
+	}
+
+	// Enum is the name of the value of an enum field of the config (e.g. "ja-JP"), as accepted in config patches.
+	Enum string
+
+	// Message is a custom text drawn on the wallpaper, the position ranges from 0 (top left) to 8 (bottom right).
+	Message struct {
+		Text     string    `json:"text,omitempty"`
+		Source   string    `json:"source,omitempty"`
+		Position int       `json:"position"`
+		Expires  time.Time `json:"expires,omitzero"`
+	}
+
+	// ConfigFieldError is the error of a field of a rejected config update.
+	ConfigFieldError struct {
+		Field string `json:"field"`
+		Error string `json:"error"`
+	}
+
+	// JSONPatchOperation is an operation of a JSON patch (RFC 6902).
+	JSONPatchOperation struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		From  string          `json:"from,omitempty"`
+		Value json.RawMessage `json:"value,omitempty"`
+	}
+
+	// Job is a refresh of the wallpaper run in the background by the daemon.
+	Job struct {
+		ID        string    `json:"id"`
+		Trigger   string    `json:"trigger"`
+		State     string    `json:"state"`
+		Started   time.Time `json:"started"`
+		Finished  time.Time `json:"finished,omitzero"`
+		Steps     []JobStep `json:"steps"`
+		Errors    []string  `json:"errors,omitempty"`
+		Coalesced int       `json:"coalesced"`
+	}
+
+	// JobStep is a timed step of a job.
+	JobStep struct {
+		Name     string        `json:"name"`
+		Started  time.Time     `json:"started"`
+		Duration time.Duration `json:"duration"`
+		Error    string        `json:"error,omitempty"`
+	}
+
+	// WallpaperDocument is the metadata of the current wallpaper and the links to its image, original image and audio description.
+	WallpaperDocument struct {
+		Title       string    `json:"title,omitempty"`
+		Copyright   string    `json:"copyright,omitempty"`
+		Description string    `json:"description,omitempty"`
+		Translation string    `json:"translation,omitempty"`
+		Region      string    `json:"region,omitempty"`
+		ID          string    `json:"id,omitempty"`
+		Date        time.Time `json:"date,omitzero"`
+		SearchURL   string    `json:"searchUrl,omitempty"`
+		DownloadURL string    `json:"downloadUrl,omitempty"`
+		Furigana    string    `json:"furigana,omitempty"`
+		Location    string    `json:"location"`
+		Width       int       `json:"width"`
+		Height      int       `json:"height"`
+		Image       string    `json:"image"`
+		Original    string    `json:"original,omitempty"`
+		Audio       string    `json:"audio,omitempty"`
+	}
+
+	// DescriptionDocument is the description of the current wallpaper in the requested language.
+	DescriptionDocument struct {
+		Language    string `json:"language"`
+		Description string `json:"description"`
+		Furigana    string `json:"furigana,omitempty"`
+	}
+
+	// PaletteDocument is the color palette of the current wallpaper, the colors are hex encoded (e.g. #1a2b3c).
+	PaletteDocument struct {
+		Wallpaper  string   `json:"wallpaper"`
+		Background string   `json:"background"`
+		Foreground string   `json:"foreground"`
+		Colors     []string `json:"colors"`
+		Terminal   []string `json:"terminal"`
+	}
+
+	// HealthDocument reports the health of the daemon and the outcome of its refresh jobs.
+	HealthDocument struct {
+		Status                string    `json:"status"`
+		Reason                string    `json:"reason,omitempty"`
+		LastRefresh           time.Time `json:"lastRefresh,omitzero"`
+		LastRefreshState      string    `json:"lastRefreshState,omitempty"`
+		LastSuccessfulRefresh time.Time `json:"lastSuccessfulRefresh,omitzero"`
+	}
+
+	// Event is a change of the state of the daemon, the data depends on the type of the event (e.g. a Job for refresh events).
+	Event struct {
+		ID   uint64          `json:"id"`
+		Type string          `json:"type"`
+		Time time.Time       `json:"time"`
+		Data json.RawMessage `json:"data,omitempty"`
+	}
+)
+
+// UnmarshalJSON decodes the name of the enum value, which is either given as is or by the enum document
+// listing the value, its name and the allowed values.
+func (e *Enum) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*e = Enum(name)
+		return nil
+	}
+
+	var document struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+
+	*e = Enum(document.Name)
+	return nil
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// pinnedSuffix is the suffix of the marker file pinning a wallpaper.
+const pinnedSuffix = ".pinned"
+
+// audioExtensions are the extensions of the audio sidecars.
+var audioExtensions = []string{".mp3", ".ogg", ".opus", ".wav"}
+
+type (
+	// ArchiveEntry is a wallpaper saved in the download directory.
+	// Files sharing the base name of the wallpaper with a known suffix (e.g. audio, original image) are its sidecars.
+	ArchiveEntry struct {
+		Path     string    `json:"path"`
+		Metadata Metadata  `json:"metadata"`
+		ModTime  time.Time `json:"modTime"`
+		Size     int64     `json:"size"`
+		Sidecars []string  `json:"sidecars,omitempty"`
+		Pinned   bool      `json:"pinned"`
+	}
+
+	// Archive is a list of wallpapers ordered from the most recently saved one.
+	Archive []ArchiveEntry
+
+	// RetentionPolicy limits the wallpapers kept in the download directory.
+	// A wallpaper is deleted if it is not among the last KeepLast wallpapers, if it is older than KeepDays
+	// or if it does not fit into MaxSize (in MB) anymore. Zero values disable the rules.
+	RetentionPolicy struct {
+		KeepLast int
+		KeepDays int
+		MaxSize  float64
+	}
+)
+
+// LoadArchive reads the wallpapers saved in the directory and its subdirectories.
+// Only images with embedded metadata (see ReadMetadata) are considered wallpapers, other files are left alone.
+func LoadArchive(dir string) (Archive, error) {
+	var archive Archive
+	var others []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil && path == dir && errors.Is(err, fs.ErrNotExist) {
+			return fs.SkipAll
+		}
+
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		ext := strings.ToLower(filepath.Ext(path))
+		if (ext != ".png" && ext != ".jpg" && ext != ".jpeg") || strings.HasSuffix(strings.TrimSuffix(path, filepath.Ext(path)), ".original") {
+			others = append(others, path)
+			return nil
+		}
+
+		metadata, err := ReadMetadata(path)
+		if err != nil || (metadata.ID == "" && metadata.DownloadURL == "") {
+			others = append(others, path)
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		archive = append(archive, ArchiveEntry{Path: path, Metadata: *metadata, ModTime: info.ModTime(), Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// assign the sidecars to the wallpaper with the longest matching base name, other files are left alone
+	for _, other := range others {
+		best, bestLength := -1, 0
+		for i, entry := range archive {
+			base := strings.TrimSuffix(entry.Path, filepath.Ext(entry.Path))
+			if suffix, ok := strings.CutPrefix(other, base); ok && isSidecarSuffix(suffix) && len(base) > bestLength {
+				best, bestLength = i, len(base)
+			}
+		}
+
+		if best < 0 {
+			continue
+		}
+
+		archive[best].Sidecars = append(archive[best].Sidecars, other)
+		if strings.HasSuffix(other, pinnedSuffix) {
+			archive[best].Pinned = true
+		}
+
+		if info, err := os.Stat(other); err == nil {
+			archive[best].Size += info.Size()
+		}
+	}
+
+	slices.SortStableFunc(archive, func(a, b ArchiveEntry) int {
+		return cmp.Or(b.ModTime.Compare(a.ModTime), strings.Compare(a.Path, b.Path))
+	})
+
+	return archive, nil
+}
+
+// isSidecarSuffix returns true if the suffix following the base name of a wallpaper is one of its sidecars,
+// i.e. the original image (.original.jpg), the audio description, the JSON sidecar or the pin marker.
+func isSidecarSuffix(suffix string) bool {
+	suffix = strings.ToLower(suffix)
+	if suffix == sidecarExtension || suffix == pinnedSuffix || slices.Contains(audioExtensions, suffix) {
+		return true
+	}
+
+	ext, ok := strings.CutPrefix(suffix, ".original")
+	return ok && ext != "" && filepath.Ext(ext) == ext
+}
+
+// Audio returns the path of the audio description of the wallpaper or an empty string.
+func (e ArchiveEntry) Audio() string {
+	for _, sidecar := range e.Sidecars {
+		if slices.Contains(audioExtensions, strings.ToLower(filepath.Ext(sidecar))) {
+			return sidecar
+		}
+	}
+
+	return ""
+}
+
+// Original returns the path of the original image as downloaded from Bing or an empty string.
+func (e ArchiveEntry) Original() string {
+	for _, sidecar := range e.Sidecars {
+		if strings.HasSuffix(strings.TrimSuffix(sidecar, filepath.Ext(sidecar)), ".original") {
+			return sidecar
+		}
+	}
+
+	return ""
+}
+
+// PinWallpaper pins or unpins the wallpaper at the given path.
+// Pinned wallpapers are never deleted by the retention policy.
+func PinWallpaper(path string, pinned bool) error {
+	marker := strings.TrimSuffix(path, filepath.Ext(path)) + pinnedSuffix
+	if !pinned {
+		if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	return os.WriteFile(marker, nil, 0o644)
+}
+
+// IsPinned returns true if the wallpaper at the given path is pinned.
+func IsPinned(path string) bool {
+	_, err := os.Stat(strings.TrimSuffix(path, filepath.Ext(path)) + pinnedSuffix)
+	return err == nil
+}
+
+// RetentionPolicy returns the retention policy of the download directory.
+func (c *Config) RetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{KeepLast: c.RetentionKeepLast, KeepDays: c.RetentionKeepDays, MaxSize: c.RetentionMaxSize}
+}
+
+// Enabled returns true if any rule of the policy is enabled.
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepLast > 0 || p.KeepDays > 0 || p.MaxSize > 0
+}
+
+// Expired returns the wallpapers of the archive violating the policy as of the given time.
+// Pinned wallpapers and the most recently saved wallpaper are never returned.
+func (p RetentionPolicy) Expired(archive Archive, now time.Time) Archive {
+	if !p.Enabled() {
+		return nil
+	}
+
+	var expired Archive
+	var total int64
+	for _, entry := range archive {
+		total += entry.Size
+	}
+
+	maxSize := int64(p.MaxSize * 1024 * 1024)
+	kept := 0
+	for i, entry := range archive {
+		if entry.Pinned || i == 0 {
+			kept++
+			continue
+		}
+
+		if (p.KeepLast > 0 && kept >= p.KeepLast) || (p.KeepDays > 0 && now.Sub(entry.ModTime) > time.Duration(p.KeepDays)*24*time.Hour) {
+			expired = append(expired, entry)
+			total -= entry.Size
+			continue
+		}
+
+		kept++
+	}
+
+	// delete the oldest remaining wallpapers until the archive fits
+	for i := len(archive) - 1; i > 0 && p.MaxSize > 0 && total > maxSize; i-- {
+		if entry := archive[i]; !entry.Pinned && !slices.ContainsFunc(expired, func(e ArchiveEntry) bool { return e.Path == entry.Path }) {
+			expired = append(expired, entry)
+			total -= entry.Size
+		}
+	}
+
+	return expired
+}
+
+// Prune deletes the wallpapers of the directory violating the policy together with their sidecars
+// and returns the deleted wallpapers. If dryRun is set, nothing is deleted.
+// Directories left empty are removed.
+func Prune(dir string, policy RetentionPolicy, dryRun bool) (Archive, error) {
+	archive, err := LoadArchive(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := policy.Expired(archive, time.Now())
+	if dryRun {
+		return expired, nil
+	}
+
+	for _, entry := range expired {
+		for _, path := range append([]string{entry.Path}, entry.Sidecars...) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return expired, err
+			}
+		}
+
+		// remove the empty directories created by the file name template
+		for parent := filepath.Dir(entry.Path); parent != filepath.Clean(dir) && strings.HasPrefix(parent, filepath.Clean(dir)); parent = filepath.Dir(parent) {
+			if os.Remove(parent) != nil {
+				break
+			}
+		}
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// setupTestArchive saves a wallpaper per day (the oldest first) and returns their paths (the newest first).
+func setupTestArchive(t testing.TB, dir string, days int, template string) []string {
+	t.Helper()
+
+	now := time.Now()
+	var paths []string
+	for day := days - 1; day >= 0; day-- {
+		img := SetupTestImage(t)
+		img.ID = "OHR.Test" + string(rune('A'+day))
+		img.Date = now.AddDate(0, 0, -day)
+
+		path, err := img.EncodeAndDump(dir, EncodeOptions{FileNameTemplate: template})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		saved := now.AddDate(0, 0, -day)
+		if err := os.Chtimes(path, saved, saved); err != nil {
+			t.Fatal(err)
+		}
+
+		paths = append([]string{path}, paths...)
+	}
+
+	return paths
+}
+
+func TestLoadArchive(t *testing.T) {
+	dir := t.TempDir()
+	paths := setupTestArchive(t, dir, 3, "{id}")
+
+	base := paths[1][:len(paths[1])-len(".png")]
+	for _, sidecar := range []string{base + ".wav", base + ".original.jpg", base + ".json", base + ".notes.txt", filepath.Join(dir, "notes.txt")} {
+		if err := os.WriteFile(sidecar, []byte("sidecar"), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := PinWallpaper(paths[2], true); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadArchive(dir)
+	if err != nil {
+		t.Fatalf("LoadArchive() error = %v", err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("LoadArchive() = %d entries, want 3", len(got))
+	}
+
+	for i, entry := range got {
+		if entry.Path != paths[i] {
+			t.Errorf("LoadArchive()[%d].Path = %q, want %q", i, entry.Path, paths[i])
+		}
+	}
+
+	if want := []string{base + ".json", base + ".original.jpg", base + ".wav"}; !slices.Equal(got[1].Sidecars, want) || got[1].Pinned {
+		t.Errorf("LoadArchive()[1] = %+v, want sidecars %v", got[1], want)
+	}
+
+	if !got[2].Pinned || !IsPinned(paths[2]) || got[2].Metadata.ID != "OHR.TestC" {
+		t.Errorf("LoadArchive()[2] = %+v, want pinned OHR.TestC", got[2])
+	}
+
+	if err := PinWallpaper(paths[2], false); err != nil || IsPinned(paths[2]) {
+		t.Errorf("PinWallpaper(false) error = %v, want unpinned", err)
+	}
+
+	if err := PinWallpaper(filepath.Join(dir, "missing.png"), true); err == nil {
+		t.Error("PinWallpaper() error = nil, want error for missing wallpaper")
+	}
+
+	if got, err := LoadArchive(filepath.Join(dir, "missing")); err != nil || len(got) != 0 {
+		t.Errorf("LoadArchive() = %v, %v, want empty archive for missing directory", got, err)
+	}
+}
+
+func TestRetentionPolicyExpired(t *testing.T) {
+	now := time.Now()
+	archive := Archive{
+		{Path: "0", ModTime: now, Size: 3 << 20},
+		{Path: "1", ModTime: now.Add(-24 * time.Hour), Size: 3 << 20, Pinned: true},
+		{Path: "2", ModTime: now.Add(-48 * time.Hour), Size: 3 << 20},
+		{Path: "3", ModTime: now.Add(-72 * time.Hour), Size: 3 << 20},
+		{Path: "4", ModTime: now.Add(-96 * time.Hour), Size: 3 << 20},
+	}
+
+	for _, tt := range []struct {
+		name string
+		args RetentionPolicy
+		want []string
+	}{
+		{"test#1", RetentionPolicy{}, nil},
+		{"test#2", RetentionPolicy{KeepLast: 3}, []string{"3", "4"}},
+		{"test#3", RetentionPolicy{KeepLast: 1}, []string{"2", "3", "4"}},
+		{"test#4", RetentionPolicy{KeepDays: 2}, []string{"3", "4"}},
+		{"test#5", RetentionPolicy{MaxSize: 10}, []string{"4", "3"}},
+		{"test#6", RetentionPolicy{KeepDays: 3, MaxSize: 9}, []string{"4", "3"}},
+		{"test#7", RetentionPolicy{MaxSize: 1}, []string{"4", "3", "2"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, entry := range tt.args.Expired(archive, now) {
+				got = append(got, entry.Path)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expired(%+v) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	paths := setupTestArchive(t, dir, 3, "{year}/{month}/{day}/{id}")
+
+	audio := paths[2][:len(paths[2])-len(".png")] + ".wav"
+	if err := os.WriteFile(audio, []byte("audio"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := RetentionPolicy{KeepLast: 2}
+	got, err := Prune(dir, policy, true)
+	if err != nil || len(got) != 1 || got[0].Path != paths[2] {
+		t.Fatalf("Prune(dry run) = %v, %v, want %s", got, err, paths[2])
+	}
+
+	if _, err := os.Stat(paths[2]); err != nil {
+		t.Errorf("Prune(dry run) deleted %s", paths[2])
+	}
+
+	if got, err = Prune(dir, policy, false); err != nil || len(got) != 1 {
+		t.Fatalf("Prune() = %v, %v, want one wallpaper deleted", got, err)
+	}
+
+	for _, path := range []string{paths[2], audio, filepath.Dir(paths[2])} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Prune() left %s behind", path)
+		}
+	}
+
+	for _, path := range paths[:2] {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Prune() deleted %s", path)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package core
 import (
 	"bytes"
 	"io"
-	"os"
 )
 
 type Audio struct {
@@ -17,16 +16,13 @@ type Audio struct {
 func (a *Audio) Close() error { return nil }
 
 // Dump dumps the audio to the target path.
+// The file is written atomically.
 func (a *Audio) Dump(path string) error {
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
-	if err != nil {
-		return err
-	}
-
-	defer f.Close()
-
 	var buffer bytes.Buffer
-	if _, err := io.ReadAll(io.TeeReader(a.Source, io.MultiWriter(&buffer, f))); err != nil {
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.ReadAll(io.TeeReader(a.Source, io.MultiWriter(&buffer, w)))
+		return err
+	}); err != nil {
 		return err
 	}
 
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+)
+
+// DefaultApiBind is the address the API server listens on by default, i.e. the loopback interface only.
+const DefaultApiBind = "127.0.0.1"
+
+// apiTokenSize is the number of random bytes of a generated API token.
+const apiTokenSize = 32
+
+// LoadApiToken reads the bearer token of the API from the file.
+// If the file does not exist, a random token is generated and saved with permissions 0600.
+// Token files accessible by other users are rejected (not checked on Windows).
+func LoadApiToken(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return generateApiToken(path)
+
+	case err != nil:
+		return "", err
+
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkPrivate(info); err != nil {
+		return "", fmt.Errorf("insecure API token file %s: %w", path, err)
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("empty API token file: %s", path)
+	}
+
+	return token, nil
+}
+
+// generateApiToken generates a random token and saves it to the new file readable by the owner only.
+func generateApiToken(path string) (string, error) {
+	random := make([]byte, apiTokenSize)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	token := hex.EncodeToString(random)
+	if _, err := f.WriteString(token + "\n"); err != nil {
+		return "", err
+	}
+
+	logger.Logger.Printf("Generated API token: %s", path)
+	return token, nil
+}
+
+// requireToken returns the handler rejecting requests without the bearer token.
+// Without token, the requests are passed through.
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(provided)), []byte(token)) != 1 {
+			logger.Logger.Printf("Unauthorized: %s %s", r.Method, r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+AppName+`"`)
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiCommand returns the curl command calling the API as configured (address, Unix socket, TLS and bearer token).
+// The token is read from the token file by the shell, so that it is not revealed by the command.
+func apiCommand(cfg *Config, verb, path, query, payload string) string {
+	uri := apiURL(cfg, path, query)
+	args := []string{"curl", "-X", verb}
+	if cfg.ApiSocket != "" {
+		uri.Host = "localhost"
+		args = append(args, "--unix-socket", shellQuote(cfg.ApiSocket))
+	}
+
+	if uri.Scheme == "https" {
+		args = append(args, "--cacert", shellQuote(cfg.ApiTLSCert))
+	}
+
+	if cfg.ApiTokenFile != "" {
+		args = append(args, "-H", `"Authorization: Bearer $(cat `+shellQuote(cfg.ApiTokenFile)+`)"`)
+	}
+
+	args = append(args, shellQuote(uri.String()))
+	if payload != "" {
+		args = append(args, "-d", shellQuote(payload))
+	}
+
+	return strings.Join(args, " ")
+}
+
+// apiURL returns the URL of the API endpoint as reached over TCP.
+func apiURL(cfg *Config, path, query string) *url.URL {
+	uri := &url.URL{Scheme: "http", Host: apiHost(cfg), Path: path, RawQuery: query}
+	if cfg.ApiTLSCert != "" && cfg.ApiTLSKey != "" {
+		uri.Scheme = "https"
+	}
+
+	return uri
+}
+
+// dashboardURL returns the URL of the web dashboard, the bearer token is passed in the fragment, which is not sent to the server.
+func dashboardURL(cfg *Config) string {
+	uri := apiURL(cfg, "/ui/", "")
+	if cfg.ApiToken != "" {
+		uri.Fragment = "token=" + cfg.ApiToken
+	}
+
+	return uri.String()
+}
+
+// publicURL returns the URL of the path as linked by the documents of the API (e.g. the feed).
+// It is based on the public URL of the API server, if configured (e.g. behind a reverse proxy), otherwise on its listener,
+// so that the links do not depend on the Host header sent by the client.
+func publicURL(cfg *Config, path string) string {
+	if cfg.ApiPublicURL != "" {
+		return strings.TrimSuffix(cfg.ApiPublicURL, "/") + path
+	}
+
+	uri := apiURL(cfg, path, "")
+	if cfg.ApiSocket != "" {
+		uri.Host = "localhost"
+	}
+
+	return uri.String()
+}
+
+// apiHost returns the host and port clients reach the API server at.
+// Unspecified bind addresses (listening on all interfaces) are reached via localhost.
+func apiHost(cfg *Config) string {
+	host := cfg.ApiBind
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(cfg.ApiPort))
+}
+
+// shellQuote quotes the argument for the shell unless it consists of safe characters only.
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@%+,") == "" {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
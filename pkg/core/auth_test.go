@@ -0,0 +1,186 @@
+package core
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestLoadApiToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets", "api.token")
+
+	generated, err := LoadApiToken(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(generated) != 2*apiTokenSize {
+		t.Errorf("LoadApiToken() = %q, want a token of %d characters", generated, 2*apiTokenSize)
+	}
+
+	loaded, err := LoadApiToken(path)
+	if err != nil || loaded != generated {
+		t.Errorf("LoadApiToken() = %q, %v, want the generated token %q", loaded, err, generated)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("LoadApiToken() saved the token with %v, %v, want permissions 0600", info.Mode(), err)
+	}
+
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadApiToken(path); err == nil {
+		t.Errorf("LoadApiToken() accepted a token file readable by others")
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.token")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadApiToken(empty); err == nil {
+		t.Errorf("LoadApiToken() accepted an empty token file")
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	for _, tt := range []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{"test#1", "", "", http.StatusNoContent},
+		{"test#2", "secret", "", http.StatusUnauthorized},
+		{"test#3", "secret", "Bearer wrong", http.StatusUnauthorized},
+		{"test#4", "secret", "Basic secret", http.StatusUnauthorized},
+		{"test#5", "secret", "Bearer secret", http.StatusNoContent},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/config", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			requireToken(tt.token, next).ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("requireToken() = %d, want %d", w.Code, tt.want)
+			}
+
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("requireToken() did not challenge the client")
+			}
+		})
+	}
+}
+
+func TestApiCommand(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		cfg     Config
+		verb    string
+		query   string
+		payload string
+		want    string
+	}{
+		{"test#1", Config{ApiPort: 44244}, http.MethodGet, "", "",
+			"curl -X GET http://localhost:44244/config"},
+		{"test#2", Config{ApiPort: 44244, ApiBind: "0.0.0.0"}, http.MethodPatch, "refresh=true", "{...}",
+			"curl -X PATCH 'http://localhost:44244/config?refresh=true' -d '{...}'"},
+		{"test#3", Config{ApiPort: 8443, ApiBind: "::1", ApiTLSCert: "/etc/bwc/cert.pem", ApiTLSKey: "/etc/bwc/key.pem"}, http.MethodGet, "", "",
+			"curl -X GET --cacert /etc/bwc/cert.pem 'https://[::1]:8443/config'"},
+		{"test#4", Config{ApiSocket: "/run/bwc.sock", ApiTokenFile: "/home/me/my token"}, http.MethodGet, "", "",
+			`curl -X GET --unix-socket /run/bwc.sock -H "Authorization: Bearer $(cat '/home/me/my token')" http://localhost/config`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := apiCommand(&tt.cfg, tt.verb, "/config", tt.query, tt.payload); got != tt.want {
+				t.Errorf("apiCommand() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDashboardURL(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"test#1", Config{ApiPort: 44244}, "http://localhost:44244/ui/"},
+		{"test#2", Config{ApiPort: 8443, ApiBind: "192.168.1.2", ApiTLSCert: "cert.pem", ApiTLSKey: "key.pem", ApiToken: "c0ffee"},
+			"https://192.168.1.2:8443/ui/#token=c0ffee"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dashboardURL(&tt.cfg); got != tt.want {
+				t.Errorf("dashboardURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublicURL(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"test#1", Config{ApiPort: 44244, ApiBind: "0.0.0.0"}, "http://localhost:44244/feed.atom"},
+		{"test#2", Config{ApiPort: 8443, ApiBind: DefaultApiBind, ApiTLSCert: "cert.pem", ApiTLSKey: "key.pem"}, "https://127.0.0.1:8443/feed.atom"},
+		{"test#3", Config{ApiSocket: "/run/bwc.sock"}, "http://localhost/feed.atom"},
+		{"test#4", Config{ApiPort: 44244, ApiPublicURL: "https://example.org/bwc/"}, "https://example.org/bwc/feed.atom"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := publicURL(&tt.cfg, "/feed.atom"); got != tt.want {
+				t.Errorf("publicURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerListen(t *testing.T) {
+	cfg := &Config{ApiBind: DefaultApiBind}
+	server := NewServer(cfg, setupController(t, cfg, nil))
+	if err := server.listen(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer server.listener.Close()
+
+	if server.GetPort() == 0 || server.listener.Addr().String() != net.JoinHostPort(DefaultApiBind, strconv.Itoa(server.GetPort())) {
+		t.Errorf("listen() = %s, want the loopback address", server.listener.Addr())
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "bwc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg = &Config{ApiSocket: filepath.Join(dir, "api.sock")}
+	socket := NewServer(cfg, setupController(t, cfg, nil))
+	if err := socket.listen(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer socket.listener.Close()
+
+	if info, err := os.Stat(socket.config.ApiSocket); err != nil || info.Mode().Perm() != 0o600 || info.Mode()&os.ModeSocket == 0 {
+		t.Errorf("listen() created %v, %v, want a socket with permissions 0600", info.Mode(), err)
+	}
+}
@@ -0,0 +1,640 @@
+package core
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+)
+
+var AllowedCalendarViews = CalendarViews{CalendarViewAgenda, CalendarViewMonth}
+
+const (
+	CalendarViewAgenda CalendarView = iota
+	CalendarViewMonth
+)
+
+// maxRecurrences limits the number of expanded occurrences of a recurring event.
+const maxRecurrences = 10000
+
+// weekdays maps the RFC 5545 weekday codes to time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// CalendarView represents the layout of the calendar overlay.
+type CalendarView int
+
+// CalendarViews represents a list of calendar views.
+type CalendarViews []CalendarView
+
+// Contains returns true if the view is in the list of views.
+func (vs CalendarViews) Contains(v CalendarView) bool {
+	return slices.Contains(vs, v)
+}
+
+// String returns the string representation of the view.
+func (v CalendarView) String() string {
+	s, ok := map[CalendarView]string{
+		CalendarViewAgenda: "agenda",
+		CalendarViewMonth:  "month",
+	}[v]
+	if !ok {
+		return "Unknown"
+	}
+	return s
+}
+
+type (
+	// Calendar is a list of events parsed from an iCalendar (RFC 5545) file.
+	Calendar struct {
+		Events []CalendarEvent
+	}
+
+	// CalendarEvent is a single event or an occurrence of a recurring event.
+	CalendarEvent struct {
+		UID      string
+		Summary  string
+		Location string
+		Start    time.Time
+		End      time.Time
+		AllDay   bool
+
+		recurrence   *recurrenceRule
+		exceptions   []time.Time
+		recurrenceID time.Time
+	}
+
+	// recurrenceRule is the subset of the RFC 5545 RRULE supported for expansion.
+	recurrenceRule struct {
+		frequency  string
+		interval   int
+		count      int
+		until      time.Time
+		byDay      []weekdayNum
+		byMonthDay []int
+	}
+
+	// weekdayNum is a weekday with an optional ordinal (e.g. -1FR for the last Friday).
+	weekdayNum struct {
+		ordinal int
+		weekday time.Weekday
+	}
+
+	// icsProperty is a content line of an iCalendar file.
+	icsProperty struct {
+		name   string
+		params map[string]string
+		value  string
+	}
+)
+
+// LoadCalendar loads the iCalendar file at the given path.
+func LoadCalendar(path string) (*Calendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseCalendar(f)
+}
+
+// ParseCalendar parses the events of an iCalendar (RFC 5545) stream.
+// Time zones are resolved by their TZID using the IANA time zone database.
+func ParseCalendar(r io.Reader) (*Calendar, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &Calendar{}
+	var event *CalendarEvent
+	var duration time.Duration
+	for _, line := range lines {
+		prop, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case prop.name == "BEGIN" && prop.value == "VEVENT":
+			event, duration = &CalendarEvent{}, 0
+
+		case prop.name == "END" && prop.value == "VEVENT" && event != nil:
+			if event.Start.IsZero() {
+				return nil, fmt.Errorf("event %q without DTSTART", event.Summary)
+			}
+
+			switch {
+			case duration > 0:
+				event.End = event.Start.Add(duration)
+
+			case event.End.IsZero() && event.AllDay:
+				event.End = event.Start.AddDate(0, 0, 1)
+
+			case event.End.IsZero():
+				event.End = event.Start
+
+			}
+
+			calendar.Events = append(calendar.Events, *event)
+			event = nil
+
+		case event == nil:
+			continue
+
+		case prop.name == "UID":
+			event.UID = prop.value
+
+		case prop.name == "SUMMARY":
+			event.Summary = unescapeText(prop.value)
+
+		case prop.name == "LOCATION":
+			event.Location = unescapeText(prop.value)
+
+		case prop.name == "DTSTART":
+			event.Start, event.AllDay, err = parseDateTime(prop)
+
+		case prop.name == "DTEND":
+			event.End, _, err = parseDateTime(prop)
+
+		case prop.name == "DURATION":
+			duration, err = parseDuration(prop.value)
+
+		case prop.name == "RECURRENCE-ID":
+			event.recurrenceID, _, err = parseDateTime(prop)
+
+		case prop.name == "RRULE":
+			event.recurrence, err = parseRecurrenceRule(prop.value)
+
+		case prop.name == "EXDATE":
+			for _, value := range strings.Split(prop.value, ",") {
+				var exception time.Time
+				exception, _, err = parseDateTime(icsProperty{name: prop.name, params: prop.params, value: value})
+				if err != nil {
+					break
+				}
+
+				event.exceptions = append(event.exceptions, exception)
+			}
+
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", prop.name, err)
+		}
+	}
+
+	return calendar, nil
+}
+
+// Between returns the occurrences of all events overlapping the interval [from, to) sorted by their start.
+// Recurring events are expanded and overridden occurrences (RECURRENCE-ID) are replaced.
+func (c *Calendar) Between(from, to time.Time) []CalendarEvent {
+	overridden := make(map[string]bool)
+	for _, event := range c.Events {
+		if !event.recurrenceID.IsZero() {
+			overridden[event.UID+"@"+event.recurrenceID.UTC().Format(time.RFC3339)] = true
+		}
+	}
+
+	var result []CalendarEvent
+	for _, event := range c.Events {
+		for _, start := range event.occurrences(to) {
+			if !event.recurrenceID.IsZero() || !overridden[event.UID+"@"+start.UTC().Format(time.RFC3339)] {
+				occurrence := event
+				occurrence.Start, occurrence.End = start, start.Add(event.End.Sub(event.Start))
+				if occurrence.Start.Before(to) && (occurrence.End.After(from) || occurrence.Start.Equal(from)) {
+					result = append(result, occurrence)
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(result, func(a, b CalendarEvent) int {
+		if a.AllDay != b.AllDay {
+			return map[bool]int{true: -1, false: 1}[a.AllDay]
+		}
+
+		return a.Start.Compare(b.Start)
+	})
+
+	return result
+}
+
+// occurrences returns the start times of the event until the given time.
+func (e CalendarEvent) occurrences(until time.Time) []time.Time {
+	if e.recurrence == nil || !e.recurrenceID.IsZero() {
+		return []time.Time{e.Start}
+	}
+
+	rule := e.recurrence
+	if !rule.until.IsZero() && rule.until.Before(until) {
+		until = rule.until.Add(time.Nanosecond)
+	}
+
+	var result []time.Time
+	count := 0
+	for period := 0; count < maxRecurrences; period++ {
+		candidates := rule.expand(e.Start, period*rule.interval)
+		if len(candidates) == 0 && period > maxRecurrences {
+			break
+		}
+
+		done := false
+		for _, candidate := range candidates {
+			if candidate.Before(e.Start) {
+				continue
+			}
+
+			if !candidate.Before(until) || (rule.count > 0 && count >= rule.count) {
+				done = true
+				break
+			}
+
+			count++
+			if !slices.ContainsFunc(e.exceptions, candidate.Equal) {
+				result = append(result, candidate)
+			}
+		}
+
+		if done {
+			break
+		}
+	}
+
+	return result
+}
+
+// expand returns the candidate start times of the given period (offset in units of the frequency).
+func (rule *recurrenceRule) expand(start time.Time, offset int) []time.Time {
+	hour, minute, second := start.Clock()
+	at := func(year int, month time.Month, day int) (time.Time, bool) {
+		t := time.Date(year, month, day, hour, minute, second, 0, start.Location())
+		return t, t.Day() == day
+	}
+
+	var candidates []time.Time
+	switch rule.frequency {
+	case "DAILY":
+		candidates = append(candidates, start.AddDate(0, 0, offset))
+
+	case "WEEKLY":
+		if len(rule.byDay) == 0 {
+			candidates = append(candidates, start.AddDate(0, 0, 7*offset))
+			break
+		}
+
+		// weeks start on Monday (WKST=MO)
+		monday := start.AddDate(0, 0, 7*offset-(int(start.Weekday())+6)%7)
+		for _, day := range rule.byDay {
+			candidates = append(candidates, monday.AddDate(0, 0, (int(day.weekday)+6)%7))
+		}
+
+	case "MONTHLY":
+		first := time.Date(start.Year(), start.Month()+time.Month(offset), 1, 0, 0, 0, 0, start.Location())
+		switch {
+		case len(rule.byMonthDay) > 0:
+			for _, day := range rule.byMonthDay {
+				if day < 0 {
+					day = daysIn(first.Year(), first.Month()) + day + 1
+				}
+
+				if t, ok := at(first.Year(), first.Month(), day); ok {
+					candidates = append(candidates, t)
+				}
+			}
+
+		case len(rule.byDay) > 0:
+			for _, day := range rule.byDay {
+				for _, d := range weekdaysOfMonth(first.Year(), first.Month(), day) {
+					if t, ok := at(first.Year(), first.Month(), d); ok {
+						candidates = append(candidates, t)
+					}
+				}
+			}
+
+		default:
+			if t, ok := at(first.Year(), first.Month(), start.Day()); ok {
+				candidates = append(candidates, t)
+			}
+
+		}
+
+	case "YEARLY":
+		if t, ok := at(start.Year()+offset, start.Month(), start.Day()); ok {
+			candidates = append(candidates, t)
+		}
+
+	}
+
+	slices.SortFunc(candidates, func(a, b time.Time) int { return a.Compare(b) })
+	return candidates
+}
+
+// DrawCalendar draws today's agenda or the month grid with today highlighted in a box at the given position.
+func (img *Image) DrawCalendar(calendar *Calendar, now time.Time, view CalendarView, position types.Position, fontName string) error {
+	defer renderDuration.ObserveSince(time.Now(), "calendar")
+
+	switch view {
+	case CalendarViewAgenda:
+		return img.drawTextBox(calendar.agenda(now), position, fontName)
+
+	case CalendarViewMonth:
+		text, today := calendar.month(now)
+		return img.drawTextBox(text, position, fontName, today)
+
+	default:
+		return fmt.Errorf("unsupported calendar view: %s, expected any of: %s", view, AllowedCalendarViews)
+
+	}
+}
+
+// agenda returns the list of today's events.
+func (c *Calendar) agenda(now time.Time) string {
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	lines := []string{now.Format("Monday, 2 January 2006")}
+
+	events := c.Between(from, from.AddDate(0, 0, 1))
+	if len(events) == 0 {
+		lines = append(lines, "No events today")
+	}
+
+	for _, event := range events {
+		when := "All day    "
+		if !event.AllDay {
+			when = event.Start.In(now.Location()).Format("15:04") + "-" + event.End.In(now.Location()).Format("15:04")
+		}
+
+		line := when + "  " + event.Summary
+		if event.Location != "" {
+			line += " (" + event.Location + ")"
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// month returns the month grid of the current month and the span of today's cell.
+// Days with events are marked with an asterisk.
+func (c *Calendar) month(now time.Time) (string, textSpan) {
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	busy := make(map[int]bool)
+	for _, event := range c.Between(first, first.AddDate(0, 1, 0)) {
+		for day := event.Start.In(now.Location()); day.Before(event.End) || day.Equal(event.Start); day = day.AddDate(0, 0, 1) {
+			if day.Month() == now.Month() {
+				busy[day.Day()] = true
+			}
+		}
+	}
+
+	title := now.Format("January 2006")
+	width := 7*4 - 1
+	lines := []string{strings.Repeat(" ", (width-len(title))/2) + title, " Mo  Tu  We  Th  Fr  Sa  Su"}
+
+	var today textSpan
+	column := (int(first.Weekday()) + 6) % 7
+	week := strings.Repeat("    ", column)
+	for day := 1; day <= daysIn(now.Year(), now.Month()); day++ {
+		if day == now.Day() {
+			digits := len(strconv.Itoa(day))
+			today = textSpan{line: len(lines), column: len(week) + 3 - digits, length: digits, inverted: true}
+		}
+
+		mark := " "
+		if busy[day] {
+			mark = "*"
+		}
+
+		week += fmt.Sprintf("%3d%s", day, mark)
+		if column = (column + 1) % 7; column == 0 {
+			lines = append(lines, strings.TrimRight(week, " "))
+			week = ""
+		}
+	}
+
+	if week != "" {
+		lines = append(lines, strings.TrimRight(week, " "))
+	}
+
+	return strings.Join(lines, "\n"), today
+}
+
+// daysIn returns the number of days of the given month.
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// parseDateTime parses a DATE or DATE-TIME value respecting the TZID parameter.
+func parseDateTime(prop icsProperty) (time.Time, bool, error) {
+	value := strings.TrimSpace(prop.value)
+	location := time.Local
+	if tzid, ok := prop.params["TZID"]; ok {
+		if loaded, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			location = loaded
+		}
+	}
+
+	if prop.params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, location)
+	return t, false, err
+}
+
+// parseDuration parses a RFC 5545 duration (e.g. P1DT2H30M or PT45M).
+func parseDuration(value string) (time.Duration, error) {
+	matches := regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`).FindStringSubmatch(value)
+	if matches == nil {
+		return 0, fmt.Errorf("malformed duration: %q", value)
+	}
+
+	var duration time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if n, err := strconv.Atoi(matches[i+2]); err == nil {
+			duration += time.Duration(n) * unit
+		}
+	}
+
+	if matches[1] == "-" {
+		duration = -duration
+	}
+
+	return duration, nil
+}
+
+// parseProperty parses a content line of the form NAME;PARAM=VALUE:VALUE.
+func parseProperty(line string) (icsProperty, error) {
+	prop := icsProperty{params: make(map[string]string)}
+
+	// find the colon separating the value, ignoring colons in quoted parameter values
+	quoted, separator := false, -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			separator = i
+			break
+		}
+	}
+
+	if separator < 0 {
+		return prop, fmt.Errorf("malformed content line: %q", line)
+	}
+
+	head := strings.Split(line[:separator], ";")
+	prop.name, prop.value = strings.ToUpper(head[0]), line[separator+1:]
+	for _, param := range head[1:] {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+
+	return prop, nil
+}
+
+// parseRecurrenceRule parses the supported parts of a RRULE value.
+func parseRecurrenceRule(value string) (*recurrenceRule, error) {
+	rule := &recurrenceRule{interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		key, val, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.frequency = strings.ToUpper(val)
+
+		case "INTERVAL":
+			rule.interval, err = strconv.Atoi(val)
+
+		case "COUNT":
+			rule.count, err = strconv.Atoi(val)
+
+		case "UNTIL":
+			rule.until, _, err = parseDateTime(icsProperty{value: val})
+
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				day = strings.ToUpper(strings.TrimSpace(day))
+				if len(day) < 2 {
+					return nil, fmt.Errorf("malformed BYDAY: %q", val)
+				}
+
+				weekday, ok := weekdays[day[len(day)-2:]]
+				if !ok {
+					return nil, fmt.Errorf("unknown weekday: %q", day)
+				}
+
+				ordinal := 0
+				if prefix := day[:len(day)-2]; prefix != "" {
+					if ordinal, err = strconv.Atoi(prefix); err != nil {
+						return nil, err
+					}
+				}
+
+				rule.byDay = append(rule.byDay, weekdayNum{ordinal: ordinal, weekday: weekday})
+			}
+
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil {
+					return nil, err
+				}
+
+				rule.byMonthDay = append(rule.byMonthDay, n)
+			}
+
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s: %w", key, err)
+		}
+	}
+
+	if !slices.Contains([]string{"DAILY", "WEEKLY", "MONTHLY", "YEARLY"}, rule.frequency) {
+		return nil, fmt.Errorf("unsupported frequency: %q", rule.frequency)
+	}
+
+	if rule.interval < 1 {
+		return nil, fmt.Errorf("invalid interval: %d", rule.interval)
+	}
+
+	slices.SortFunc(rule.byDay, func(a, b weekdayNum) int {
+		return cmp.Compare((a.weekday+6)%7, (b.weekday+6)%7)
+	})
+
+	return rule, nil
+}
+
+// unescapeText unescapes a TEXT value.
+func unescapeText(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
+
+// unfoldLines reads the content lines and joins folded lines.
+func unfoldLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// weekdaysOfMonth returns the days of the month matching the weekday (and its ordinal if given).
+func weekdaysOfMonth(year int, month time.Month, day weekdayNum) []int {
+	var days []int
+	for d := 1; d <= daysIn(year, month); d++ {
+		if time.Date(year, month, d, 0, 0, 0, 0, time.UTC).Weekday() == day.weekday {
+			days = append(days, d)
+		}
+	}
+
+	switch {
+	case day.ordinal > 0 && day.ordinal <= len(days):
+		return days[day.ordinal-1 : day.ordinal]
+
+	case day.ordinal < 0 && -day.ordinal <= len(days):
+		return days[len(days)+day.ordinal : len(days)+day.ordinal+1]
+
+	case day.ordinal != 0:
+		return nil
+
+	}
+
+	return days
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/extras"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+)
+
+const testCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Test//Test//EN
+BEGIN:VEVENT
+UID:standup
+SUMMARY:Stand-up
+LOCATION:Room 1\, 2nd floor
+DTSTART;TZID=Europe/Berlin:20240102T093000
+DTEND;TZID=Europe/Berlin:20240102T094500
+RRULE:FREQ=WEEKLY;BYDAY=TU,TH;COUNT=6
+EXDATE;TZID=Europe/Berlin:20240104T093000
+END:VEVENT
+BEGIN:VEVENT
+UID:standup
+RECURRENCE-ID;TZID=Europe/Berlin:20240109T093000
+SUMMARY:Stand-up (moved)
+DTSTART;TZID=Europe/Berlin:20240109T110000
+DURATION:PT15M
+END:VEVENT
+BEGIN:VEVENT
+UID:review
+SUMMARY:Monthly
+  review
+DTSTART:20240105T130000Z
+DTEND:20240105T140000Z
+RRULE:FREQ=MONTHLY;BYDAY=1FR;UNTIL=20240401T000000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:holiday
+SUMMARY:Holiday
+DTSTART;VALUE=DATE:20240109
+END:VEVENT
+BEGIN:VEVENT
+UID:payday
+SUMMARY:Payday
+DTSTART;VALUE=DATE:20240131
+RRULE:FREQ=MONTHLY;BYMONTHDAY=31
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseCalendar(t *testing.T) {
+	calendar, err := ParseCalendar(strings.NewReader(testCalendar))
+	if err != nil {
+		t.Fatalf("ParseCalendar() error = %v", err)
+	}
+
+	if len(calendar.Events) != 5 {
+		t.Fatalf("ParseCalendar() = %d events, want 5", len(calendar.Events))
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	standup := calendar.Events[0]
+	if want := time.Date(2024, 1, 2, 9, 30, 0, 0, berlin); !standup.Start.Equal(want) || standup.End.Sub(standup.Start) != 15*time.Minute {
+		t.Errorf("ParseCalendar().Events[0] = %s - %s, want %s + 15m", standup.Start, standup.End, want)
+	}
+
+	if standup.Location != "Room 1, 2nd floor" {
+		t.Errorf("ParseCalendar().Events[0].Location = %q, want %q", standup.Location, "Room 1, 2nd floor")
+	}
+
+	if calendar.Events[2].Summary != "Monthly review" {
+		t.Errorf("ParseCalendar().Events[2].Summary = %q, want %q", calendar.Events[2].Summary, "Monthly review")
+	}
+
+	if holiday := calendar.Events[3]; !holiday.AllDay || holiday.End.Sub(holiday.Start) != 24*time.Hour {
+		t.Errorf("ParseCalendar().Events[3] = %+v, want all-day event", holiday)
+	}
+
+	for _, tt := range []struct {
+		name string
+		args string
+	}{
+		{"test#1", "BEGIN:VEVENT\nSUMMARY:No start\nEND:VEVENT\n"},
+		{"test#2", "BEGIN:VEVENT\nDTSTART:yesterday\nEND:VEVENT\n"},
+		{"test#3", "BEGIN:VEVENT\nDTSTART:20240101T100000Z\nRRULE:FREQ=SECONDLY\nEND:VEVENT\n"},
+		{"test#4", "BEGIN:VEVENT\nDTSTART:20240101T100000Z\nRRULE:FREQ=WEEKLY;BYDAY=XX\nEND:VEVENT\n"},
+		{"test#5", "BEGIN:VEVENT\nmalformed\nEND:VEVENT\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCalendar(strings.NewReader(tt.args)); err == nil {
+				t.Errorf("ParseCalendar(%q) error = nil, want error", tt.args)
+			}
+		})
+	}
+}
+
+func TestCalendarBetween(t *testing.T) {
+	calendar, err := ParseCalendar(strings.NewReader(testCalendar))
+	if err != nil {
+		t.Fatalf("ParseCalendar() error = %v", err)
+	}
+
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		from, to time.Time
+		want     []string
+	}{
+		{"test#1", day(2024, 1, 1), day(2024, 1, 8), []string{"Stand-up", "Monthly review"}},
+		{"test#2", day(2024, 1, 9), day(2024, 1, 10), []string{"Holiday", "Stand-up (moved)"}},
+		{"test#3", day(2024, 1, 1), day(2024, 2, 1), []string{"Holiday", "Payday", "Stand-up", "Monthly review", "Stand-up (moved)", "Stand-up", "Stand-up", "Stand-up"}},
+		{"test#4", day(2024, 2, 1), day(2024, 3, 1), []string{"Monthly review"}},
+		{"test#5", day(2024, 3, 1), day(2024, 4, 1), []string{"Payday", "Monthly review"}},
+		{"test#6", day(2024, 4, 1), day(2024, 5, 1), nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, event := range calendar.Between(tt.from, tt.to) {
+				got = append(got, event.Summary)
+			}
+
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("Between(%s, %s) = %q, want %q", tt.from.Format(time.DateOnly), tt.to.Format(time.DateOnly), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalendarViews(t *testing.T) {
+	calendar, err := ParseCalendar(strings.NewReader(testCalendar))
+	if err != nil {
+		t.Fatalf("ParseCalendar() error = %v", err)
+	}
+
+	now := time.Date(2024, 1, 9, 8, 0, 0, 0, time.Local)
+	if got := calendar.agenda(now); !strings.HasPrefix(got, "Tuesday, 9 January 2024\nAll day      Holiday\n") {
+		t.Errorf("agenda() = %q, want today's events", got)
+	}
+
+	if got := calendar.agenda(now.AddDate(0, 0, 1)); got != "Wednesday, 10 January 2024\nNo events today" {
+		t.Errorf("agenda() = %q, want no events", got)
+	}
+
+	text, today := calendar.month(now)
+	lines := strings.Split(text, "\n")
+	if len(lines) != 7 || lines[1] != " Mo  Tu  We  Th  Fr  Sa  Su" {
+		t.Fatalf("month() = %q, want month grid", text)
+	}
+
+	if got := lines[today.line][today.column : today.column+today.length]; got != "9" {
+		t.Errorf("month() highlights %q, want %q", got, "9")
+	}
+
+	if !strings.HasPrefix(lines[2], "  1   2*") {
+		t.Errorf("month() = %q, want busy days marked", lines[2])
+	}
+}
+
+func TestDrawCalendar(t *testing.T) {
+	img := SetupTestImage(t)
+	calendar, err := ParseCalendar(strings.NewReader(testCalendar))
+	if err != nil {
+		t.Fatalf("ParseCalendar() error = %v", err)
+	}
+
+	now := time.Date(2024, 1, 9, 8, 0, 0, 0, time.Local)
+	for _, tt := range []struct {
+		name     string
+		view     CalendarView
+		position types.Position
+		wantErr  bool
+	}{
+		{"test#1", CalendarViewAgenda, types.PositionTopRight, false},
+		{"test#2", CalendarViewMonth, types.PositionTopLeft, false},
+		{"test#3", CalendarView(-1), types.PositionTopLeft, true},
+		{"test#4", CalendarViewAgenda, types.Position(-1), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SetupTestImage(t)
+
+			err := got.DrawCalendar(calendar, now, tt.view, tt.position, extras.DefaultFontName)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DrawCalendar(%s, %q) error = %v, wantErr %t", tt.view, tt.position, err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr != got.Equals(img) {
+				t.Errorf("DrawCalendar(%s, %q) = %v, want %v", tt.view, tt.position, got, img)
+			}
+		})
+	}
+}
+
+func TestLoadCalendar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar.ics")
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(testCalendar, "\n", "\r\n")), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	calendar, err := LoadCalendar(path)
+	if err != nil {
+		t.Fatalf("LoadCalendar() error = %v", err)
+	}
+
+	if len(calendar.Events) != 5 {
+		t.Errorf("LoadCalendar() = %d events, want 5", len(calendar.Events))
+	}
+
+	if _, err := LoadCalendar(filepath.Join(t.TempDir(), "missing.ics")); err == nil {
+		t.Error("LoadCalendar() error = nil, want error for missing file")
+	}
+}
@@ -1,6 +1,20 @@
 package core
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/extras"
 	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
 )
 
@@ -8,6 +22,14 @@ const AppName = "Bing Wallpaper Changer"
 
 type Config struct {
 	ApiPort                     int                                             `json:"-"`
+	ApiBind                     string                                          `json:"-"`
+	ApiSocket                   string                                          `json:"-"`
+	ApiTokenFile                string                                          `json:"-"`
+	ApiToken                    string                                          `json:"-"`
+	ApiTLSCert                  string                                          `json:"-"`
+	ApiTLSKey                   string                                          `json:"-"`
+	ApiPublicURL                string                                          `json:"-"`
+	ApiReadyMaxAge              time.Duration                                   `json:"-"`
 	AutoPlayAudio               bool                                            `json:"autoPlayAudio"`
 	Day                         types.Enum[types.Day, types.Days]               `json:"day"`
 	Mode                        types.Enum[Mode, Modes]                         `json:"mode"`
@@ -15,9 +37,39 @@ type Config struct {
 	Resolution                  types.Enum[types.Resolution, types.Resolutions] `json:"resolution"`
 	DrawDescription             bool                                            `json:"drawDescription"`
 	DrawQRCode                  bool                                            `json:"drawQRCode"`
+	QRCodePosition              types.Enum[types.Position, types.Positions]     `json:"qrCodePosition"`
+	QRCodeSize                  types.Percent                                   `json:"qrCodeSize"`
+	QRCodeLevel                 types.Enum[QRCodeLevel, QRCodeLevels]           `json:"qrCodeLevel"`
+	QRCodeForeground            types.Color                                     `json:"qrCodeForeground"`
+	QRCodeBackground            types.Color                                     `json:"qrCodeBackground"`
+	QRCodeFade                  types.Percent                                   `json:"qrCodeFade"`
+	QRCodePayload               string                                          `json:"qrCodePayload"`
 	Watermark                   string                                          `json:"watermark"`
+	WatermarkMode               types.Enum[WatermarkMode, WatermarkModes]       `json:"watermarkMode"`
+	WatermarkPosition           types.Enum[types.Position, types.Positions]     `json:"watermarkPosition"`
+	WatermarkOpacity            types.Percent                                   `json:"watermarkOpacity"`
+	WatermarkSize               types.Percent                                   `json:"watermarkSize"`
+	WatermarkMargin             int                                             `json:"watermarkMargin"`
+	WatermarkRotation           float64                                         `json:"watermarkRotation"`
+	TextWatermark               string                                          `json:"textWatermark"`
+	TextWatermarkFont           string                                          `json:"textWatermarkFont"`
+	TextWatermarkSize           float64                                         `json:"textWatermarkSize"`
+	TextWatermarkColor          types.Color                                     `json:"textWatermarkColor"`
+	TextWatermarkOpacity        types.Percent                                   `json:"textWatermarkOpacity"`
+	TextWatermarkRotation       float64                                         `json:"textWatermarkRotation"`
+	TextWatermarkPosition       types.Enum[types.Position, types.Positions]     `json:"textWatermarkPosition"`
+	TextWatermarkRepeat         bool                                            `json:"textWatermarkRepeat"`
 	DownloadOnly                bool                                            `json:"downloadOnly"`
 	DownloadDirectory           string                                          `json:"downloadDirectory"`
+	FileNameTemplate            string                                          `json:"fileNameTemplate"`
+	RetentionKeepLast           int                                             `json:"retentionKeepLast"`
+	RetentionKeepDays           int                                             `json:"retentionKeepDays"`
+	RetentionMaxSize            float64                                         `json:"retentionMaxSize"`
+	OutputFormat                types.Enum[OutputFormat, OutputFormats]         `json:"outputFormat"`
+	JPEGQuality                 int                                             `json:"jpegQuality"`
+	PNGCompression              types.Enum[PNGCompression, PNGCompressions]     `json:"pngCompression"`
+	KeepOriginal                bool                                            `json:"keepOriginal"`
+	WriteSidecar                bool                                            `json:"writeSidecar"`
 	RotateCounterClockwise      bool                                            `json:"rotateCounterClockwise"`
 	GoogleAppCredentials        string                                          `json:"googleAppCredentials"`
 	FuriganaApiAppId            string                                          `json:"furiganaApiAppId"`
@@ -26,4 +78,255 @@ type Config struct {
 	Daemon                      bool                                            `json:"daemon"`
 	Debug                       bool                                            `json:"debug"`
 	DimImage                    types.Percent                                   `json:"dimImage"`
+	ExtractPalette              bool                                            `json:"extractPalette"`
+	PaletteSize                 int                                             `json:"paletteSize"`
+	PostSetHook                 string                                          `json:"-"`
+	DrawSystemInfo              bool                                            `json:"drawSystemInfo"`
+	SystemInfoPosition          types.Enum[types.Position, types.Positions]     `json:"systemInfoPosition"`
+	SystemInfoTemplate          string                                          `json:"systemInfoTemplate"`
+	SystemInfoTemplateFile      string                                          `json:"systemInfoTemplateFile"`
+	SystemInfoOwner             string                                          `json:"systemInfoOwner"`
+	CalendarFile                string                                          `json:"calendarFile"`
+	CalendarView                types.Enum[CalendarView, CalendarViews]         `json:"calendarView"`
+	CalendarPosition            types.Enum[types.Position, types.Positions]     `json:"calendarPosition"`
+	DrawWeather                 bool                                            `json:"drawWeather"`
+	WeatherLatitude             float64                                         `json:"weatherLatitude"`
+	WeatherLongitude            float64                                         `json:"weatherLongitude"`
+	WeatherLocationName         string                                          `json:"weatherLocationName"`
+	WeatherForecastDays         int                                             `json:"weatherForecastDays"`
+	WeatherPosition             types.Enum[types.Position, types.Positions]     `json:"weatherPosition"`
+	WeatherCacheTTL             time.Duration                                   `json:"weatherCacheTTL"`
+	Messages                    Messages                                        `json:"messages"`
+	OverlayRefreshInterval      time.Duration                                   `json:"overlayRefreshInterval"`
+}
+
+// readOnlyConfigFields are the fields of the config document, which cannot be changed at runtime.
+var readOnlyConfigFields = []string{"daemon"}
+
+// configValidators validate the fields of the config document by their JSON name.
+// Enums and percentages are validated when decoded (see types.Enum and types.Percent).
+var configValidators = map[string]func(*Config) error{
+	"downloadDirectory":      func(c *Config) error { return validateDirectory(c.DownloadDirectory) },
+	"googleAppCredentials":   func(c *Config) error { return validateFile(c.GoogleAppCredentials, true) },
+	"calendarFile":           func(c *Config) error { return validateFile(c.CalendarFile, true) },
+	"systemInfoTemplateFile": func(c *Config) error { return validateFile(c.SystemInfoTemplateFile, true) },
+	"watermark": func(c *Config) error {
+		if extras.EmbeddedWatermarks[c.Watermark] != nil {
+			return nil
+		}
+		return validateFile(c.Watermark, true)
+	},
+	"textWatermarkFont": func(c *Config) error {
+		if extras.EmbeddedFonts[c.TextWatermarkFont] != nil {
+			return nil
+		}
+		return validateFile(c.TextWatermarkFont, false)
+	},
+	"jpegQuality":         func(c *Config) error { return validateRange(c.JPEGQuality, 1, 100) },
+	"paletteSize":         func(c *Config) error { return validateRange(c.PaletteSize, MinPaletteSize, MaxPaletteSize) },
+	"weatherForecastDays": func(c *Config) error { return validateRange(c.WeatherForecastDays, 1, MaxWeatherForecastDays) },
+	"weatherLatitude":     func(c *Config) error { return validateRange(c.WeatherLatitude, -90, 90) },
+	"weatherLongitude":    func(c *Config) error { return validateRange(c.WeatherLongitude, -180, 180) },
+	"watermarkMargin":     func(c *Config) error { return validateRange(c.WatermarkMargin, 0, math.MaxInt) },
+	"retentionKeepLast":   func(c *Config) error { return validateRange(c.RetentionKeepLast, 0, math.MaxInt) },
+	"retentionKeepDays":   func(c *Config) error { return validateRange(c.RetentionKeepDays, 0, math.MaxInt) },
+	"retentionMaxSize":    func(c *Config) error { return validateRange(c.RetentionMaxSize, 0, math.MaxFloat64) },
+	"textWatermarkSize":   func(c *Config) error { return validateRange(c.TextWatermarkSize, 1, MaxTextWatermarkSize) },
+	"weatherCacheTTL":     func(c *Config) error { return validateRange(c.WeatherCacheTTL, 0, math.MaxInt64) },
+	"overlayRefreshInterval": func(c *Config) error {
+		return validateRange(c.OverlayRefreshInterval, 0, math.MaxInt64)
+	},
+	"fileNameTemplate": func(c *Config) error {
+		sample := &Image{Image: image.NewRGBA(image.Rect(0, 0, 1, 1)), ID: "OHR.Sample", Title: "Sample"}
+		_, err := sample.FileName(c.FileNameTemplate)
+		return err
+	},
+	"messages": func(c *Config) error {
+		for i, message := range c.Messages {
+			if message.Text == "" && message.Source == "" {
+				return fmt.Errorf("message %d: either text or source is required", i)
+			}
+
+			if !types.AllowedPositions.Contains(message.Position) {
+				return fmt.Errorf("message %d: invalid position: %d, expected any of: %s", i, message.Position, types.AllowedPositions)
+			}
+		}
+		return nil
+	},
+}
+
+// ConfigFieldError is the error of a field of a config patch.
+type ConfigFieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// Patch returns a copy of the config with the fields of the patch applied.
+// The fields are decoded into copies of their current values, so that enums are validated against their allowed values,
+// except for lists, which are decoded into fresh values, so that a rejected patch leaves the config unchanged.
+// Changed fields are validated by the rules of their type and configValidators, read-only fields cannot be changed.
+// Messages cannot read their text from sources other than the ones configured already (see Messages.checkSources).
+// Unknown fields are rejected. The errors are returned per field.
+func (c *Config) Patch(patch map[string]json.RawMessage) (*Config, []ConfigFieldError) {
+	patched := *c
+	target := reflect.ValueOf(&patched).Elem()
+
+	var errs []ConfigFieldError
+	fail := func(field string, err error) { errs = append(errs, ConfigFieldError{Field: field, Error: err.Error()}) }
+
+	names := make([]string, 0, len(patch))
+	for name := range patch {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		index, ok := configFieldIndex()[name]
+		if !ok {
+			fail(name, fmt.Errorf("unknown field"))
+			continue
+		}
+
+		// lists can be removed, i.e. emptied, other fields cannot
+		if kind := target.Field(index).Kind(); string(patch[name]) == "null" && kind != reflect.Slice && kind != reflect.Map {
+			fail(name, fmt.Errorf("field cannot be removed"))
+			continue
+		}
+
+		// lists are replaced as a whole and decoded into a fresh value, since decoding into the current one would modify the live config
+		value := reflect.New(target.Field(index).Type())
+		if kind := target.Field(index).Kind(); kind != reflect.Slice && kind != reflect.Map {
+			value.Elem().Set(target.Field(index))
+		}
+
+		if err := json.Unmarshal(patch[name], value.Interface()); err != nil {
+			fail(name, err)
+			continue
+		}
+
+		target.Field(index).Set(value.Elem())
+	}
+
+	for name := range diffConfig(c, &patched) {
+		if slices.Contains(readOnlyConfigFields, name) {
+			fail(name, fmt.Errorf("read-only field"))
+		} else if validate, ok := configValidators[name]; ok {
+			if err := validate(&patched); err != nil {
+				fail(name, err)
+			} else if name == "messages" {
+				if err := patched.Messages.checkSources(c.Messages); err != nil {
+					fail(name, err)
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(errs, func(a, b ConfigFieldError) int { return strings.Compare(a.Field, b.Field) })
+	return &patched, errs
+}
+
+// ETag returns the entity tag of the config document, it changes whenever any field of the document changes.
+func (c *Config) ETag() string {
+	raw, _ := json.Marshal(c)
+	return configETag(raw)
+}
+
+// configETag returns the entity tag of the encoded config document.
+func configETag(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Document returns the config document decoded as generic JSON object, which can be patched (see mergePatch and applyJSONPatch).
+func (c *Config) Document() (map[string]any, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := decodeDocument(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return document.(map[string]any), nil
+}
+
+// PatchDocument returns a copy of the config with the fields of the patched document, which differ from the config, applied.
+// Removed fields are applied as null. See Patch for the validation.
+func (c *Config) PatchDocument(document map[string]any) (*Config, []ConfigFieldError) {
+	original, err := c.Document()
+	if err != nil {
+		return nil, []ConfigFieldError{{Error: err.Error()}}
+	}
+
+	patch := make(map[string]json.RawMessage)
+	for name, value := range document {
+		if !equalDocuments(original[name], value) {
+			patch[name], _ = json.Marshal(value)
+		}
+	}
+
+	for name := range original {
+		if _, ok := document[name]; !ok {
+			patch[name] = json.RawMessage("null")
+		}
+	}
+
+	return c.Patch(patch)
+}
+
+// configFieldIndex maps the JSON names of the fields of the config document to their index.
+var configFieldIndex = sync.OnceValue(func() map[string]int {
+	indices := make(map[string]int)
+	configType := reflect.TypeFor[Config]()
+	for i := range configType.NumField() {
+		name, _, _ := strings.Cut(configType.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			indices[name] = i
+		}
+	}
+
+	return indices
+})
+
+// validateRange checks that the value is within the inclusive range.
+func validateRange[T int | float64 | time.Duration](value, lower, upper T) error {
+	if value < lower || value > upper {
+		return fmt.Errorf("value %v out of range", value)
+	}
+
+	return nil
+}
+
+// validateDirectory checks that the path is an existing directory.
+func validateDirectory(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", path)
+	}
+
+	return nil
+}
+
+// validateFile checks that the path is an existing file, an empty path is accepted if optional.
+func validateFile(path string, optional bool) error {
+	if path == "" && optional {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("not a file: %s", path)
+	}
+
+	return nil
 }
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+)
+
+// overlayCheckInterval is the interval in which the overlay refresh conditions are checked.
+const overlayCheckInterval = 10 * time.Second
+
+// newController creates the controller of the daemon, which refreshes the wallpaper in jobs (see JobRunner)
+// and publishes the changes on its event bus.
+func newController(cfg *Config, execute func(*Config, *Job) *Image) *Controller {
+	c := &Controller{img: &Image{}, cfg: cfg, execute: execute, events: NewEventBus()}
+	c.jobs = NewJobRunner(c.refresh, c.events)
+	return c
+}
+
+// update executes the refresh job with a copy of the config and updates the current wallpaper.
+// The audio description is played automatically only on startup.
+func (c *Controller) update(job *Job) error {
+	cfg := c.config()
+	autoPlayAudio := cfg.AutoPlayAudio
+	cfg.AutoPlayAudio = false
+	img := c.execute(cfg, job)
+
+	if img == nil {
+		return errNoWallpaper
+	}
+
+	c.img.Update(img)
+	c.events.Publish(EventWallpaperChanged, newWallpaperDocument(img))
+
+	if job.Trigger == JobTriggerStartup && autoPlayAudio {
+		c.playAudio()
+	}
+
+	return nil
+}
+
+// playAudio plays the audio description of the current wallpaper, if any.
+// The start and the end of the playback are published on the event bus.
+func (c *Controller) playAudio() {
+	if c.img == nil || c.img.Audio == nil {
+		return
+	}
+
+	c.events.Publish(EventAudioStarted, map[string]string{"location": c.img.Audio.Location})
+	defer c.events.Publish(EventAudioStopped, map[string]string{"location": c.img.Audio.Location})
+
+	logger.Logger.Println("Playing audio description")
+	if err := c.img.Audio.Play(); err != nil {
+		logger.Logger.Printf("Failed to play audio: %v", err)
+		return
+	}
+
+	logger.Logger.Println("Audio description played")
+}
+
+// config returns a copy of the current config, which can be read without holding the config lock.
+func (c *Controller) config() *Config {
+	c.cfgLock.RLock()
+	defer c.cfgLock.RUnlock()
+
+	snapshot := *c.cfg
+	return &snapshot
+}
+
+// configure applies the edit to a copy of the config under the config lock and returns the changed fields.
+// The config is replaced by the copy unless the edit fails, the changed fields are published on the event bus.
+func (c *Controller) configure(edit func(*Config) error) (map[string]ConfigChange, error) {
+	c.cfgLock.Lock()
+	edited := *c.cfg
+	if err := edit(&edited); err != nil {
+		c.cfgLock.Unlock()
+		return nil, err
+	}
+
+	changes := diffConfig(c.cfg, &edited)
+	*c.cfg = edited
+	c.cfgLock.Unlock()
+
+	if len(changes) > 0 {
+		c.events.Publish(EventConfigChanged, changes)
+	}
+
+	return changes, nil
+}
+
+// watchOverlays redraws the overlays periodically without downloading the wallpaper again.
+// The overlays are also redrawn when the day changes or the calendar file is modified.
+func (c *Controller) watchOverlays(ctx context.Context) {
+	ticker := time.NewTicker(overlayCheckInterval)
+	defer ticker.Stop()
+
+	lastRefresh, lastModified := time.Now(), calendarModTime(c.config().CalendarFile)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case now := <-ticker.C:
+			cfg := c.config()
+			if !cfg.HasOverlays() {
+				continue
+			}
+
+			modified := calendarModTime(cfg.CalendarFile)
+			dayChanged := now.YearDay() != lastRefresh.YearDay() || now.Year() != lastRefresh.Year()
+			elapsed := cfg.OverlayRefreshInterval > 0 && now.Sub(lastRefresh) >= cfg.OverlayRefreshInterval
+			if !elapsed && !dayChanged && modified.Equal(lastModified) {
+				continue
+			}
+
+			lastRefresh, lastModified = now, modified
+			logger.Logger.Debug("Refreshing overlays")
+			if err := c.img.RefreshOverlays(cfg); err != nil {
+				logger.Logger.Printf("Failed to refresh overlays: %v", err)
+			}
+
+		}
+	}
+}
+
+// calendarModTime returns the modification time of the calendar file or the zero time if not available.
+func calendarModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -3,7 +3,9 @@ package core
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"image"
 	"io"
 	"math/rand/v2"
 	"net/http"
@@ -34,6 +36,7 @@ const (
 	defaultBingUrl        = "https://www.bing.com"
 	defaultFuriganaApiUrl = "https://labs.goo.ne.jp"
 	defaultJishoOrgUrl    = "https://jisho.org"
+	defaultOpenMeteoUrl   = "https://api.open-meteo.com"
 )
 
 type (
@@ -42,7 +45,9 @@ type (
 		furiganaApiAppId            string
 		furiganaApiUrl              string
 		googleAppCredentials        string
+		job                         *Job
 		jishoOrgUrl                 string
+		openMeteoUrl                string
 		useGoogleText2SpeechService bool
 		useGoogleTranslateService   bool
 	}
@@ -50,11 +55,12 @@ type (
 	crawlerConfigOption func(*crawlerConfig)
 )
 
-// configuration for the Bing, Goo Labs APIs and Google Cloud Translation Service.
+// configuration for the Bing, Goo Labs, Open-Meteo APIs and Google Cloud Translation Service.
 var cfg = crawlerConfig{
 	bingUrl:        defaultBingUrl,
 	furiganaApiUrl: defaultFuriganaApiUrl,
 	jishoOrgUrl:    defaultJishoOrgUrl,
+	openMeteoUrl:   defaultOpenMeteoUrl,
 }
 
 // retryablehttp client configuration.
@@ -70,6 +76,14 @@ var client = func() *retryablehttp.Client {
 		return nil
 	}
 
+	// record the status codes and durations of the requests and the retries
+	client.HTTPClient.Transport = metricsTransport{next: client.HTTPClient.Transport}
+	client.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, attempt int) {
+		if attempt > 0 {
+			httpRequestRetries.Add(1, req.URL.Host)
+		}
+	}
+
 	client.HTTPClient.Timeout = time.Second * 5
 	client.RetryWaitMax = time.Millisecond * 1500
 	client.RetryWaitMin = time.Millisecond * 500
@@ -345,52 +359,16 @@ func DownloadAndDecode(day types.Day, region types.Region, resolution types.Reso
 		opt(&cfg)
 	}
 
-	jsonRaw, err := readResponse(client.Get(cfg.bingUrl + "/HPImageArchive.aspx?" + url.Values{
-		"format": {"js"},
-		"idx":    {fmt.Sprintf("%d", day)},
-		"n":      {"1"},
-		"mkt":    {region.String()},
-	}.Encode()))
-	if err != nil {
-		return nil, err
-	}
-
-	path := gjson.GetBytes(jsonRaw, "images.0.url").String()
-	if path == "" {
-		return nil, fmt.Errorf("no image found in response: %s", jsonRaw)
-	}
-
-	path = regexp.MustCompile(`_(?:\d+x\d+|UHD)`).ReplaceAllString(path, "_"+resolution.BingFormat())
-	parsedRequestUri, err := url.ParseRequestURI(path)
-	if err != nil {
-		return nil, err
-	}
-
-	remoteHostUrl, err := url.Parse(cfg.bingUrl)
-	if err != nil {
-		return nil, err
-	}
-
-	parsedRequestUri.Host = remoteHostUrl.Host
-	parsedRequestUri.Scheme = remoteHostUrl.Scheme
-
-	decoder, err := getDecoder(parsedRequestUri.Query().Get("id"))
-	if err != nil {
+	var jsonRaw, content []byte
+	var downloadURL *url.URL
+	var img image.Image
+	if err := cfg.job.Step(JobStepFetch, func() (err error) {
+		jsonRaw, downloadURL, content, img, err = fetchWallpaper(day, region, resolution)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
-	content, err := readResponse(client.Get(parsedRequestUri.String()))
-	if err != nil {
-		return nil, err
-	}
-
-	img, err := decoder(bytes.NewReader(content))
-
-	imgBounds := img.Bounds()
-	if imgBounds.Dx() != resolution.Width || imgBounds.Dy() != resolution.Height {
-		return nil, fmt.Errorf("expected resolution: %s, got: %s", resolution, imgBounds.Size())
-	}
-
 	title := gjson.GetBytes(jsonRaw, "images.0.title").String()
 	copyright := gjson.GetBytes(jsonRaw, "images.0.copyright").String()
 	description := title + ", " + copyright
@@ -398,32 +376,49 @@ func DownloadAndDecode(day types.Day, region types.Region, resolution types.Reso
 	var translated string
 	if region.IsAny(types.NonEnglishRegions...) && cfg.useGoogleTranslateService && cfg.googleAppCredentials != "" {
 		logger.Logger.Println("Using Google Cloud Translation Service for description translation from", region.String(), "to", types.RegionUnitedStates.String())
-		translated, err = translateDescription(description, region.String(), types.RegionUnitedStates.String())
-		if err != nil {
+		if err := cfg.job.Step(JobStepTranslate, func() error {
+			return callProvider(JobStepTranslate, "google", func() (err error) {
+				translated, err = translateDescription(description, region.String(), types.RegionUnitedStates.String())
+				return err
+			})
+		}); err != nil {
 			logger.Logger.Printf("failed to translate description: %v\n", err)
 		}
 	}
 
+	var furigana string
 	if region == types.RegionJapan {
 		var annotated string
-		var err error
-		if cfg.furiganaApiAppId != "" {
-			logger.Logger.Println("Using Goo Labs API for Furigana conversion")
-			annotated, err = furiganizeByGooLabsApi(description)
-		} else {
-			logger.Logger.Println("Using Jisho.org for Furigana conversion")
-			annotated, err = furiganizeByJishoOrg(description)
-		}
+		err := cfg.job.Step(JobStepFurigana, func() (err error) {
+			if cfg.furiganaApiAppId != "" {
+				logger.Logger.Println("Using Goo Labs API for Furigana conversion")
+				err = callProvider(JobStepFurigana, "goolabs", func() (err error) {
+					annotated, err = furiganizeByGooLabsApi(description)
+					return err
+				})
+			} else {
+				logger.Logger.Println("Using Jisho.org for Furigana conversion")
+				err = callProvider(JobStepFurigana, "jisho", func() (err error) {
+					annotated, err = furiganizeByJishoOrg(description)
+					return err
+				})
+			}
 
-		if err != nil {
-			logger.Logger.Printf("failed to annotate description: %v, falling back to Kakasi\n", err)
-			annotated, err = furiganizeByKakasi(description)
-		}
+			if err != nil {
+				logger.Logger.Printf("failed to annotate description: %v, falling back to Kakasi\n", err)
+				err = callProvider(JobStepFurigana, "kakasi", func() (err error) {
+					annotated, err = furiganizeByKakasi(description)
+					return err
+				})
+			}
+
+			return err
+		})
 
 		if err != nil {
 			logger.Logger.Printf("failed to annotate description: %v\n", err)
 		} else {
-			description = annotated
+			description, furigana = annotated, annotated
 		}
 	}
 
@@ -435,26 +430,102 @@ func DownloadAndDecode(day types.Day, region types.Region, resolution types.Reso
 	var audio *Audio
 	if cfg.useGoogleText2SpeechService {
 		logger.Logger.Println("Using Google Cloud Text-to-Speech Service for audio generation")
-		audio, err = speakDescription(title+", "+copyright, types.Map[types.Region, types.Region]{
-			types.RegionBrazil:        types.Region{Country: "PT", LanguageCode: "pt"},
-			types.RegionCanadaEnglish: types.RegionUnitedStates,
-			types.RegionCanadaFrench:  types.RegionFrance,
-			types.RegionIndia:         types.RegionUnitedKingdom,
-			types.RegionNewZealand:    types.RegionUnitedKingdom,
-			types.RegionOther:         types.RegionUnitedStates,
-		}.Get(region, region).String())
-		if err != nil {
+		if err := cfg.job.Step(JobStepTTS, func() error {
+			return callProvider(JobStepTTS, "google", func() (err error) {
+				audio, err = speakDescription(title+", "+copyright, types.Map[types.Region, types.Region]{
+					types.RegionBrazil:        types.Region{Country: "PT", LanguageCode: "pt"},
+					types.RegionCanadaEnglish: types.RegionUnitedStates,
+					types.RegionCanadaFrench:  types.RegionFrance,
+					types.RegionIndia:         types.RegionUnitedKingdom,
+					types.RegionNewZealand:    types.RegionUnitedKingdom,
+					types.RegionOther:         types.RegionUnitedStates,
+				}.Get(region, region).String())
+				return err
+			})
+		}); err != nil {
 			logger.Logger.Printf("failed to generate audio stream: %v\n", err)
 		}
 	}
 
+	date, _ := time.Parse("20060102", gjson.GetBytes(jsonRaw, "images.0.startdate").String())
+	var id string
+	if parsedBase, err := url.Parse(gjson.GetBytes(jsonRaw, "images.0.urlbase").String()); err == nil {
+		id = parsedBase.Query().Get("id")
+	}
+
 	return &Image{
 		Audio:       audio,
+		Title:       title,
+		Copyright:   copyright,
 		Description: strings.Join(lines, "\n"),
+		Translation: translated,
+		Furigana:    furigana,
+		Region:      region,
+		ID:          id,
+		Date:        date,
 		Image:       img,
-		DownloadURL: parsedRequestUri.String(),
+		original:    content,
+		Bing:        json.RawMessage(gjson.GetBytes(jsonRaw, "images.0").Raw),
+		DownloadURL: downloadURL.String(),
 		SearchURL:   gjson.GetBytes(jsonRaw, "images.0.copyrightlink").String(),
-	}, err
+	}, nil
+}
+
+// fetchWallpaper requests the metadata of the wallpaper from the Bing API, downloads the image in the given resolution and decodes it.
+func fetchWallpaper(day types.Day, region types.Region, resolution types.Resolution) ([]byte, *url.URL, []byte, image.Image, error) {
+	jsonRaw, err := readResponse(client.Get(cfg.bingUrl + "/HPImageArchive.aspx?" + url.Values{
+		"format": {"js"},
+		"idx":    {fmt.Sprintf("%d", day)},
+		"n":      {"1"},
+		"mkt":    {region.String()},
+	}.Encode()))
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	path := gjson.GetBytes(jsonRaw, "images.0.url").String()
+	if path == "" {
+		return nil, nil, nil, nil, fmt.Errorf("no image found in response: %s", jsonRaw)
+	}
+
+	path = regexp.MustCompile(`_(?:\d+x\d+|UHD)`).ReplaceAllString(path, "_"+resolution.BingFormat())
+	parsedRequestUri, err := url.ParseRequestURI(path)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	remoteHostUrl, err := url.Parse(cfg.bingUrl)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	parsedRequestUri.Host = remoteHostUrl.Host
+	parsedRequestUri.Scheme = remoteHostUrl.Scheme
+
+	decoder, err := getDecoder(parsedRequestUri.Query().Get("id"))
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	content, err := readResponse(client.Get(parsedRequestUri.String()))
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	downloadBytes.Add(float64(len(content)))
+
+	start := time.Now()
+	img, err := decoder(bytes.NewReader(content))
+	decodeDuration.ObserveSince(start)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	imgBounds := img.Bounds()
+	if imgBounds.Dx() != resolution.Width || imgBounds.Dy() != resolution.Height {
+		return nil, nil, nil, nil, fmt.Errorf("expected resolution: %s, got: %s", resolution, imgBounds.Size())
+	}
+
+	return jsonRaw, parsedRequestUri, content, img, nil
 }
 
 func WithGoogleAppCredentials(credentials string) crawlerConfigOption {
@@ -463,6 +534,13 @@ func WithGoogleAppCredentials(credentials string) crawlerConfigOption {
 	}
 }
 
+// WithJob records the steps of the download in the refresh job, nil disables the recording.
+func WithJob(job *Job) crawlerConfigOption {
+	return func(cfg *crawlerConfig) {
+		cfg.job = job
+	}
+}
+
 func WithFuriganaApiAppId(appId string) crawlerConfigOption {
 	return func(cfg *crawlerConfig) {
 		cfg.furiganaApiAppId = appId
@@ -2,6 +2,7 @@ package core
 
 import (
 	"testing"
+	"time"
 
 	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
 )
@@ -88,6 +89,10 @@ func TestDownloadAndDecode(t *testing.T) {
 			}
 
 			t.Logf("Fetched wallpaper: %#v", got)
+
+			if FromMock(t) && (got.ID != "OHR.FolegandrosGreece_DE-DE3993128464" || got.Title != "Paradies auf Griechisch" || !got.Date.Equal(time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC))) {
+				t.Errorf("DownloadAndDecode() = %q, %q, %s, want metadata of the mocked wallpaper", got.ID, got.Title, got.Date)
+			}
 		})
 	}
 }
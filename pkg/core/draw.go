@@ -7,7 +7,11 @@ import (
 	"io"
 	"math"
 	"os"
+	"os/user"
+	"slices"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/fogleman/gg"
 	"github.com/sarumaj/bing-wallpaper-changer/pkg/extras"
@@ -18,8 +22,190 @@ import (
 	"golang.org/x/image/font/opentype"
 )
 
+var AllowedWatermarkModes = WatermarkModes{
+	WatermarkModeStretch,
+	WatermarkModeFit,
+	WatermarkModeFill,
+	WatermarkModeCenter,
+	WatermarkModeCorner,
+	WatermarkModeTile,
+}
+
+var AllowedQRCodeLevels = QRCodeLevels{
+	QRCodeLevelLow,
+	QRCodeLevelMedium,
+	QRCodeLevelHigh,
+	QRCodeLevelHighest,
+}
+
+const (
+	QRCodeLevelLow QRCodeLevel = iota
+	QRCodeLevelMedium
+	QRCodeLevelHigh
+	QRCodeLevelHighest
+)
+
+const DefaultQRCodePayload = "{{.SearchURL}}"
+
+const (
+	// MaxTextWatermarkSize limits the font size of the text watermark in points,
+	// it is further limited to a quarter of the height of the wallpaper when drawn.
+	MaxTextWatermarkSize = 512
+	// maxTextWatermarkRepeats limits the number of repetitions of a repeated text watermark,
+	// the gaps between the repetitions of a small text are widened instead.
+	maxTextWatermarkRepeats = 1000
+)
+
+const (
+	WatermarkModeStretch WatermarkMode = iota
+	WatermarkModeFit
+	WatermarkModeFill
+	WatermarkModeCenter
+	WatermarkModeCorner
+	WatermarkModeTile
+)
+
+// QRCodeLevel represents the error correction level of the QR code.
+type QRCodeLevel int
+
+// QRCodeLevels represents a list of error correction levels.
+type QRCodeLevels []QRCodeLevel
+
+// QRCodeOptions represents the options for drawing a QR code.
+type QRCodeOptions struct {
+	// Position is the position of the QR code.
+	Position types.Position
+	// Size is the size of the QR code relative to the height of the wallpaper (0.0 to 100.0).
+	Size types.Percent
+	// Level is the error correction level.
+	Level QRCodeLevel
+	// Foreground is the color of the modules.
+	Foreground types.Color
+	// Background is the color of the background.
+	Background types.Color
+	// Fade is the width of the transparent fade at the edges relative to the QR code (0.0 to 100.0).
+	Fade types.Percent
+	// Payload is the text/template of the encoded content, executed with the image, e.g. {{.DownloadURL}}.
+	Payload string
+}
+
+// WatermarkMode represents the placement mode of the watermark.
+type WatermarkMode int
+
+// WatermarkModes represents a list of watermark modes.
+type WatermarkModes []WatermarkMode
+
+// WatermarkOptions represents the options for drawing a watermark.
+type WatermarkOptions struct {
+	// Mode is the placement mode of the watermark.
+	Mode WatermarkMode
+	// Position is the position of the watermark in corner mode.
+	Position types.Position
+	// Opacity is the opacity of the watermark (0.0 to 100.0).
+	Opacity types.Percent
+	// Size is the size of the watermark relative to the wallpaper in center, corner and tile mode (0.0 to 100.0).
+	Size types.Percent
+	// Margin is the distance in pixels to the edges of the wallpaper and between tiles.
+	Margin int
+	// Rotation is the clockwise rotation of the watermark in degrees.
+	Rotation float64
+	// RotateCounterClockwise rotates portrait watermarks counter-clockwise in stretch mode.
+	RotateCounterClockwise bool
+}
+
+// TextWatermarkOptions represents the options for drawing a text watermark.
+type TextWatermarkOptions struct {
+	// Font is the name of an embedded font or the path to a font file.
+	Font string
+	// Size is the font size in points (1 to MaxTextWatermarkSize), at most a quarter of the height of the wallpaper.
+	Size float64
+	// Color is the color of the text.
+	Color types.Color
+	// Opacity is the opacity of the text (0.0 to 100.0).
+	Opacity types.Percent
+	// Rotation is the clockwise rotation of the text in degrees.
+	Rotation float64
+	// Position is the position of the text, ignored if the text is repeated.
+	Position types.Position
+	// Margin is the distance in pixels to the edges of the wallpaper and between repetitions.
+	Margin int
+	// Repeat repeats the text diagonally across the wallpaper.
+	Repeat bool
+}
+
+// textSpan marks a range of characters in a line of a text box and how they are styled.
+type textSpan struct {
+	line, column, length int
+	inverted             bool        // drawn black on a white background
+	bold                 bool        // drawn with a faux bold stroke
+	color                color.Color // overrides the white text color
+	icon                 image.Image // drawn in place of the (blank) characters
+}
+
+// Contains returns true if the mode is in the list of modes.
+func (ms WatermarkModes) Contains(m WatermarkMode) bool {
+	return slices.Contains(ms, m)
+}
+
+// Contains returns true if the level is in the list of levels.
+func (ls QRCodeLevels) Contains(l QRCodeLevel) bool {
+	return slices.Contains(ls, l)
+}
+
+// String returns the string representation of the level.
+func (l QRCodeLevel) String() string {
+	s, ok := map[QRCodeLevel]string{
+		QRCodeLevelLow:     "low",
+		QRCodeLevelMedium:  "medium",
+		QRCodeLevelHigh:    "high",
+		QRCodeLevelHighest: "highest",
+	}[l]
+	if !ok {
+		return "Unknown"
+	}
+	return s
+}
+
+// recoveryLevel returns the recovery level of the QR code encoder.
+func (l QRCodeLevel) recoveryLevel() qrcode.RecoveryLevel {
+	return map[QRCodeLevel]qrcode.RecoveryLevel{
+		QRCodeLevelLow:     qrcode.Low,
+		QRCodeLevelMedium:  qrcode.Medium,
+		QRCodeLevelHigh:    qrcode.High,
+		QRCodeLevelHighest: qrcode.Highest,
+	}[l]
+}
+
+// String returns the string representation of the modes.
+func (ms WatermarkModes) String() string {
+	var s []string
+	for _, v := range ms {
+		s = append(s, v.String())
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// String returns the string representation of the mode.
+func (m WatermarkMode) String() string {
+	s, ok := map[WatermarkMode]string{
+		WatermarkModeStretch: "stretch",
+		WatermarkModeFit:     "fit",
+		WatermarkModeFill:    "fill",
+		WatermarkModeCenter:  "center",
+		WatermarkModeCorner:  "corner",
+		WatermarkModeTile:    "tile",
+	}[m]
+	if !ok {
+		return "Unknown"
+	}
+	return s
+}
+
 // DrawDescription draws a title onto the given image.
 func (img *Image) DrawDescription(position types.Position, fontName string) error {
+	defer renderDuration.ObserveSince(time.Now(), "description")
+
 	imgBounds := img.Bounds()
 
 	// create a new image with the same dimensions as the original.
@@ -28,28 +214,11 @@ func (img *Image) DrawDescription(position types.Position, fontName string) erro
 	// copy the original image onto the new image.
 	ctx.DrawImage(img.Image, 0, 0)
 
-	fontDataReader, ok := extras.EmbeddedFonts[fontName]
-	if !ok {
-		return fmt.Errorf("unknown font: %s", fontName)
-	}
-	defer fontDataReader.Close()
-
-	data, err := io.ReadAll(fontDataReader)
+	face, err := loadFontFace(fontName, 20)
 	if err != nil {
 		return err
 	}
 
-	// parse font
-	parsed, err := opentype.Parse(data)
-	if err != nil {
-		return fmt.Errorf("error parsing font: %v", err)
-	}
-
-	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: 20, DPI: 72, Hinting: font.HintingNone})
-	if err != nil {
-		return fmt.Errorf("error creating font face: %v", err)
-	}
-
 	// measure text bounding box
 	ctx.SetFontFace(face)
 
@@ -85,16 +254,7 @@ func (img *Image) DrawDescription(position types.Position, fontName string) erro
 
 	}
 
-	// draw outline of the text box with rounded corners
-	ctx.SetColor(color.White)
-	ctx.SetLineWidth(5)
-	ctx.DrawRoundedRectangle(x, y, w, h, r)
-	ctx.Stroke()
-
-	// fill the text box with a semi-transparent black color (opacity of 64%)
-	ctx.SetColor(color.RGBA{R: 0, G: 0, B: 0, A: 164})
-	ctx.DrawRoundedRectangle(x, y, w, h, r)
-	ctx.Fill()
+	drawBox(ctx, x, y, w, h, r)
 
 	// draw the text
 	ctx.SetColor(color.White)
@@ -105,92 +265,99 @@ func (img *Image) DrawDescription(position types.Position, fontName string) erro
 }
 
 // DrawQRCode draws a QR code onto the given image.
-func (img *Image) DrawQRCode(resolution types.Resolution, position types.Position) error {
-	var size int
-	switch resolution {
-	case types.LowDefinition:
-		size = 128
+// The size of the QR code is relative to the height of the image, hence any resolution is supported.
+func (img *Image) DrawQRCode(opts QRCodeOptions) error {
+	defer renderDuration.ObserveSince(time.Now(), "qrcode")
 
-	case types.HighDefinition:
-		size = 164
+	if !AllowedQRCodeLevels.Contains(opts.Level) {
+		return fmt.Errorf("unsupported error correction level: %s, expected any of: %s", opts.Level, AllowedQRCodeLevels)
+	}
 
-	case types.UltraHighDefinition:
-		size = 192
+	payloadTemplate := opts.Payload
+	if payloadTemplate == "" {
+		payloadTemplate = DefaultQRCodePayload
+	}
 
-	default:
-		return fmt.Errorf("unsupported resolution: %s, expected any of: %s", resolution, types.AllowedResolutions)
+	tmpl, err := template.New("payload").Parse(payloadTemplate)
+	if err != nil {
+		return err
+	}
+
+	var payload strings.Builder
+	if err := tmpl.Execute(&payload, img); err != nil {
+		return err
+	}
 
+	if payload.Len() == 0 {
+		return fmt.Errorf("empty QR code payload: %q", payloadTemplate)
 	}
 
-	coder, err := qrcode.New(img.SearchURL, qrcode.Medium)
+	coder, err := qrcode.New(payload.String(), opts.Level.recoveryLevel())
 	if err != nil {
 		return err
 	}
 
+	coder.ForegroundColor, coder.BackgroundColor = opts.Foreground, opts.Background
+
 	imgBounds := img.Bounds()
 	ctx := gg.NewContextForRGBA(image.NewRGBA(imgBounds))
 
 	// copy the original image onto the new image.
 	ctx.DrawImage(img.Image, 0, 0)
 
-	// generate QR code.
-	x_offset, y_offset, qrCodeImg := 50, 50, coder.Image(size)
+	// generate QR code, the encoder enlarges it if the requested size is too small for the payload.
+	qrCodeImg := coder.Image(max(1, int(float64(imgBounds.Dy())*float64(opts.Size.Float32())/100)))
+	size := qrCodeImg.Bounds().Dx()
 
 	// blur edges of QR code image
 	qrCodeImgTransparent := image.NewRGBA(image.Rect(0, 0, size, size))
 	center := float64(size) / 2                     // max distance from a pixel at the border to the center
-	margin := 0.95                                  // margin, at which blur transition begins
+	margin := 1 - float64(opts.Fade.Float32())/100  // margin, at which blur transition begins
 	smooth := (margin - 1) * center / math.Log(0.1) // level of blur when the distance between the margin and center is maximal (1 - 0.1/255 = 96%)
 
 	for x := 0.0; x < float64(size); x++ {
 		for y := 0.0; y < float64(size); y++ {
-			r, g, b, _ := qrCodeImg.At(int(x), int(y)).RGBA()
-			a := uint32(196) // make image semi-transparent per default
+			r, g, b, a := qrCodeImg.At(int(x), int(y)).RGBA()
+			alpha := float64(a>>8) * 196 / 255 // make image semi-transparent per default
 
 			// calculate distance difference and calculate blur level (transparency) besides margin
-			if d := math.Max(math.Abs(x-center), math.Abs(y-center)); d > margin*center {
-				a = uint32(float64(a) * math.Exp((margin*center-d)/smooth))
+			if d := math.Max(math.Abs(x-center), math.Abs(y-center)); smooth > 0 && d > margin*center {
+				alpha *= math.Exp((margin*center - d) / smooth)
 			}
 
-			// apply
-			qrCodeImgTransparent.Set(int(x), int(y), color.RGBA{uint8(r), uint8(g), uint8(b), uint8(a)})
+			// apply, the colors are premultiplied by the alpha of the QR code colors
+			scale := alpha / float64(max(a>>8, 1))
+			qrCodeImgTransparent.Set(int(x), int(y), color.RGBA{uint8(float64(r>>8) * scale), uint8(float64(g>>8) * scale), uint8(float64(b>>8) * scale), uint8(alpha)})
 		}
 	}
 
-	qrCodeImg = qrCodeImgTransparent
-
-	x, y := 0, 0
-	switch position {
-	case types.PositionTopLeft:
-		x, y = x_offset, y_offset
-
-	case types.PositionTopRight:
-		x, y = imgBounds.Dx()-size-x_offset, y_offset
-
-	case types.PositionBottomLeft:
-		x, y = x_offset, imgBounds.Dy()-size-y_offset
-
-	case types.PositionBottomRight:
-		x, y = imgBounds.Dx()-size-x_offset, imgBounds.Dy()-size-y_offset
-
-	default:
-		return fmt.Errorf("unsupported position: %s, expected any of: %s", position,
-			types.Positions{types.PositionTopLeft, types.PositionTopRight, types.PositionBottomLeft, types.PositionBottomRight})
-
+	x, y, err := placeRect(opts.Position, imgBounds.Dx(), imgBounds.Dy(), size, size, 50)
+	if err != nil {
+		return err
 	}
 
 	// draw QR code image.
-	ctx.DrawImage(qrCodeImg, x, y)
+	ctx.DrawImage(qrCodeImgTransparent, x, y)
 
 	img.Image = ctx.Image()
 	return nil
 }
 
 // DrawWatermark draws a watermark onto the given image.
-func (img *Image) DrawWatermark(watermarkFile string, rotateCounterClockwise bool) error {
+func (img *Image) DrawWatermark(watermarkFile string, opts WatermarkOptions) error {
+	defer renderDuration.ObserveSince(time.Now(), "watermark")
+
+	if !AllowedWatermarkModes.Contains(opts.Mode) {
+		return fmt.Errorf("unsupported watermark mode: %s, expected any of: %s", opts.Mode, AllowedWatermarkModes)
+	}
+
+	if opts.Mode == WatermarkModeCorner && !types.AllowedPositions.Contains(opts.Position) {
+		return fmt.Errorf("unsupported position: %s, expected any of: %s", opts.Position, types.AllowedPositions)
+	}
+
 	var source io.ReadCloser
 	var err error
-	if r, ok := extras.EmbeddedWatermarks[watermarkFile]; ok {
+	if r, ok := extras.EmbeddedWatermarks.Open(watermarkFile); ok {
 		source = r
 
 	} else {
@@ -214,13 +381,13 @@ func (img *Image) DrawWatermark(watermarkFile string, rotateCounterClockwise boo
 	}
 
 	watermarkBounds := watermark.Bounds()
-	if watermarkBounds.Dx() < watermarkBounds.Dy() {
+	if opts.Mode == WatermarkModeStretch && watermarkBounds.Dx() < watermarkBounds.Dy() {
 		// rotate the image 90 degrees clockwise or counter-clockwise
 		rotated := image.NewRGBA(image.Rect(0, 0, watermarkBounds.Dy(), watermarkBounds.Dx()))
 		for y := watermarkBounds.Min.Y; y < watermarkBounds.Max.Y; y++ {
 			for x := watermarkBounds.Min.X; x < watermarkBounds.Max.X; x++ {
 				// set each pixel to the corresponding pixel in the original image
-				if rotateCounterClockwise {
+				if opts.RotateCounterClockwise {
 					rotated.Set(y, watermarkBounds.Bounds().Max.X-x-1, watermark.At(x, y))
 				} else {
 					rotated.Set(watermarkBounds.Bounds().Max.Y-y-1, x, watermark.At(x, y))
@@ -229,29 +396,254 @@ func (img *Image) DrawWatermark(watermarkFile string, rotateCounterClockwise boo
 		}
 
 		watermark = rotated
-		watermarkBounds = rotated.Bounds()
 	}
 
+	// rotate the watermark by an arbitrary angle
+	watermark = rotateImage(watermark, opts.Rotation)
+	watermarkBounds = watermark.Bounds()
+
 	imgBounds := img.Bounds()
+	imgWidth, imgHeight := imgBounds.Dx(), imgBounds.Dy()
+	margin := max(0, opts.Margin)
+
+	// determine the target size of the watermark
+	var width, height int
+	switch opts.Mode {
+	case WatermarkModeStretch:
+		width, height = imgWidth, imgHeight
+
+	case WatermarkModeFit:
+		width, height = fitSize(watermarkBounds.Dx(), watermarkBounds.Dy(), imgWidth-2*margin, imgHeight-2*margin, false)
+
+	case WatermarkModeFill:
+		width, height = fitSize(watermarkBounds.Dx(), watermarkBounds.Dy(), imgWidth, imgHeight, true)
+
+	default:
+		scale := opts.Size.Float32() / 100
+		width, height = fitSize(watermarkBounds.Dx(), watermarkBounds.Dy(), int(float32(imgWidth)*scale), int(float32(imgHeight)*scale), false)
+
+	}
+
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("watermark does not fit into the wallpaper: %dx%d", width, height)
+	}
 
-	// resize watermark to fit the wallpaper dimensions
-	resized := image.NewRGBA(image.Rect(0, 0, imgBounds.Dx(), imgBounds.Dy()))
+	// resize watermark to the target size
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
 	draw.CatmullRom.Scale(resized, resized.Rect, watermark, watermarkBounds, draw.Over, nil)
 
+	// determine the placements of the watermark
+	var placements []image.Point
+	switch opts.Mode {
+	case WatermarkModeCorner:
+		x, y, err := placeRect(opts.Position, imgWidth, imgHeight, width, height, margin)
+		if err != nil {
+			return err
+		}
+
+		placements = append(placements, image.Pt(x, y))
+
+	case WatermarkModeTile:
+		for y := margin; y < imgHeight; y += height + margin {
+			for x := margin; x < imgWidth; x += width + margin {
+				placements = append(placements, image.Pt(x, y))
+			}
+		}
+
+	default:
+		placements = append(placements, image.Pt((imgWidth-width)/2, (imgHeight-height)/2))
+
+	}
+
+	img.drawOver(resized, opts.Opacity, placements...)
+	return nil
+}
+
+// DrawTextWatermark draws a text watermark onto the given image.
+// The placeholders {hostname} and {username} are replaced with the name of the machine and the current user.
+func (img *Image) DrawTextWatermark(text string, opts TextWatermarkOptions) error {
+	defer renderDuration.ObserveSince(time.Now(), "text_watermark")
+
+	if !opts.Repeat && !types.AllowedPositions.Contains(opts.Position) {
+		return fmt.Errorf("unsupported position: %s, expected any of: %s", opts.Position, types.AllowedPositions)
+	}
+
+	text = expandPlaceholders(text)
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("empty text watermark")
+	}
+
+	imgBounds := img.Bounds()
+	size := max(1, min(opts.Size, MaxTextWatermarkSize, float64(imgBounds.Dy())/4))
+	face, err := loadFontFace(opts.Font, size)
+	if err != nil {
+		return err
+	}
+
+	// measure text bounding box
+	ctx := gg.NewContext(1, 1)
+	ctx.SetFontFace(face)
+	lineSpacing := 1.2
+	textWidth, textHeight := ctx.MeasureMultilineString(text, lineSpacing)
+	padding := size / 2
+
+	// render the text onto a transparent layer
+	ctx = gg.NewContext(int(math.Ceil(textWidth+2*padding)), int(math.Ceil(textHeight+2*padding)))
+	ctx.SetFontFace(face)
+	ctx.SetColor(opts.Color)
+	ctx.DrawStringWrapped(text, padding, padding, 0.0, 0.0, textWidth, lineSpacing, gg.AlignCenter)
+	layer := rotateImage(ctx.Image(), opts.Rotation)
+	layerBounds := layer.Bounds()
+	margin := max(0, opts.Margin)
+
+	var placements []image.Point
+	if opts.Repeat {
+		// repeat the text in staggered rows, so that it runs diagonally across the image
+		stepX, stepY := max(1, layerBounds.Dx()+margin), max(1, layerBounds.Dy()+margin)
+		if repeats := (imgBounds.Dx()/stepX + 2) * (imgBounds.Dy()/stepY + 2); repeats > maxTextWatermarkRepeats {
+			scale := math.Sqrt(float64(repeats) / maxTextWatermarkRepeats)
+			stepX, stepY = int(math.Ceil(float64(stepX)*scale)), int(math.Ceil(float64(stepY)*scale))
+		}
+		for row, y := 0, -stepY/2; y < imgBounds.Dy(); row, y = row+1, y+stepY {
+			for x := -stepX + (row%2)*stepX/2; x < imgBounds.Dx(); x += stepX {
+				placements = append(placements, image.Pt(x, y))
+			}
+		}
+
+	} else {
+		x, y, err := placeRect(opts.Position, imgBounds.Dx(), imgBounds.Dy(), layerBounds.Dx(), layerBounds.Dy(), margin)
+		if err != nil {
+			return err
+		}
+
+		placements = append(placements, image.Pt(x, y))
+
+	}
+
+	img.drawOver(layer, opts.Opacity, placements...)
+	return nil
+}
+
+// drawTextBox draws the text in a box styled like the description box at the given position.
+// Leading spaces and empty lines are preserved, so that the text can be laid out in columns.
+// The characters of the spans are styled accordingly.
+func (img *Image) drawTextBox(text string, position types.Position, fontName string, spans ...textSpan) error {
+	face, err := loadFontFace(fontName, 20)
+	if err != nil {
+		return err
+	}
+
+	imgBounds := img.Bounds()
+
+	// create a new image with the same dimensions as the original.
 	ctx := gg.NewContextForRGBA(image.NewRGBA(imgBounds))
 
-	// copy the original image onto the new image
+	// copy the original image onto the new image.
 	ctx.DrawImage(img.Image, 0, 0)
 
-	// draw the watermark
-	ctx.DrawImage(resized, 0, 0)
+	// measure text bounding box
+	ctx.SetFontFace(face)
+	lines, lineSpacing := strings.Split(text, "\n"), 1.2
+	textWidth, textHeight := ctx.MeasureMultilineString(text, lineSpacing)
+	lineHeight := ctx.FontHeight() * lineSpacing
+
+	margin, r := 50, math.Min(textHeight/5, 25)
+	w, h := textWidth+2*r, textHeight+2*r
+	x, y, err := placeRect(position, imgBounds.Dx(), imgBounds.Dy(), int(math.Ceil(w)), int(math.Ceil(h)), margin)
+	if err != nil {
+		return err
+	}
+
+	drawBox(ctx, float64(x), float64(y), w, h, r)
+
+	// draw the text line by line, consecutive characters of the same span are drawn as one segment
+	for i, line := range lines {
+		runes := []rune(line)
+		styles := make([]*textSpan, len(runes))
+		for j := range spans {
+			if span := &spans[j]; span.line == i && span.column >= 0 && span.column+span.length <= len(runes) {
+				for k := span.column; k < span.column+span.length; k++ {
+					styles[k] = span
+				}
+			}
+		}
+
+		top := float64(y) + r + float64(i)*lineHeight
+		for start := 0; start < len(runes); {
+			end := start + 1
+			for end < len(runes) && styles[end] == styles[start] {
+				end++
+			}
+
+			prefixWidth, _ := ctx.MeasureString(string(runes[:start]))
+			drawTextSegment(ctx, string(runes[start:end]), styles[start], float64(x)+r+prefixWidth, top, lineSpacing)
+			start = end
+		}
+	}
 
 	img.Image = ctx.Image()
 	return nil
 }
 
+// drawOver draws the layer onto the image at the given placements with the given opacity.
+func (img *Image) drawOver(layer image.Image, opacity types.Percent, placements ...image.Point) {
+	imgBounds := img.Bounds()
+	layerBounds := layer.Bounds()
+
+	// copy the original image onto the new image
+	canvas := image.NewRGBA(image.Rect(0, 0, imgBounds.Dx(), imgBounds.Dy()))
+	draw.Draw(canvas, canvas.Rect, img.Image, imgBounds.Min, draw.Src)
+
+	// draw the layer with the requested opacity
+	mask := image.NewUniform(color.Alpha{A: uint8(math.Round(float64(opacity.Float32()) * 255 / 100))})
+	for _, p := range placements {
+		draw.DrawMask(canvas, layerBounds.Sub(layerBounds.Min).Add(p), layer, layerBounds.Min, mask, image.Point{}, draw.Over)
+	}
+
+	img.Image = canvas
+}
+
+// drawTextSegment draws the text with the top left corner at the given point in the style of the span.
+func drawTextSegment(ctx *gg.Context, text string, span *textSpan, x, y, lineSpacing float64) {
+	width, _ := ctx.MeasureString(text)
+	height := ctx.FontHeight()
+	switch {
+	case span == nil:
+		ctx.SetColor(color.White)
+
+	case span.icon != nil:
+		// scale the icon to the segment
+		iconBounds := span.icon.Bounds()
+		iconWidth, iconHeight := fitSize(iconBounds.Dx(), iconBounds.Dy(), int(width), int(height*lineSpacing), false)
+		scaled := image.NewRGBA(image.Rect(0, 0, iconWidth, iconHeight))
+		draw.CatmullRom.Scale(scaled, scaled.Rect, span.icon, iconBounds, draw.Over, nil)
+		ctx.DrawImage(scaled, int(x+(width-float64(iconWidth))/2), int(y+(height-float64(iconHeight))/2+height*0.15))
+		return
+
+	case span.inverted:
+		ctx.SetColor(color.White)
+		ctx.DrawRoundedRectangle(x-3, y-2, width+6, height+4, 4)
+		ctx.Fill()
+		ctx.SetColor(color.Black)
+
+	case span.color != nil:
+		ctx.SetColor(span.color)
+
+	default:
+		ctx.SetColor(color.White)
+
+	}
+
+	ctx.DrawStringAnchored(text, x, y, 0.0, 1.0)
+	if span != nil && span.bold {
+		ctx.DrawStringAnchored(text, x+1, y, 0.0, 1.0)
+	}
+}
+
 // Dim dims the image by the specified percentage (0.0-100.0).
 func (img *Image) Dim(percentage types.Percent) error {
+	defer renderDuration.ObserveSince(time.Now(), "dim")
+
 	level := percentage.Float32()
 	if level < 0.0 || level > 100.0 {
 		return fmt.Errorf("percentage must be between 0.0 and 100.0, got %f", level)
@@ -275,3 +667,136 @@ func (img *Image) Dim(percentage types.Percent) error {
 	img.DimmedPercent = level
 	return nil
 }
+
+// drawBox draws the semi-transparent box with a white outline and rounded corners used for text overlays.
+func drawBox(ctx *gg.Context, x, y, w, h, r float64) {
+	// draw outline of the text box with rounded corners
+	ctx.SetColor(color.White)
+	ctx.SetLineWidth(5)
+	ctx.DrawRoundedRectangle(x, y, w, h, r)
+	ctx.Stroke()
+
+	// fill the text box with a semi-transparent black color (opacity of 64%)
+	ctx.SetColor(color.RGBA{R: 0, G: 0, B: 0, A: 164})
+	ctx.DrawRoundedRectangle(x, y, w, h, r)
+	ctx.Fill()
+}
+
+// expandPlaceholders replaces the {hostname} and {username} placeholders in the text.
+func expandPlaceholders(text string) string {
+	hostname, _ := os.Hostname()
+	username := ""
+	if current, err := user.Current(); err == nil {
+		username = current.Username
+	}
+
+	return strings.NewReplacer("{hostname}", hostname, "{username}", username).Replace(text)
+}
+
+// fitSize scales the source dimensions to fit into (or cover if requested) the given box keeping the aspect ratio.
+func fitSize(srcWidth, srcHeight, boxWidth, boxHeight int, cover bool) (width, height int) {
+	if srcWidth <= 0 || srcHeight <= 0 {
+		return 0, 0
+	}
+
+	scaleX, scaleY := float64(boxWidth)/float64(srcWidth), float64(boxHeight)/float64(srcHeight)
+	scale := math.Min(scaleX, scaleY)
+	if cover {
+		scale = math.Max(scaleX, scaleY)
+	}
+
+	return int(math.Round(float64(srcWidth) * scale)), int(math.Round(float64(srcHeight) * scale))
+}
+
+// placeRect returns the top left corner of a rectangle of the given size
+// placed at the given position within the canvas keeping the given margin.
+func placeRect(position types.Position, canvasWidth, canvasHeight, width, height, margin int) (x, y int, err error) {
+	left, centerX, right := margin, (canvasWidth-width)/2, canvasWidth-width-margin
+	top, centerY, bottom := margin, (canvasHeight-height)/2, canvasHeight-height-margin
+
+	switch position {
+	case types.PositionTopLeft:
+		return left, top, nil
+
+	case types.PositionTopCenter:
+		return centerX, top, nil
+
+	case types.PositionTopRight:
+		return right, top, nil
+
+	case types.PositionCenterLeft:
+		return left, centerY, nil
+
+	case types.PositionCenter:
+		return centerX, centerY, nil
+
+	case types.PositionCenterRight:
+		return right, centerY, nil
+
+	case types.PositionBottomLeft:
+		return left, bottom, nil
+
+	case types.PositionBottomCenter:
+		return centerX, bottom, nil
+
+	case types.PositionBottomRight:
+		return right, bottom, nil
+
+	default:
+		return 0, 0, fmt.Errorf("unsupported position: %s, expected any of: %s", position, types.AllowedPositions)
+
+	}
+}
+
+// loadFontFace loads the font face of the given size from the embedded fonts or from a font file.
+func loadFontFace(fontName string, size float64) (font.Face, error) {
+	var data []byte
+	if fontDataReader, ok := extras.EmbeddedFonts.Open(fontName); ok {
+		defer fontDataReader.Close()
+
+		var err error
+		data, err = io.ReadAll(fontDataReader)
+		if err != nil {
+			return nil, err
+		}
+
+	} else if raw, err := os.ReadFile(fontName); err == nil {
+		data = raw
+
+	} else {
+		return nil, fmt.Errorf("unknown font: %s", fontName)
+
+	}
+
+	// parse font
+	parsed, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing font: %v", err)
+	}
+
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, fmt.Errorf("error creating font face: %v", err)
+	}
+
+	return face, nil
+}
+
+// rotateImage rotates the image clockwise by the given angle in degrees.
+// The bounds of the resulting image are expanded to fit the rotated image.
+func rotateImage(src image.Image, degrees float64) image.Image {
+	if math.Mod(degrees, 360) == 0 {
+		return src
+	}
+
+	srcBounds := src.Bounds()
+	radians := gg.Radians(degrees)
+	sin, cos := math.Abs(math.Sin(radians)), math.Abs(math.Cos(radians))
+	width := int(math.Ceil(float64(srcBounds.Dx())*cos + float64(srcBounds.Dy())*sin))
+	height := int(math.Ceil(float64(srcBounds.Dx())*sin + float64(srcBounds.Dy())*cos))
+
+	ctx := gg.NewContext(width, height)
+	ctx.RotateAbout(radians, float64(width)/2, float64(height)/2)
+	ctx.DrawImageAnchored(src, width/2, height/2, 0.5, 0.5)
+	return ctx.Image()
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"image"
 	"testing"
 
 	"github.com/sarumaj/bing-wallpaper-changer/pkg/extras"
@@ -44,46 +45,104 @@ func TestDrawDescription(t *testing.T) {
 func TestDrawQRCode(t *testing.T) {
 	img := SetupTestImage(t)
 
-	type args struct {
-		resolution types.Resolution
-		position   types.Position
+	black, white := types.Color{A: 0xff}, types.Color{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	defaults := func(modify func(*QRCodeOptions)) QRCodeOptions {
+		opts := QRCodeOptions{Position: types.PositionTopRight, Size: 15, Level: QRCodeLevelMedium, Foreground: black, Background: white, Fade: 5}
+		modify(&opts)
+		return opts
 	}
 
 	for _, tt := range []struct {
 		name    string
-		args    args
+		args    QRCodeOptions
 		wantErr bool
 	}{
-		{"test#1", args{types.HighDefinition, types.PositionTopLeft}, false},
-		{"test#2", args{types.HighDefinition, types.PositionBottomRight}, false},
-		{"test#3", args{types.HighDefinition, types.PositionBottomLeft}, false},
-		{"test#4", args{types.HighDefinition, types.PositionTopRight}, false},
-		{"test#5", args{types.HighDefinition, types.Position(-1)}, true},
-		{"test#6", args{types.Resolution{}, types.PositionTopLeft}, true},
+		{"test#1", defaults(func(o *QRCodeOptions) { o.Position = types.PositionTopLeft }), false},
+		{"test#2", defaults(func(o *QRCodeOptions) { o.Position = types.PositionBottomRight }), false},
+		{"test#3", defaults(func(o *QRCodeOptions) { o.Position = types.PositionBottomLeft }), false},
+		{"test#4", defaults(func(o *QRCodeOptions) { o.Position = types.PositionTopRight }), false},
+		{"test#5", defaults(func(o *QRCodeOptions) { o.Position = types.Position(-1) }), true},
+		{"test#6", defaults(func(o *QRCodeOptions) { o.Position = types.PositionCenter; o.Size = 0 }), false},
+		{"test#7", defaults(func(o *QRCodeOptions) { o.Level = QRCodeLevelHighest; o.Fade = 0; o.Size = 30 }), false},
+		{"test#8", defaults(func(o *QRCodeOptions) { o.Level = QRCodeLevel(-1) }), true},
+		{"test#9", defaults(func(o *QRCodeOptions) { o.Foreground = types.Color{R: 0x20, G: 0x40, B: 0x80, A: 0x80} }), false},
+		{"test#10", defaults(func(o *QRCodeOptions) { o.Payload = "{{.DownloadURL}}" }), false},
+		{"test#11", defaults(func(o *QRCodeOptions) { o.Payload = "{{.Unknown}}" }), true},
+		{"test#12", defaults(func(o *QRCodeOptions) { o.Payload = "{{if false}}{{end}}" }), true},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			got := SetupTestImage(t)
 
-			err := got.DrawQRCode(tt.args.resolution, tt.args.position)
+			err := got.DrawQRCode(tt.args)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("DrawQRCode(%q, %q) error = %v, wantErr %t", tt.args.resolution, tt.args.position, err, tt.wantErr)
+				t.Errorf("DrawQRCode(%+v) error = %v, wantErr %t", tt.args, err, tt.wantErr)
 				return
 			}
 
 			if tt.wantErr != got.Equals(img) {
-				t.Errorf("DrawQRCode(%q, %q) = %v, want %v", tt.args.resolution, tt.args.position, got, img)
+				t.Errorf("DrawQRCode(%+v) = %v, want %v", tt.args, got, img)
 			}
 		})
 	}
 
+	// resolutions other than the ones provided by Bing are supported
+	cropped := SetupTestImage(t)
+	cropped.Image = cropped.Image.(interface {
+		SubImage(image.Rectangle) image.Image
+	}).SubImage(image.Rect(0, 0, 1000, 700))
+	if err := cropped.DrawQRCode(defaults(func(*QRCodeOptions) {})); err != nil {
+		t.Errorf("DrawQRCode() error = %v for a custom resolution", err)
+	}
 }
 
 func TestDrawWatermark(t *testing.T) {
 	img := SetupTestImage(t)
 
 	type args struct {
-		watermarkFile          string
-		rotateCounterClockwise bool
+		watermarkFile string
+		opts          WatermarkOptions
+	}
+
+	for _, tt := range []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"test#1", args{extras.DefaultWatermarkName, WatermarkOptions{Opacity: 100}}, false},
+		{"test#2", args{extras.DefaultWatermarkName, WatermarkOptions{Opacity: 100, RotateCounterClockwise: true}}, false},
+		{"test#3", args{"unknown", WatermarkOptions{Opacity: 100}}, true},
+		{"test#4", args{extras.DefaultWatermarkName, WatermarkOptions{Mode: WatermarkModeFit, Opacity: 50, Margin: 20}}, false},
+		{"test#5", args{extras.DefaultWatermarkName, WatermarkOptions{Mode: WatermarkModeFill, Opacity: 50}}, false},
+		{"test#6", args{extras.DefaultWatermarkName, WatermarkOptions{Mode: WatermarkModeCenter, Opacity: 100, Size: 30, Rotation: 45}}, false},
+		{"test#7", args{extras.DefaultWatermarkName, WatermarkOptions{Mode: WatermarkModeCorner, Position: types.PositionBottomLeft, Opacity: 100, Size: 10, Margin: 10}}, false},
+		{"test#8", args{extras.DefaultWatermarkName, WatermarkOptions{Mode: WatermarkModeTile, Opacity: 30, Size: 10, Margin: 25, Rotation: -30}}, false},
+		{"test#9", args{extras.DefaultWatermarkName, WatermarkOptions{Mode: WatermarkModeCorner, Position: types.Position(-1), Opacity: 100, Size: 10}}, true},
+		{"test#10", args{extras.DefaultWatermarkName, WatermarkOptions{Mode: WatermarkMode(-1), Opacity: 100}}, true},
+		{"test#11", args{extras.DefaultWatermarkName, WatermarkOptions{Mode: WatermarkModeCenter, Opacity: 100, Size: 0}}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SetupTestImage(t)
+
+			err := got.DrawWatermark(tt.args.watermarkFile, tt.args.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DrawWatermark(%q, %+v) error = %v, wantErr %t", tt.args.watermarkFile, tt.args.opts, err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr != got.Equals(img) {
+				t.Errorf("DrawWatermark(%q, %+v) = %v, want %v", tt.args.watermarkFile, tt.args.opts, got, img)
+			}
+		})
+	}
+}
+
+func TestDrawTextWatermark(t *testing.T) {
+	img := SetupTestImage(t)
+	white := types.Color{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+	type args struct {
+		text string
+		opts TextWatermarkOptions
 	}
 
 	for _, tt := range []struct {
@@ -91,21 +150,26 @@ func TestDrawWatermark(t *testing.T) {
 		args    args
 		wantErr bool
 	}{
-		{"test#1", args{extras.DefaultWatermarkName, false}, false},
-		{"test#2", args{extras.DefaultWatermarkName, true}, false},
-		{"test#3", args{"unknown", false}, true},
+		{"test#1", args{"CONFIDENTIAL", TextWatermarkOptions{Font: extras.DefaultFontName, Size: 48, Color: white, Opacity: 50, Position: types.PositionBottomLeft, Margin: 50}}, false},
+		{"test#2", args{"{hostname}\n{username}", TextWatermarkOptions{Font: extras.DefaultFontName, Size: 24, Color: white, Opacity: 100, Position: types.PositionCenter, Rotation: 30}}, false},
+		{"test#3", args{"CONFIDENTIAL", TextWatermarkOptions{Font: extras.DefaultFontName, Size: 32, Color: white, Opacity: 25, Rotation: -45, Margin: 40, Repeat: true}}, false},
+		{"test#4", args{"CONFIDENTIAL", TextWatermarkOptions{Font: "unknown", Size: 48, Color: white, Opacity: 50}}, true},
+		{"test#5", args{"CONFIDENTIAL", TextWatermarkOptions{Font: extras.DefaultFontName, Size: 48, Color: white, Opacity: 50, Position: types.Position(-1)}}, true},
+		{"test#6", args{" ", TextWatermarkOptions{Font: extras.DefaultFontName, Size: 48, Color: white, Opacity: 50}}, true},
+		{"test#7", args{"CONFIDENTIAL", TextWatermarkOptions{Font: extras.DefaultFontName, Size: 1e6, Color: white, Opacity: 50, Position: types.PositionCenter}}, false},
+		{"test#8", args{".", TextWatermarkOptions{Font: extras.DefaultFontName, Size: 1, Color: white, Opacity: 50, Repeat: true}}, false},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			got := SetupTestImage(t)
 
-			err := got.DrawWatermark(tt.args.watermarkFile, tt.args.rotateCounterClockwise)
+			err := got.DrawTextWatermark(tt.args.text, tt.args.opts)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("DrawWatermark(%q, %t) error = %v, wantErr %t", tt.args.watermarkFile, tt.args.rotateCounterClockwise, err, tt.wantErr)
+				t.Errorf("DrawTextWatermark(%q, %+v) error = %v, wantErr %t", tt.args.text, tt.args.opts, err, tt.wantErr)
 				return
 			}
 
 			if tt.wantErr != got.Equals(img) {
-				t.Errorf("DrawWatermark(%q, %t) = %v, want %v", tt.args.watermarkFile, tt.args.rotateCounterClockwise, got, img)
+				t.Errorf("DrawTextWatermark(%q, %+v) = %v, want %v", tt.args.text, tt.args.opts, got, img)
 			}
 		})
 	}
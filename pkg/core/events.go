@@ -0,0 +1,117 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The types of the events published on the event bus.
+const (
+	EventWallpaperChanged = "wallpaper.changed"
+	EventConfigChanged    = "config.changed"
+	EventRefreshStarted   = "refresh.started"
+	EventRefreshSucceeded = "refresh.succeeded"
+	EventRefreshFailed    = "refresh.failed"
+	EventAudioStarted     = "audio.started"
+	EventAudioStopped     = "audio.stopped"
+)
+
+// eventBuffer is the number of events buffered per subscriber.
+// Events are dropped for subscribers, which do not keep up.
+const eventBuffer = 32
+
+type (
+	// Event is a change of the state of the application published on the event bus.
+	Event struct {
+		ID   uint64    `json:"id"`
+		Type string    `json:"type"`
+		Time time.Time `json:"time"`
+		Data any       `json:"data,omitempty"`
+	}
+
+	// EventBus delivers the published events to its subscribers (e.g. the tray and the event stream of the API).
+	// Publishing never blocks, the events are delivered in order of their publication.
+	EventBus struct {
+		mu          sync.Mutex
+		lastID      uint64
+		subscribers map[chan Event]struct{}
+	}
+
+	// ConfigChange is a changed field of the config, the values are encoded as in the config document.
+	ConfigChange struct {
+		Old json.RawMessage `json:"old"`
+		New json.RawMessage `json:"new"`
+	}
+)
+
+// NewEventBus creates a new event bus.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[chan Event]struct{})}
+}
+
+// Publish publishes the event of the given type to all subscribers.
+// The receiver may be nil, in which case the event is discarded.
+func (b *EventBus) Publish(eventType string, data any) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := Event{ID: b.lastID, Type: eventType, Time: time.Now(), Data: data}
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe returns the channel of the events published from now on and the function to cancel the subscription.
+// The channel is closed when the subscription is cancelled.
+func (b *EventBus) Subscribe() (<-chan Event, func()) {
+	subscriber := make(chan Event, eventBuffer)
+
+	b.mu.Lock()
+	b.subscribers[subscriber] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return subscriber, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, subscriber)
+			b.mu.Unlock()
+			close(subscriber)
+		})
+	}
+}
+
+// diffConfig returns the fields of the config, which differ between old and new, by their JSON name.
+// Fields hidden from the config document are skipped.
+func diffConfig(old, new *Config) map[string]ConfigChange {
+	changes := make(map[string]ConfigChange)
+	oldValue, newValue := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	for i := range oldValue.NumField() {
+		name, _, _ := strings.Cut(oldValue.Type().Field(i).Tag.Get("json"), ",")
+		if name == "-" || name == "" {
+			continue
+		}
+
+		oldRaw, oldErr := json.Marshal(oldValue.Field(i).Interface())
+		newRaw, newErr := json.Marshal(newValue.Field(i).Interface())
+		if oldErr != nil || newErr != nil || bytes.Equal(oldRaw, newRaw) {
+			continue
+		}
+
+		changes[name] = ConfigChange{Old: oldRaw, New: newRaw}
+	}
+
+	return changes
+}
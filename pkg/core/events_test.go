@@ -0,0 +1,120 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+)
+
+func TestEventBus(t *testing.T) {
+	var bus *EventBus
+	bus.Publish(EventAudioStarted, nil)
+
+	bus = NewEventBus()
+	first, cancelFirst := bus.Subscribe()
+	second, cancelSecond := bus.Subscribe()
+	defer cancelSecond()
+
+	bus.Publish(EventRefreshStarted, "job")
+	bus.Publish(EventRefreshSucceeded, "job")
+	cancelFirst()
+	cancelFirst()
+	bus.Publish(EventWallpaperChanged, nil)
+
+	var got []string
+	for event := range first {
+		got = append(got, event.Type)
+	}
+
+	if len(got) != 2 || got[0] != EventRefreshStarted || got[1] != EventRefreshSucceeded {
+		t.Errorf("Subscribe() received %v, want the events published before cancelling", got)
+	}
+
+	for i, want := range []string{EventRefreshStarted, EventRefreshSucceeded, EventWallpaperChanged} {
+		if event := <-second; event.Type != want || event.ID != uint64(i+1) {
+			t.Errorf("Subscribe() received %d %s, want %d %s", event.ID, event.Type, i+1, want)
+		}
+	}
+
+	for range eventBuffer + 1 {
+		bus.Publish(EventAudioStopped, nil)
+	}
+
+	if len(second) != eventBuffer {
+		t.Errorf("Publish() buffered %d events, want %d", len(second), eventBuffer)
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	old := &Config{DimImage: 10, ApiPort: 8080}
+	old.Region.SetDefault(types.RegionGermany)
+
+	new := *old
+	new.DimImage, new.ApiPort, new.DrawQRCode = 20, 9090, true
+	new.Region.SetDefault(types.RegionJapan)
+
+	changes := diffConfig(old, &new)
+	if len(changes) != 3 {
+		t.Fatalf("diffConfig() = %v, want 3 changes", changes)
+	}
+
+	for name, want := range map[string][2]string{
+		"dimImage":   {"10", "20"},
+		"drawQRCode": {"false", "true"},
+	} {
+		if change := changes[name]; string(change.Old) != want[0] || string(change.New) != want[1] {
+			t.Errorf("diffConfig()[%s] = %s -> %s, want %s -> %s", name, change.Old, change.New, want[0], want[1])
+		}
+	}
+
+	if _, ok := changes["region"]; !ok {
+		t.Errorf("diffConfig() = %v, want region change", changes)
+	}
+}
+
+func TestControllerConfigure(t *testing.T) {
+	cfg := &Config{DimImage: 10}
+	controller := newController(cfg, nil)
+	events, cancel := controller.events.Subscribe()
+	defer cancel()
+
+	if _, err := controller.configure(func(cfg *Config) error {
+		cfg.DimImage = 20
+		return errors.New("rejected")
+	}); err == nil || cfg.DimImage != 10 {
+		t.Errorf("configure() error = %v, DimImage = %g, want the failed edit to be discarded", err, cfg.DimImage)
+	}
+
+	changes, err := controller.configure(func(cfg *Config) error {
+		cfg.DimImage = 30
+		return nil
+	})
+	if _, ok := changes["dimImage"]; err != nil || !ok || len(changes) != 1 || cfg.DimImage != 30 {
+		t.Errorf("configure() = %v, %v, want dimImage changed", changes, err)
+	}
+
+	if event := <-events; event.Type != EventConfigChanged || len(event.Data.(map[string]ConfigChange)) != 1 {
+		t.Errorf("configure() published %s %v, want the changed field", event.Type, event.Data)
+	}
+
+	if snapshot := controller.config(); snapshot == cfg || snapshot.DimImage != 30 {
+		t.Errorf("config() = %p %g, want a copy of the current config", snapshot, snapshot.DimImage)
+	}
+}
+
+func TestControllerUpdate(t *testing.T) {
+	cfg := &Config{AutoPlayAudio: true}
+	var got *Config
+	controller := newController(cfg, func(cfg *Config, job *Job) *Image {
+		got = cfg
+		return &Image{}
+	})
+
+	job, _ := controller.jobs.Start(JobTriggerAPI)
+	job.Wait()
+
+	if got == nil || got == cfg || got.AutoPlayAudio || !cfg.AutoPlayAudio {
+		t.Errorf("update() executed the job with %p %+v, want a copy of the config without audio playback", got, got)
+	}
+}
@@ -0,0 +1,460 @@
+package core
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var AllowedFeedFormats = FeedFormats{FeedFormatAtom, FeedFormatRSS, FeedFormatJSON}
+
+const (
+	FeedFormatAtom FeedFormat = iota
+	FeedFormatRSS
+	FeedFormatJSON
+)
+
+// DefaultFeedLimit is the number of most recent wallpapers in the feed if no limit is provided.
+const DefaultFeedLimit = 30
+
+// mediaTypes are the media types of the audio files missing in the builtin table of the mime package.
+var mediaTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+}
+
+// FeedFormat represents the format of the feed of archived wallpapers.
+type FeedFormat int
+
+// FeedFormats represents a list of feed formats.
+type FeedFormats []FeedFormat
+
+// Contains returns true if the format is in the list of formats.
+func (fs FeedFormats) Contains(f FeedFormat) bool {
+	return slices.Contains(fs, f)
+}
+
+// ContentType returns the media type of the feed.
+func (f FeedFormat) ContentType() string {
+	return map[FeedFormat]string{
+		FeedFormatAtom: "application/atom+xml; charset=utf-8",
+		FeedFormatRSS:  "application/rss+xml; charset=utf-8",
+		FeedFormatJSON: "application/feed+json; charset=utf-8",
+	}[f]
+}
+
+// FileName returns the file name of the feed (e.g. feed.atom).
+func (f FeedFormat) FileName() string {
+	return "feed." + f.String()
+}
+
+// String returns the string representation of the format.
+func (f FeedFormat) String() string {
+	s, ok := map[FeedFormat]string{
+		FeedFormatAtom: "atom",
+		FeedFormatRSS:  "rss",
+		FeedFormatJSON: "json",
+	}[f]
+	if !ok {
+		return "Unknown"
+	}
+	return s
+}
+
+type (
+	// FeedOptions configures the feed of archived wallpapers.
+	// The enclosures link the files relative to BaseURL, or as file URLs if BaseURL is empty.
+	// SelfURL is the URL the feed is published at, Limit the number of most recent wallpapers.
+	FeedOptions struct {
+		Title   string
+		BaseURL string
+		SelfURL string
+		Limit   int
+	}
+
+	// feedEntry is a wallpaper of the feed independent of the format.
+	feedEntry struct {
+		ID        string
+		Title     string
+		Summary   string
+		Link      string
+		Published time.Time
+		Image     feedEnclosure
+		Audio     *feedEnclosure
+	}
+
+	// feedEnclosure is a file attached to the entry of the feed.
+	feedEnclosure struct {
+		URL    string
+		Type   string
+		Length int64
+	}
+)
+
+// WriteFeed writes the feed of the most recent wallpapers of the archive saved in the directory.
+// Each entry carries the title, the description, the search link and the image and audio description as enclosures.
+func WriteFeed(w io.Writer, archive Archive, dir string, format FeedFormat, opts FeedOptions) error {
+	if !AllowedFeedFormats.Contains(format) {
+		return fmt.Errorf("unsupported feed format: %s, expected any of: %s", format, AllowedFeedFormats)
+	}
+
+	if opts.Title == "" {
+		opts.Title = DefaultGalleryTitle
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultFeedLimit
+	}
+
+	entries := make([]feedEntry, 0, min(opts.Limit, len(archive)))
+	for _, archived := range archive[:min(opts.Limit, len(archive))] {
+		entry, err := newFeedEntry(archived, dir, opts.BaseURL)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	updated := time.Now()
+	if len(entries) > 0 {
+		updated = entries[0].Published
+	}
+
+	switch format {
+	case FeedFormatRSS:
+		return writeRSSFeed(w, entries, updated, opts)
+
+	case FeedFormatJSON:
+		return writeJSONFeed(w, entries, opts)
+
+	default:
+		return writeAtomFeed(w, entries, updated, opts)
+	}
+}
+
+// newFeedEntry describes the archived wallpaper linking its files relative to the base URL.
+func newFeedEntry(archived ArchiveEntry, dir, baseURL string) (feedEntry, error) {
+	metadata := archived.Metadata
+	entry := feedEntry{
+		ID:        "urn:bing:" + metadata.ID,
+		Title:     metadata.Title,
+		Summary:   metadata.Description,
+		Link:      metadata.SearchURL,
+		Published: metadata.Date,
+	}
+
+	if metadata.ID == "" {
+		entry.ID = metadata.DownloadURL
+	}
+
+	if entry.Title == "" {
+		entry.Title = filepath.Base(archived.Path)
+	}
+
+	if metadata.Translation != "" {
+		entry.Summary += "\n" + metadata.Translation
+	}
+
+	if entry.Published.IsZero() {
+		entry.Published = archived.ModTime
+	}
+
+	var err error
+	if entry.Image, err = newFeedEnclosure(archived.Path, dir, baseURL); err != nil {
+		return entry, err
+	}
+
+	if audio := archived.Audio(); audio != "" {
+		enclosure, err := newFeedEnclosure(audio, dir, baseURL)
+		if err != nil {
+			return entry, err
+		}
+
+		entry.Audio = &enclosure
+	}
+
+	return entry, nil
+}
+
+// newFeedEnclosure returns the URL, the media type and the size of the file.
+func newFeedEnclosure(path, dir, baseURL string) (feedEnclosure, error) {
+	enclosure := feedEnclosure{Type: mediaType(path)}
+	if described, err := describeFile(path); err == nil {
+		enclosure.Length = described.Size
+	}
+
+	if baseURL == "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return enclosure, err
+		}
+
+		enclosure.URL = (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+		return enclosure, nil
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return enclosure, err
+	}
+
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/")
+	if err != nil {
+		return enclosure, err
+	}
+
+	enclosure.URL = base.JoinPath(strings.Split(filepath.ToSlash(rel), "/")...).String()
+	return enclosure, nil
+}
+
+// mediaType returns the media type of the file derived from its extension.
+func mediaType(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if t, ok := mediaTypes[ext]; ok {
+		return t
+	}
+
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+
+	return "application/octet-stream"
+}
+
+// writeAtomFeed writes the entries as Atom feed (RFC 4287).
+func writeAtomFeed(w io.Writer, entries []feedEntry, updated time.Time, opts FeedOptions) error {
+	type link struct {
+		Rel    string `xml:"rel,attr,omitempty"`
+		Type   string `xml:"type,attr,omitempty"`
+		Href   string `xml:"href,attr"`
+		Length int64  `xml:"length,attr,omitempty"`
+	}
+
+	type entry struct {
+		ID        string `xml:"id"`
+		Title     string `xml:"title"`
+		Updated   string `xml:"updated"`
+		Published string `xml:"published"`
+		Summary   string `xml:"summary"`
+		Links     []link `xml:"link"`
+	}
+
+	feed := struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Title   string   `xml:"title"`
+		Updated string   `xml:"updated"`
+		Author  string   `xml:"author>name"`
+		Links   []link   `xml:"link"`
+		Entries []entry  `xml:"entry"`
+	}{
+		ID:      "urn:bing-wallpaper-changer:feed",
+		Title:   opts.Title,
+		Updated: updated.Format(time.RFC3339),
+		Author:  AppName,
+	}
+
+	if opts.SelfURL != "" {
+		feed.ID = opts.SelfURL
+		feed.Links = append(feed.Links, link{Rel: "self", Type: "application/atom+xml", Href: opts.SelfURL})
+	}
+
+	for _, e := range entries {
+		atomEntry := entry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Updated:   e.Published.Format(time.RFC3339),
+			Published: e.Published.Format(time.RFC3339),
+			Summary:   e.Summary,
+			Links:     []link{{Rel: "enclosure", Type: e.Image.Type, Href: e.Image.URL, Length: e.Image.Length}},
+		}
+
+		if e.Link != "" {
+			atomEntry.Links = append([]link{{Rel: "alternate", Type: "text/html", Href: e.Link}}, atomEntry.Links...)
+		}
+
+		if e.Audio != nil {
+			atomEntry.Links = append(atomEntry.Links, link{Rel: "enclosure", Type: e.Audio.Type, Href: e.Audio.URL, Length: e.Audio.Length})
+		}
+
+		feed.Entries = append(feed.Entries, atomEntry)
+	}
+
+	return writeXMLFeed(w, feed)
+}
+
+// writeRSSFeed writes the entries as RSS 2.0 feed.
+// RSS allows a single enclosure, which is the image, hence the audio description is attached as Media RSS content.
+func writeRSSFeed(w io.Writer, entries []feedEntry, updated time.Time, opts FeedOptions) error {
+	type enclosure struct {
+		URL    string `xml:"url,attr"`
+		Type   string `xml:"type,attr"`
+		Length int64  `xml:"length,attr"`
+	}
+
+	type content struct {
+		URL      string `xml:"url,attr"`
+		Type     string `xml:"type,attr"`
+		FileSize int64  `xml:"fileSize,attr,omitempty"`
+		Medium   string `xml:"medium,attr"`
+	}
+
+	type guid struct {
+		Value     string `xml:",chardata"`
+		Permalink bool   `xml:"isPermaLink,attr"`
+	}
+
+	type item struct {
+		Title       string    `xml:"title"`
+		Link        string    `xml:"link,omitempty"`
+		Description string    `xml:"description"`
+		GUID        guid      `xml:"guid"`
+		PubDate     string    `xml:"pubDate"`
+		Enclosure   enclosure `xml:"enclosure"`
+		Contents    []content `xml:"media:content"`
+	}
+
+	type atomLink struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	}
+
+	type channel struct {
+		Title         string    `xml:"title"`
+		Link          string    `xml:"link"`
+		Description   string    `xml:"description"`
+		Generator     string    `xml:"generator"`
+		LastBuildDate string    `xml:"lastBuildDate"`
+		Self          *atomLink `xml:"atom:link,omitempty"`
+		Items         []item    `xml:"item"`
+	}
+
+	feed := struct {
+		XMLName xml.Name `xml:"rss"`
+		Version string   `xml:"version,attr"`
+		Atom    string   `xml:"xmlns:atom,attr"`
+		Media   string   `xml:"xmlns:media,attr"`
+		Channel channel  `xml:"channel"`
+	}{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Media:   "http://search.yahoo.com/mrss/",
+		Channel: channel{
+			Title:         opts.Title,
+			Link:          opts.SelfURL,
+			Description:   opts.Title,
+			Generator:     AppName,
+			LastBuildDate: updated.Format(time.RFC1123Z),
+		},
+	}
+
+	if opts.SelfURL != "" {
+		feed.Channel.Self = &atomLink{Href: opts.SelfURL, Rel: "self", Type: "application/rss+xml"}
+	}
+
+	for _, e := range entries {
+		rssItem := item{
+			Title:       e.Title,
+			Link:        e.Link,
+			Description: e.Summary,
+			GUID:        guid{Value: e.ID},
+			PubDate:     e.Published.Format(time.RFC1123Z),
+			Enclosure:   enclosure{URL: e.Image.URL, Type: e.Image.Type, Length: e.Image.Length},
+			Contents:    []content{{URL: e.Image.URL, Type: e.Image.Type, FileSize: e.Image.Length, Medium: "image"}},
+		}
+
+		if e.Audio != nil {
+			rssItem.Contents = append(rssItem.Contents, content{URL: e.Audio.URL, Type: e.Audio.Type, FileSize: e.Audio.Length, Medium: "audio"})
+		}
+
+		feed.Channel.Items = append(feed.Channel.Items, rssItem)
+	}
+
+	return writeXMLFeed(w, feed)
+}
+
+// writeJSONFeed writes the entries as JSON Feed (version 1.1).
+func writeJSONFeed(w io.Writer, entries []feedEntry, opts FeedOptions) error {
+	type attachment struct {
+		URL      string `json:"url"`
+		MimeType string `json:"mime_type"`
+		Size     int64  `json:"size_in_bytes,omitempty"`
+	}
+
+	type item struct {
+		ID            string       `json:"id"`
+		URL           string       `json:"url,omitempty"`
+		Title         string       `json:"title"`
+		ContentText   string       `json:"content_text"`
+		Image         string       `json:"image"`
+		DatePublished string       `json:"date_published"`
+		Attachments   []attachment `json:"attachments"`
+	}
+
+	feed := struct {
+		Version string `json:"version"`
+		Title   string `json:"title"`
+		FeedURL string `json:"feed_url,omitempty"`
+		Authors []struct {
+			Name string `json:"name"`
+		} `json:"authors"`
+		Items []item `json:"items"`
+	}{
+		Version: "https://jsonfeed.org/version/1.1",
+		Title:   opts.Title,
+		FeedURL: opts.SelfURL,
+		Authors: []struct {
+			Name string `json:"name"`
+		}{{Name: AppName}},
+		Items: make([]item, 0, len(entries)),
+	}
+
+	for _, e := range entries {
+		jsonItem := item{
+			ID:            e.ID,
+			URL:           e.Link,
+			Title:         e.Title,
+			ContentText:   e.Summary,
+			Image:         e.Image.URL,
+			DatePublished: e.Published.Format(time.RFC3339),
+			Attachments:   []attachment{{URL: e.Image.URL, MimeType: e.Image.Type, Size: e.Image.Length}},
+		}
+
+		if e.Audio != nil {
+			jsonItem.Attachments = append(jsonItem.Attachments, attachment{URL: e.Audio.URL, MimeType: e.Audio.Type, Size: e.Audio.Length})
+		}
+
+		feed.Items = append(feed.Items, jsonItem)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(feed)
+}
+
+// writeXMLFeed writes the XML header and the indented feed.
+func writeXMLFeed(w io.Writer, feed any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
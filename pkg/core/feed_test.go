@@ -0,0 +1,80 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriteFeed(t *testing.T) {
+	dir := t.TempDir()
+	paths := setupTestArchive(t, dir, 3, "{year}/{id}")
+	if err := os.WriteFile(strings.TrimSuffix(paths[0], ".png")+".mp3", []byte("audio"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := LoadArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		format FeedFormat
+		opts   FeedOptions
+		want   []string
+	}{
+		{"test#1", FeedFormatAtom, FeedOptions{BaseURL: "http://localhost/archive"}, []string{
+			`<feed xmlns="http://www.w3.org/2005/Atom">`, `<id>urn:bing:OHR.TestA</id>`, `rel="alternate"`,
+			`rel="enclosure" type="image/png" href="http://localhost/archive/`, `type="audio/mpeg" href="http://localhost/archive/`,
+		}},
+		{"test#2", FeedFormatRSS, FeedOptions{BaseURL: "http://localhost/archive/", SelfURL: "http://localhost/feed.rss", Limit: 1}, []string{
+			`<rss version="2.0"`, `<atom:link href="http://localhost/feed.rss" rel="self"`, `<enclosure url="http://localhost/archive/`,
+			`medium="audio"`, `<guid isPermaLink="false">urn:bing:OHR.TestA</guid>`,
+		}},
+		{"test#3", FeedFormatJSON, FeedOptions{}, []string{
+			`"version": "https://jsonfeed.org/version/1.1"`, `"id": "urn:bing:OHR.TestC"`, `"url": "file://`, `"mime_type": "audio/mpeg"`,
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := WriteFeed(&buffer, archive, dir, tt.format, tt.opts); err != nil {
+				t.Fatalf("WriteFeed() error = %v", err)
+			}
+
+			got := buffer.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("WriteFeed() = %s, want to contain %s", got, want)
+				}
+			}
+
+			if tt.format == FeedFormatJSON {
+				var document map[string]any
+				if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+					t.Errorf("WriteFeed() wrote invalid JSON: %v", err)
+				}
+				return
+			}
+
+			var document struct {
+				Items   []struct{} `xml:"channel>item"`
+				Entries []struct{} `xml:"entry"`
+			}
+			if err := xml.Unmarshal(buffer.Bytes(), &document); err != nil {
+				t.Errorf("WriteFeed() wrote invalid XML: %v", err)
+			}
+
+			if want := min(len(archive), max(tt.opts.Limit, 0)); tt.opts.Limit > 0 && len(document.Items)+len(document.Entries) != want {
+				t.Errorf("WriteFeed() wrote %d entries, want %d", len(document.Items)+len(document.Entries), want)
+			}
+		})
+	}
+
+	if err := WriteFeed(&bytes.Buffer{}, archive, dir, FeedFormat(-1), FeedOptions{}); err == nil {
+		t.Errorf("WriteFeed() error = nil, want unsupported format")
+	}
+}
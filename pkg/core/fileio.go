@@ -0,0 +1,98 @@
+package core
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// lockSuffix is the suffix of the lock file serializing the writes of a target.
+const lockSuffix = ".lock"
+
+// targetLocks holds a mutex per target, since file locks are not guaranteed to exclude each other within a process.
+var targetLocks sync.Map
+
+// lockTarget acquires the exclusive lock of the target path and returns the function releasing it.
+// The lock is held across processes by a lock file next to the target, which is removed on release.
+func lockTarget(path string) (func(), error) {
+	path = filepath.Clean(path)
+	value, _ := targetLocks.LoadOrStore(path, &sync.Mutex{})
+	mutex := value.(*sync.Mutex)
+	mutex.Lock()
+
+	for {
+		f, err := os.OpenFile(path+lockSuffix, os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			mutex.Unlock()
+			return nil, err
+		}
+
+		if err := lockFile(f); err != nil {
+			_ = f.Close()
+			mutex.Unlock()
+			return nil, err
+		}
+
+		// retry if the lock file has been removed by the previous holder in the meantime
+		locked, errLocked := f.Stat()
+		current, errCurrent := os.Stat(path + lockSuffix)
+		if errLocked != nil || errCurrent != nil || !os.SameFile(locked, current) {
+			_ = unlockFile(f)
+			_ = f.Close()
+			continue
+		}
+
+		return func() {
+			_ = os.Remove(path + lockSuffix)
+			_ = unlockFile(f)
+			_ = f.Close()
+			mutex.Unlock()
+		}, nil
+	}
+}
+
+// writeFileAtomic writes the file by writing a temporary file in the same directory,
+// syncing it to the disk and renaming it to the path, so that the file is never observed partially written.
+func writeFileAtomic(path string, write func(io.Writer) error) (err error) {
+	dir := filepath.Dir(path)
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = temp.Close()
+			_ = os.Remove(temp.Name())
+		}
+	}()
+
+	if err := write(temp); err != nil {
+		return err
+	}
+
+	if err := temp.Sync(); err != nil {
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(temp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return err
+	}
+
+	// persist the rename, not supported on every OS
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "wallpaper.png")
+
+	for _, tt := range []struct {
+		name    string
+		args    func(io.Writer) error
+		want    string
+		wantErr bool
+	}{
+		{"test#1", func(w io.Writer) error { _, err := io.WriteString(w, "first"); return err }, "first", false},
+		{"test#2", func(w io.Writer) error { _, err := io.WriteString(w, "second"); return err }, "second", false},
+		{"test#3", func(w io.Writer) error {
+			_, _ = io.WriteString(w, "trunc")
+			return fmt.Errorf("killed")
+		}, "second", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := writeFileAtomic(path, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("writeFileAtomic() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("writeFileAtomic() wrote %q, %v, want %q", got, err, tt.want)
+			}
+
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 1 {
+				t.Errorf("writeFileAtomic() left %d files behind, want 1", len(entries))
+			}
+		})
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "wallpaper.png"), func(io.Writer) error { return nil }); err == nil {
+		t.Error("writeFileAtomic() error = nil, want error for missing directory")
+	}
+}
+
+func TestLockTarget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallpaper.png")
+
+	var inside, overlaps atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			unlock, err := lockTarget(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer unlock()
+
+			if inside.Add(1) > 1 {
+				overlaps.Add(1)
+			}
+			time.Sleep(5 * time.Millisecond)
+			inside.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if overlaps.Load() > 0 {
+		t.Errorf("lockTarget() overlapped %d times, want serialized", overlaps.Load())
+	}
+
+	if _, err := os.Stat(path + lockSuffix); !os.IsNotExist(err) {
+		t.Errorf("lockTarget() left the lock file behind: %v", err)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallpaper.png.lock")
+
+	first, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	second, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	if err := lockFile(first); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error)
+	go func() { acquired <- lockFile(second) }()
+
+	select {
+	case <-acquired:
+		t.Fatal("lockFile() acquired a lock held by another file")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := unlockFile(first); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-acquired; err != nil {
+		t.Errorf("lockFile() error = %v", err)
+	}
+
+	_ = unlockFile(second)
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile acquires the exclusive advisory lock of the file blocking until it is available.
+func lockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_EX)
+}
+
+// unlockFile releases the lock of the file.
+func unlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
+
+// checkPrivate checks that the file is not accessible by the group and other users.
+func checkPrivate(info fs.FileInfo) error {
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return fmt.Errorf("permissions %#o, expected 0600", perm)
+	}
+
+	return nil
+}
@@ -0,0 +1,354 @@
+package core
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+//go:embed gallery/*
+var galleryTemplates embed.FS
+
+// DefaultGalleryTitle is the title of the gallery if none is provided.
+const DefaultGalleryTitle = "Bing Wallpapers"
+
+// galleryThumbnailWidth is the width of the thumbnails in pixels.
+const galleryThumbnailWidth = 480
+
+type (
+	// GalleryOptions configures the static gallery.
+	// Files of the template directory replace the embedded templates of the same name,
+	// additional files are copied to the gallery as they are (e.g. images, scripts).
+	GalleryOptions struct {
+		Title             string
+		TemplateDirectory string
+	}
+
+	// Gallery is the data passed to the gallery templates.
+	Gallery struct {
+		Title      string
+		Generator  string
+		Generated  time.Time
+		Wallpapers []GalleryWallpaper
+		Regions    []string
+		Months     []string
+	}
+
+	// GalleryWallpaper is a wallpaper of the gallery.
+	// The paths are slash separated and relative to the root of the gallery.
+	GalleryWallpaper struct {
+		Metadata
+		Day       string
+		Month     string
+		Furigana  string
+		Pinned    bool
+		Page      string
+		Image     string
+		Thumbnail string
+		Audio     string
+		Original  string
+	}
+
+	// galleryPage is the data passed to a template rendering a page.
+	// Root is the relative path from the page to the root of the gallery.
+	galleryPage struct {
+		Gallery   *Gallery
+		Wallpaper *GalleryWallpaper
+		Previous  *GalleryWallpaper
+		Next      *GalleryWallpaper
+		Root      string
+	}
+)
+
+// SearchText returns the lower case text matched by the client-side search.
+func (w GalleryWallpaper) SearchText() string {
+	return strings.ToLower(strings.Join([]string{w.Title, w.Copyright, w.Description, w.Translation, w.Day}, " "))
+}
+
+// BuildGallery builds a static website of the wallpapers saved in the directory (see LoadArchive) into the output directory.
+// It contains an index with thumbnails, which can be filtered by region and month and searched, and a page per wallpaper
+// with the description, the audio player and links. The wallpapers are linked, not copied, hence the output directory
+// is supposed to be published along with the download directory (e.g. as its subdirectory).
+// Pages and thumbnails of wallpapers, which are no longer present, are removed.
+func BuildGallery(dir, outputDir string, opts GalleryOptions) (*Gallery, error) {
+	archive, err := LoadArchive(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, assets, err := loadGalleryTemplates(opts.TemplateDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	gallery := &Gallery{Title: opts.Title, Generator: AppName, Generated: time.Now()}
+	if gallery.Title == "" {
+		gallery.Title = DefaultGalleryTitle
+	}
+
+	for _, sub := range []string{"days", "thumbs"} {
+		if err := os.MkdirAll(filepath.Join(outputDir, sub), os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	generated := make(map[string]bool)
+	for _, entry := range archive {
+		wallpaper, err := newGalleryWallpaper(entry, outputDir, generated)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := dumpThumbnail(entry.Path, filepath.Join(outputDir, filepath.FromSlash(wallpaper.Thumbnail))); err != nil {
+			return nil, fmt.Errorf("failed to create thumbnail of %s: %w", entry.Path, err)
+		}
+
+		gallery.Wallpapers = append(gallery.Wallpapers, wallpaper)
+		if wallpaper.Region != "" && !slices.Contains(gallery.Regions, wallpaper.Region) {
+			gallery.Regions = append(gallery.Regions, wallpaper.Region)
+		}
+
+		if !slices.Contains(gallery.Months, wallpaper.Month) {
+			gallery.Months = append(gallery.Months, wallpaper.Month)
+		}
+	}
+
+	slices.Sort(gallery.Regions)
+	slices.Sort(gallery.Months)
+	slices.Reverse(gallery.Months)
+
+	if err := renderGalleryPage(templates, "index.html", filepath.Join(outputDir, "index.html"), galleryPage{Gallery: gallery}); err != nil {
+		return nil, err
+	}
+
+	// the wallpapers are ordered from the most recent one, hence the previous day is the next entry
+	for i := range gallery.Wallpapers {
+		page := galleryPage{Gallery: gallery, Wallpaper: &gallery.Wallpapers[i], Root: "../"}
+		if i+1 < len(gallery.Wallpapers) {
+			page.Previous = &gallery.Wallpapers[i+1]
+		}
+
+		if i > 0 {
+			page.Next = &gallery.Wallpapers[i-1]
+		}
+
+		if err := renderGalleryPage(templates, "day.html", filepath.Join(outputDir, filepath.FromSlash(page.Wallpaper.Page)), page); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, content := range assets {
+		if err := writeFileAtomic(filepath.Join(outputDir, name), func(w io.Writer) error {
+			_, err := w.Write(content)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// remove the pages and thumbnails of deleted wallpapers
+	for _, sub := range []string{"days", "thumbs"} {
+		files, err := os.ReadDir(filepath.Join(outputDir, sub))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			if !file.IsDir() && !generated[path.Join(sub, file.Name())] {
+				if err := os.Remove(filepath.Join(outputDir, sub, file.Name())); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return gallery, nil
+}
+
+// newGalleryWallpaper describes the archive entry relative to the output directory.
+// The names of the page and the thumbnail are unique among the generated ones.
+func newGalleryWallpaper(entry ArchiveEntry, outputDir string, generated map[string]bool) (GalleryWallpaper, error) {
+	wallpaper := GalleryWallpaper{Metadata: entry.Metadata, Pinned: entry.Pinned}
+
+	date := entry.Metadata.Date
+	if date.IsZero() {
+		date = entry.ModTime
+	}
+
+	wallpaper.Day, wallpaper.Month = date.Format(time.DateOnly), date.Format("2006-01")
+	if sidecar, err := ReadSidecar(entry.Path); err == nil {
+		wallpaper.Furigana = sidecar.Furigana
+	}
+
+	var err error
+	if wallpaper.Image, err = galleryLink(outputDir, entry.Path); err != nil {
+		return wallpaper, err
+	}
+
+	if audio := entry.Audio(); audio != "" {
+		if wallpaper.Audio, err = galleryLink(outputDir, audio); err != nil {
+			return wallpaper, err
+		}
+	}
+
+	if original := entry.Original(); original != "" {
+		if wallpaper.Original, err = galleryLink(outputDir, original); err != nil {
+			return wallpaper, err
+		}
+	}
+
+	name := entry.Metadata.ID
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(entry.Path), filepath.Ext(entry.Path))
+	}
+
+	slug := sanitizeFileName(wallpaper.Day + "_" + name)
+	for i := 2; generated[path.Join("days", slug+".html")]; i++ {
+		slug = sanitizeFileName(fmt.Sprintf("%s_%s-%d", wallpaper.Day, name, i))
+	}
+
+	wallpaper.Page, wallpaper.Thumbnail = path.Join("days", slug+".html"), path.Join("thumbs", slug+".jpg")
+	generated[wallpaper.Page], generated[wallpaper.Thumbnail] = true, true
+	return wallpaper, nil
+}
+
+// galleryLink returns the slash separated path of the file relative to the output directory.
+func galleryLink(outputDir, file string) (string, error) {
+	absOutputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return "", err
+	}
+
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(absOutputDir, absFile)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// dumpThumbnail scales the wallpaper down to the width of the thumbnails and saves it as JPEG.
+// Existing thumbnails newer than the wallpaper are kept.
+func dumpThumbnail(source, target string) error {
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	if targetInfo, err := os.Stat(target); err == nil && targetInfo.ModTime().After(sourceInfo.ModTime()) {
+		return nil
+	}
+
+	decoder, err := getDecoder(source)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	img, err := decoder(f)
+	if err != nil {
+		return err
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 {
+		return fmt.Errorf("empty image")
+	}
+
+	width := min(galleryThumbnailWidth, bounds.Dx())
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, max(1, bounds.Dy()*width/bounds.Dx())))
+	draw.CatmullRom.Scale(thumbnail, thumbnail.Bounds(), img, bounds, draw.Src, nil)
+
+	return writeFileAtomic(target, func(w io.Writer) error {
+		return jpeg.Encode(w, thumbnail, &jpeg.Options{Quality: 80})
+	})
+}
+
+// loadGalleryTemplates parses the HTML templates and reads the other files (assets) of the gallery.
+// Files of the template directory take precedence over the embedded ones.
+func loadGalleryTemplates(dir string) (*template.Template, map[string][]byte, error) {
+	files := make(map[string][]byte)
+	embedded, err := fs.Sub(galleryTemplates, "gallery")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sources := []fs.FS{embedded}
+	if dir != "" {
+		sources = append(sources, os.DirFS(dir))
+	}
+
+	for _, source := range sources {
+		entries, err := fs.ReadDir(source, ".")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			if files[entry.Name()], err = fs.ReadFile(source, entry.Name()); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	templates := template.New("gallery")
+	assets := make(map[string][]byte)
+	for name, content := range files {
+		if filepath.Ext(name) != ".html" {
+			assets[name] = content
+			continue
+		}
+
+		if _, err := templates.New(name).Parse(string(content)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, name := range []string{"index.html", "day.html"} {
+		if templates.Lookup(name) == nil {
+			return nil, nil, fmt.Errorf("missing gallery template: %s", name)
+		}
+	}
+
+	return templates, assets, nil
+}
+
+// renderGalleryPage executes the named template and saves the page.
+func renderGalleryPage(templates *template.Template, name, target string, page galleryPage) error {
+	var buffer bytes.Buffer
+	if err := templates.ExecuteTemplate(&buffer, name, page); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(target, func(w io.Writer) error {
+		_, err := buffer.WriteTo(w)
+		return err
+	})
+}
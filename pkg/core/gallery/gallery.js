@@ -0,0 +1,33 @@
+(function () {
+  var search = document.getElementById("search");
+  var region = document.getElementById("region");
+  var month = document.getElementById("month");
+  var count = document.getElementById("count");
+  var wallpapers = document.querySelectorAll(".wallpaper");
+
+  function filter() {
+    var terms = search.value.toLowerCase().split(/\s+/).filter(Boolean);
+    var shown = 0;
+
+    wallpapers.forEach(function (wallpaper) {
+      var text = wallpaper.dataset.search;
+      var visible =
+        (!region.value || wallpaper.dataset.region === region.value) &&
+        (!month.value || wallpaper.dataset.month === month.value) &&
+        terms.every(function (term) { return text.indexOf(term) >= 0; });
+
+      wallpaper.hidden = !visible;
+      if (visible) {
+        shown++;
+      }
+    });
+
+    count.textContent = shown + " of " + wallpapers.length;
+  }
+
+  [search, region, month].forEach(function (element) {
+    element.addEventListener("input", filter);
+  });
+
+  filter();
+})();
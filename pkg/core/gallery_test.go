@@ -0,0 +1,106 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildGallery(t *testing.T) {
+	dir := t.TempDir()
+	paths := setupTestArchive(t, dir, 3, "{year}/{id}")
+	output := filepath.Join(dir, "gallery")
+
+	audio := strings.TrimSuffix(paths[0], ".png") + ".mp3"
+	if err := os.WriteFile(audio, []byte("audio"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	templates := t.TempDir()
+	if err := os.WriteFile(filepath.Join(templates, "style.css"), []byte("body { color: red; }"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(templates, "logo.svg"), []byte("<svg/>"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := BuildGallery(dir, output, GalleryOptions{TemplateDirectory: templates})
+	if err != nil {
+		t.Fatalf("BuildGallery() error = %v", err)
+	}
+
+	if len(got.Wallpapers) != 3 || got.Title != DefaultGalleryTitle || len(got.Regions) != 1 {
+		t.Fatalf("BuildGallery() = %+v, want 3 wallpapers of one region", got)
+	}
+
+	if got.Wallpapers[0].Audio == "" || got.Wallpapers[1].Audio != "" {
+		t.Errorf("BuildGallery() audio = %q, %q, want only the first one", got.Wallpapers[0].Audio, got.Wallpapers[1].Audio)
+	}
+
+	index, err := os.ReadFile(filepath.Join(output, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, wallpaper := range got.Wallpapers {
+		for _, file := range []string{wallpaper.Page, wallpaper.Thumbnail} {
+			if _, err := os.Stat(filepath.Join(output, filepath.FromSlash(file))); err != nil {
+				t.Errorf("BuildGallery() did not create %s", file)
+			}
+		}
+
+		if !strings.Contains(string(index), wallpaper.Thumbnail) {
+			t.Errorf("BuildGallery() index does not link %s", wallpaper.Thumbnail)
+		}
+
+		if _, err := os.Stat(filepath.Join(output, filepath.FromSlash(wallpaper.Image))); err != nil {
+			t.Errorf("BuildGallery() links a missing image %s", wallpaper.Image)
+		}
+	}
+
+	day, err := os.ReadFile(filepath.Join(output, filepath.FromSlash(got.Wallpapers[0].Page)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(day), "<audio") || !strings.Contains(string(day), `rel="prev"`) || strings.Contains(string(day), `rel="next"`) {
+		t.Errorf("BuildGallery() day page = %s, want audio player and link to the previous day only", day)
+	}
+
+	for name, want := range map[string]string{"style.css": "color: red", "logo.svg": "<svg/>", "gallery.js": "filter"} {
+		if content, err := os.ReadFile(filepath.Join(output, name)); err != nil || !strings.Contains(string(content), want) {
+			t.Errorf("BuildGallery() %s = %q, %v, want %q", name, content, err, want)
+		}
+	}
+
+	// the gallery itself must not be considered part of the archive
+	if err := os.Remove(paths[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err = BuildGallery(dir, output, GalleryOptions{Title: "Test"}); err != nil || len(got.Wallpapers) != 2 || got.Title != "Test" {
+		t.Fatalf("BuildGallery() = %+v, %v, want 2 wallpapers", got, err)
+	}
+
+	thumbnails, err := os.ReadDir(filepath.Join(output, "thumbs"))
+	if err != nil || len(thumbnails) != 2 {
+		t.Errorf("BuildGallery() left %d thumbnails, want 2", len(thumbnails))
+	}
+}
+
+func TestLoadGalleryTemplates(t *testing.T) {
+	templates := t.TempDir()
+	if err := os.WriteFile(filepath.Join(templates, "day.html"), []byte("{{.Broken"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := loadGalleryTemplates(templates); err == nil {
+		t.Errorf("loadGalleryTemplates() error = nil, want parse error")
+	}
+
+	if _, assets, err := loadGalleryTemplates(""); err != nil || len(assets) != 2 {
+		t.Errorf("loadGalleryTemplates() = %v, %v, want 2 assets", assets, err)
+	}
+}
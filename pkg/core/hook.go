@@ -0,0 +1,62 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+)
+
+// RunHook runs the given shell command after the wallpaper has been set.
+// The wallpaper path, the description and the color palette are passed as environment variables,
+// the palette is additionally written to the standard input of the command as JSON document.
+func RunHook(command string, img *Image) error {
+	if strings.TrimSpace(command) == "" {
+		return nil
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	cmd.Env = append(os.Environ(),
+		"BING_WALLPAPER="+img.Location,
+		"BING_WALLPAPER_DESCRIPTION="+img.Description,
+		"BING_WALLPAPER_SEARCH_URL="+img.SearchURL,
+		"BING_WALLPAPER_DOWNLOAD_URL="+img.DownloadURL,
+	)
+
+	if len(img.Palette) > 0 {
+		document, err := json.Marshal(img.Palette.Document(img.Location))
+		if err != nil {
+			return err
+		}
+
+		cmd.Stdin = bytes.NewReader(document)
+		cmd.Env = append(cmd.Env,
+			"BING_WALLPAPER_PALETTE="+string(document),
+			"BING_WALLPAPER_BACKGROUND="+img.Palette.Background().String(),
+			"BING_WALLPAPER_FOREGROUND="+img.Palette.Foreground().String(),
+		)
+
+		for i, c := range img.Palette.Terminal() {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("BING_WALLPAPER_COLOR%d=%s", i, c))
+		}
+	}
+
+	output, err := cmd.CombinedOutput()
+	logger.Logger.Debug("Hook output:", string(output))
+	if err != nil {
+		return fmt.Errorf("hook %q failed: %w: %s", command, err, bytes.TrimSpace(output))
+	}
+
+	return nil
+}
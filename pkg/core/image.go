@@ -1,6 +1,7 @@
 package core
 
 import (
+	"encoding/json"
 	"fmt"
 	"image"
 	"image/jpeg"
@@ -9,20 +10,137 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
 	"golang.org/x/image/webp"
 )
 
+var AllowedOutputFormats = OutputFormats{OutputFormatPNG, OutputFormatJPEG}
+
+const (
+	OutputFormatPNG OutputFormat = iota
+	OutputFormatJPEG
+)
+
+var AllowedPNGCompressions = PNGCompressions{PNGCompressionDefault, PNGCompressionNone, PNGCompressionBestSpeed, PNGCompressionBestCompression}
+
+const (
+	PNGCompressionDefault PNGCompression = iota
+	PNGCompressionNone
+	PNGCompressionBestSpeed
+	PNGCompressionBestCompression
+)
+
+const DefaultJPEGQuality = 90
+
+// OutputFormat represents the encoding of the saved wallpaper.
+type OutputFormat int
+
+// OutputFormats represents a list of output formats.
+type OutputFormats []OutputFormat
+
+// Contains returns true if the format is in the list of formats.
+func (fs OutputFormats) Contains(f OutputFormat) bool {
+	return slices.Contains(fs, f)
+}
+
+// Extension returns the file extension of the format.
+func (f OutputFormat) Extension() string {
+	if f == OutputFormatJPEG {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// String returns the string representation of the format.
+func (f OutputFormat) String() string {
+	s, ok := map[OutputFormat]string{
+		OutputFormatPNG:  "png",
+		OutputFormatJPEG: "jpeg",
+	}[f]
+	if !ok {
+		return "Unknown"
+	}
+	return s
+}
+
+// PNGCompression represents the compression level of the PNG encoder.
+type PNGCompression int
+
+// PNGCompressions represents a list of PNG compression levels.
+type PNGCompressions []PNGCompression
+
+// Contains returns true if the compression level is in the list of compression levels.
+func (cs PNGCompressions) Contains(c PNGCompression) bool {
+	return slices.Contains(cs, c)
+}
+
+// String returns the string representation of the compression level.
+func (c PNGCompression) String() string {
+	s, ok := map[PNGCompression]string{
+		PNGCompressionDefault:         "default",
+		PNGCompressionNone:            "none",
+		PNGCompressionBestSpeed:       "best-speed",
+		PNGCompressionBestCompression: "best-compression",
+	}[c]
+	if !ok {
+		return "Unknown"
+	}
+	return s
+}
+
+// level returns the compression level of the PNG encoder.
+func (c PNGCompression) level() png.CompressionLevel {
+	return map[PNGCompression]png.CompressionLevel{
+		PNGCompressionNone:            png.NoCompression,
+		PNGCompressionBestSpeed:       png.BestSpeed,
+		PNGCompressionBestCompression: png.BestCompression,
+	}[c]
+}
+
+// EncodeOptions represents the options of the encoder of the saved wallpaper.
+// The JPEG quality ranges from 1 to 100 and is only used for the JPEG format.
+// If KeepOriginal is set, the image as downloaded from Bing is saved next to the encoded one.
+// If the file name template is empty, the default template is used.
+type EncodeOptions struct {
+	Format           OutputFormat
+	JPEGQuality      int
+	PNGCompression   PNGCompression
+	KeepOriginal     bool
+	FileNameTemplate string
+	// Sidecar enables the JSON sidecar (see Sidecar), Settings is the config recorded in it
+	Sidecar  bool
+	Settings *Config
+	// ImageOnly encodes the image only, the original, the audio and the sidecar are left as dumped before (e.g. when the overlays are redrawn)
+	ImageOnly bool
+}
+
 // Image is a wrapper around the image.Image interface.
 type Image struct {
 	image.Image
 	Audio         *Audio
+	Title         string
+	Copyright     string
 	Description   string
+	Translation   string
+	Furigana      string
+	Region        types.Region
+	ID            string
+	Date          time.Time
 	SearchURL     string
 	DownloadURL   string
 	Location      string
 	DimmedPercent float32
+	Palette       Palette
+	Bing          json.RawMessage
+
+	// base is the image without overlays
+	base image.Image
+	// original is the image as downloaded from Bing
+	original []byte
 }
 
 // Equals returns true if the given image is equal to the receiver.
@@ -47,37 +165,79 @@ func (i *Image) Equals(other *Image) bool {
 }
 
 // EncodeAndDump encodes the image and dumps it to the target directory.
+// The file is named after the file name template (see FileName), its extension follows the output format
+// and the metadata of the image is embedded into it (see ReadMetadata).
+// If requested, the original image is dumped next to it with the suffix ".original".
 // If audio description is available, it will be dumped as well.
-func (img *Image) EncodeAndDump(targetDir string) (string, error) {
-	parsed, err := url.Parse(img.DownloadURL)
+// If requested, a JSON sidecar describing the saved files is written last (see Sidecar).
+// The files are written atomically and concurrent dumps of the same wallpaper are serialized.
+func (img *Image) EncodeAndDump(targetDir string, opts EncodeOptions) (string, error) {
+	if !AllowedOutputFormats.Contains(opts.Format) {
+		return "", fmt.Errorf("unsupported output format: %s, expected any of: %s", opts.Format, AllowedOutputFormats)
+	}
+
+	fileName, err := img.FileName(opts.FileNameTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	base := filepath.Join(targetDir, fileName)
+	_ = os.MkdirAll(filepath.Dir(base), os.ModePerm)
+
+	// the lock covers the resolution of collisions as well
+	unlock, err := lockTarget(base + opts.Format.Extension())
 	if err != nil {
 		return "", err
 	}
 
-	fileName := parsed.Query().Get("id")
-	if fileName == "" {
-		return "", fmt.Errorf("missing file name in URL: %s", img.DownloadURL)
+	defer unlock()
+
+	filePath, err := img.availablePath(base, opts.Format.Extension())
+	if err != nil {
+		return "", err
 	}
 
-	_ = os.MkdirAll(targetDir, os.ModePerm)
-	fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".png"
-	filePath := filepath.Join(targetDir, fileName)
-	target, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	encoder, err := getEncoder(filePath, opts)
 	if err != nil {
 		return "", err
 	}
 
-	defer target.Close()
+	files := map[string]string{"image": filePath}
+	base = strings.TrimSuffix(filePath, filepath.Ext(filePath))
+	if opts.KeepOriginal && len(img.original) > 0 && !opts.ImageOnly {
+		originalExt := ".jpg"
+		if parsed, err := url.Parse(img.DownloadURL); err == nil && filepath.Ext(parsed.Query().Get("id")) != "" {
+			originalExt = filepath.Ext(parsed.Query().Get("id"))
+		}
+
+		files["original"] = base + ".original" + originalExt
+		if err := writeFileAtomic(files["original"], func(w io.Writer) error {
+			_, err := w.Write(img.original)
+			return err
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	if img.Audio != nil && !opts.ImageOnly {
+		files["audio"] = base + "." + strings.ToLower(img.Audio.Encoding)
+		if err := img.Audio.Dump(files["audio"]); err != nil {
+			return "", err
+		}
+	}
+
+	if err := writeFileAtomic(filePath, func(w io.Writer) error { return encoder(w, img) }); err != nil {
+		return "", err
+	}
 
-	if img.Audio != nil {
-		audioPath := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + "." + strings.ToLower(img.Audio.Encoding)
-		if err := img.Audio.Dump(audioPath); err != nil {
+	if opts.Sidecar && !opts.ImageOnly {
+		if err := img.dumpSidecar(filePath, files, opts.Settings); err != nil {
 			return "", err
 		}
 	}
 
 	img.Location = filePath
-	return target.Name(), png.Encode(target, img)
+	return filePath, nil
 }
 
 // Update updates the receiver with the given image.
@@ -86,11 +246,25 @@ func (i *Image) Update(o *Image) {
 		return
 	}
 
+	renderLock.Lock()
+	defer renderLock.Unlock()
+
 	i.Image = o.Image
+	i.base = o.base
+	i.Title = o.Title
+	i.Copyright = o.Copyright
 	i.Description = o.Description
+	i.Translation = o.Translation
+	i.Furigana = o.Furigana
+	i.Region = o.Region
+	i.ID = o.ID
+	i.Date = o.Date
 	i.SearchURL = o.SearchURL
 	i.DownloadURL = o.DownloadURL
 	i.Location = o.Location
+	i.Palette = o.Palette
+	i.Bing = o.Bing
+	i.original = o.original
 
 	if o.Audio == nil {
 		return
@@ -104,6 +278,32 @@ func (i *Image) Update(o *Image) {
 	}
 }
 
+// getEncoder returns the encoder for the given file path.
+// The encoders embed the metadata of the image.
+func getEncoder(path string, opts EncodeOptions) (encoder func(io.Writer, *Image) error, err error) {
+	switch ext := filepath.Ext(path); ext {
+	case ".jpg", ".jpeg":
+		if opts.JPEGQuality < 1 || opts.JPEGQuality > 100 {
+			return nil, fmt.Errorf("invalid JPEG quality: %d, expected a value between 1 and 100", opts.JPEGQuality)
+		}
+
+		encoder = func(w io.Writer, img *Image) error { return img.encodeJPEG(w, opts.JPEGQuality) }
+
+	case ".png":
+		if !AllowedPNGCompressions.Contains(opts.PNGCompression) {
+			return nil, fmt.Errorf("unsupported PNG compression: %s, expected any of: %s", opts.PNGCompression, AllowedPNGCompressions)
+		}
+
+		encoder = func(w io.Writer, img *Image) error { return img.encodePNG(w, opts.PNGCompression.level()) }
+
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", ext)
+
+	}
+
+	return encoder, nil
+}
+
 // getDecoder returns the decoder for the given file path.
 func getDecoder(path string) (decoder func(io.Reader) (image.Image, error), err error) {
 	switch ext := filepath.Ext(path); ext {
@@ -1,13 +1,78 @@
 package core
 
 import (
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 func TestEncodeAndDump(t *testing.T) {
-	img := SetupTestImage(t)
+	f, err := testData.Open("bing.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	original, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name         string
+		args         EncodeOptions
+		wantFile     string
+		wantOriginal bool
+		wantErr      bool
+	}{
+		{"test#1", EncodeOptions{}, "OHR.FolegandrosGreece_DE-DE3993128464_1920x1080.png", false, false},
+		{"test#2", EncodeOptions{PNGCompression: PNGCompressionBestSpeed}, "OHR.FolegandrosGreece_DE-DE3993128464_1920x1080.png", false, false},
+		{"test#3", EncodeOptions{Format: OutputFormatJPEG, JPEGQuality: 85}, "OHR.FolegandrosGreece_DE-DE3993128464_1920x1080.jpg", false, false},
+		{"test#4", EncodeOptions{Format: OutputFormatJPEG, JPEGQuality: 85, KeepOriginal: true}, "OHR.FolegandrosGreece_DE-DE3993128464_1920x1080.jpg", true, false},
+		{"test#5", EncodeOptions{Format: OutputFormatJPEG, JPEGQuality: 85, KeepOriginal: true, ImageOnly: true}, "OHR.FolegandrosGreece_DE-DE3993128464_1920x1080.jpg", false, false},
+		{"test#6", EncodeOptions{Format: OutputFormatJPEG}, "", false, true},
+		{"test#7", EncodeOptions{PNGCompression: PNGCompression(-1)}, "", false, true},
+		{"test#8", EncodeOptions{Format: OutputFormat(-1)}, "", false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			img := SetupTestImage(t)
+			img.original = original
+			dir := t.TempDir()
+
+			got, err := img.EncodeAndDump(dir, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EncodeAndDump() error = %v, wantErr %t", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if want := filepath.Join(dir, tt.wantFile); got != want {
+				t.Errorf("EncodeAndDump() = %q, want %q", got, want)
+			}
+
+			decoder, err := getDecoder(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			if _, err := decoder(f); err != nil {
+				t.Errorf("EncodeAndDump() wrote an undecodable file: %v", err)
+			}
 
-	if _, err := img.EncodeAndDump(t.TempDir()); err != nil {
-		t.Errorf("EncodeAndDump() error = %v, wantErr %v", err, false)
+			kept, err := os.ReadFile(filepath.Join(dir, "OHR.FolegandrosGreece_DE-DE3993128464_1920x1080.original.jpg"))
+			if tt.wantOriginal != (err == nil) || (tt.wantOriginal && string(kept) != string(original)) {
+				t.Errorf("EncodeAndDump() kept original = %t, want %t", err == nil, tt.wantOriginal)
+			}
+		})
 	}
 }
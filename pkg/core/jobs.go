@@ -0,0 +1,334 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	JobStateRunning JobState = iota
+	JobStateSucceeded
+	JobStateFailed
+	JobStateQueued
+)
+
+// The steps of a refresh job.
+const (
+	JobStepFetch     = "fetch"
+	JobStepTranslate = "translate"
+	JobStepFurigana  = "furigana"
+	JobStepTTS       = "tts"
+	JobStepRender    = "render"
+	JobStepSet       = "set"
+)
+
+// The triggers of a refresh job.
+const (
+	JobTriggerStartup = "startup"
+	JobTriggerAPI     = "api"
+	JobTriggerTray    = "tray"
+)
+
+// maxJobs limits the number of finished jobs kept for status requests.
+const maxJobs = 50
+
+// errNoWallpaper is returned by a job, which did not produce a wallpaper.
+var errNoWallpaper = errors.New("no wallpaper has been produced")
+
+// JobState represents the state of a refresh job.
+type JobState int
+
+// MarshalJSON returns the string representation of the state.
+func (s JobState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON parses the string representation of the state.
+func (s *JobState) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	for _, state := range []JobState{JobStateRunning, JobStateSucceeded, JobStateFailed, JobStateQueued} {
+		if state.String() == name {
+			*s = state
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown job state: %s", name)
+}
+
+// String returns the string representation of the state.
+func (s JobState) String() string {
+	str, ok := map[JobState]string{
+		JobStateRunning:   "running",
+		JobStateSucceeded: "succeeded",
+		JobStateFailed:    "failed",
+		JobStateQueued:    "queued",
+	}[s]
+	if !ok {
+		return "Unknown"
+	}
+	return str
+}
+
+type (
+	// Job is a refresh of the wallpaper run in the background.
+	// Its steps are timed and their errors are recorded. A job fails if any of its steps fails.
+	// Requests to refresh the wallpaper while a job is running are coalesced into it (see Coalesced),
+	// unless they need a job of their own, which is queued to run after the running job (see JobRunner.Queue).
+	Job struct {
+		ID        string    `json:"id"`
+		Trigger   string    `json:"trigger"`
+		State     JobState  `json:"state"`
+		Started   time.Time `json:"started"`
+		Finished  time.Time `json:"finished,omitzero"`
+		Steps     []JobStep `json:"steps"`
+		Errors    []string  `json:"errors,omitempty"`
+		Coalesced int       `json:"coalesced"`
+
+		mu   sync.Mutex
+		done chan struct{}
+	}
+
+	// JobStep is a timed step of a job.
+	JobStep struct {
+		Name     string        `json:"name"`
+		Started  time.Time     `json:"started"`
+		Duration time.Duration `json:"duration"`
+		Error    string        `json:"error,omitempty"`
+	}
+
+	// JobRunner runs refresh jobs one at a time and keeps the most recent ones for status requests.
+	// The start and the outcome of the jobs are published on the event bus.
+	JobRunner struct {
+		mu            sync.Mutex
+		run           func(*Job) error
+		events        *EventBus
+		running       *Job
+		queued        *Job
+		jobs          []*Job
+		lastFinished  *Job
+		lastSucceeded *Job
+	}
+)
+
+// NewJobRunner creates a job runner running the given function for each job.
+// The event bus may be nil.
+func NewJobRunner(run func(*Job) error, events *EventBus) *JobRunner {
+	return &JobRunner{run: run, events: events}
+}
+
+// Start starts a new job unless a job is running already, in which case the request is coalesced into the running job.
+// It returns the job and whether it has been started.
+func (r *JobRunner) Start(trigger string) (*Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running != nil {
+		r.running.coalesce()
+		return r.running, false
+	}
+
+	job := r.add(trigger)
+	r.launch(job)
+	return job, true
+}
+
+// Queue starts a new job unless a job is running already, in which case a job is queued to run once the running job has finished.
+// It is used for requests the running job cannot serve, e.g. a change of the config read by the running job already.
+// Requests made while a job is queued are coalesced into the queued job. It returns the job and whether it has been started.
+func (r *JobRunner) Queue(trigger string) (*Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case r.running == nil:
+		job := r.add(trigger)
+		r.launch(job)
+		return job, true
+
+	case r.queued != nil:
+		r.queued.coalesce()
+		return r.queued, false
+	}
+
+	r.queued = r.add(trigger)
+	r.queued.State = JobStateQueued
+	return r.queued, false
+}
+
+// add creates a new job and keeps it for status requests, the caller must hold the lock of the runner.
+func (r *JobRunner) add(trigger string) *Job {
+	job := &Job{ID: newJobID(), Trigger: trigger, State: JobStateRunning, Started: time.Now(), Steps: []JobStep{}, done: make(chan struct{})}
+	r.jobs = append(r.jobs, job)
+	if len(r.jobs) > maxJobs {
+		r.jobs = r.jobs[len(r.jobs)-maxJobs:]
+	}
+
+	return job
+}
+
+// launch runs the job in the background, the caller must hold the lock of the runner.
+func (r *JobRunner) launch(job *Job) {
+	job.mu.Lock()
+	job.State, job.Started = JobStateRunning, time.Now()
+	job.mu.Unlock()
+
+	r.running = job
+	r.events.Publish(EventRefreshStarted, job.snapshot())
+	go func() {
+		// the waiting callers are released once the outcome has been recorded and published
+		defer close(job.done)
+
+		err := r.run(job)
+		job.finish(err)
+		r.record(job)
+	}()
+}
+
+// record records the outcome of the finished job in the metrics, publishes it and releases the runner for the next job.
+// The queued job, if any, is launched after the outcome has been published.
+func (r *JobRunner) record(job *Job) {
+	refreshes.Add(1, job.Trigger, job.State.String())
+	refreshDuration.Observe(job.Finished.Sub(job.Started).Seconds(), job.Trigger)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.running = nil
+	r.lastFinished = job
+	if job.State == JobStateSucceeded {
+		r.lastSucceeded = job
+		lastRefreshSuccess.Set(float64(job.Finished.UnixMilli()) / 1e3)
+	}
+
+	if job.State == JobStateFailed {
+		r.events.Publish(EventRefreshFailed, job.snapshot())
+	} else {
+		r.events.Publish(EventRefreshSucceeded, job.snapshot())
+	}
+
+	if r.queued != nil {
+		r.launch(r.queued)
+		r.queued = nil
+	}
+}
+
+// LastFinished returns the most recently finished job and the most recently succeeded job, which are nil if none.
+func (r *JobRunner) LastFinished() (finished, succeeded *Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lastFinished, r.lastSucceeded
+}
+
+// Job returns the job with the given id.
+func (r *JobRunner) Job(id string) (*Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, job := range r.jobs {
+		if job.ID == id {
+			return job, true
+		}
+	}
+
+	return nil, false
+}
+
+// Step runs the step of the job recording its duration and error.
+// The receiver may be nil, in which case the step is run without being recorded.
+func (j *Job) Step(name string, step func() error) error {
+	if j == nil {
+		return step()
+	}
+
+	started := time.Now()
+	err := step()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	record := JobStep{Name: name, Started: started, Duration: time.Since(started)}
+	if err != nil {
+		record.Error = err.Error()
+		j.Errors = append(j.Errors, name+": "+err.Error())
+	}
+
+	j.Steps = append(j.Steps, record)
+	return err
+}
+
+// Wait blocks until the job has finished.
+func (j *Job) Wait() {
+	<-j.done
+}
+
+// MarshalJSON returns the current status of the job.
+func (j *Job) MarshalJSON() ([]byte, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	type job Job
+	return json.Marshal((*job)(j))
+}
+
+// coalesce counts a request coalesced into the job.
+func (j *Job) coalesce() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.Coalesced++
+}
+
+// snapshot returns a copy of the current status of the job, e.g. to publish it as of now.
+// The copy cannot be waited for.
+func (j *Job) snapshot() *Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return &Job{
+		ID:        j.ID,
+		Trigger:   j.Trigger,
+		State:     j.State,
+		Started:   j.Started,
+		Finished:  j.Finished,
+		Steps:     slices.Clone(j.Steps),
+		Errors:    slices.Clone(j.Errors),
+		Coalesced: j.Coalesced,
+	}
+}
+
+// finish records the outcome of the job.
+// Errors of steps returned by the job are recorded once only.
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err != nil && !slices.ContainsFunc(j.Steps, func(step JobStep) bool { return step.Error == err.Error() }) {
+		j.Errors = append(j.Errors, err.Error())
+	}
+
+	j.State = JobStateSucceeded
+	if len(j.Errors) > 0 {
+		j.State = JobStateFailed
+	}
+
+	j.Finished = time.Now()
+}
+
+// newJobID returns a random job id.
+func newJobID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestJobRunner(t *testing.T) {
+	release := make(chan struct{})
+	runs := 0
+	runner := NewJobRunner(func(job *Job) error {
+		runs++
+		<-release
+		_ = job.Step(JobStepFetch, func() error { return nil })
+		if job.Trigger == JobTriggerTray {
+			return errNoWallpaper
+		}
+		return job.Step(JobStepRender, func() error { return errors.New("broken") })
+	}, NewEventBus())
+
+	events, cancel := runner.events.Subscribe()
+	defer cancel()
+
+	first, started := runner.Start(JobTriggerAPI)
+	if !started {
+		t.Fatalf("Start() did not start the first job")
+	}
+
+	second, started := runner.Start(JobTriggerAPI)
+	if started || second != first {
+		t.Errorf("Start() = %v, %t, want the running job to be coalesced", second.ID, started)
+	}
+
+	close(release)
+	first.Wait()
+
+	// the events carry the status of the job as of their publication
+	if event := <-events; event.Type != EventRefreshStarted || event.Data.(*Job).State != JobStateRunning || len(event.Data.(*Job).Steps) != 0 {
+		t.Errorf("Start() published %s %+v, want the running job", event.Type, event.Data)
+	}
+
+	if event := <-events; event.Type != EventRefreshFailed || event.Data.(*Job).State != JobStateFailed || len(event.Data.(*Job).Steps) != 2 {
+		t.Errorf("Start() published %s %+v, want the failed job", event.Type, event.Data)
+	}
+
+	got, ok := runner.Job(first.ID)
+	if !ok || got != first {
+		t.Fatalf("Job(%q) = %v, %t, want the job", first.ID, got, ok)
+	}
+
+	raw, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var document struct {
+		State     string    `json:"state"`
+		Steps     []JobStep `json:"steps"`
+		Errors    []string  `json:"errors"`
+		Coalesced int       `json:"coalesced"`
+	}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	if document.State != "failed" || len(document.Steps) != 2 || document.Steps[1].Error != "broken" || document.Coalesced != 1 || len(document.Errors) != 1 {
+		t.Errorf("Job() = %s, want a failed job with two steps coalescing one request", raw)
+	}
+
+	third, started := runner.Start(JobTriggerTray)
+	if !started || third == first {
+		t.Fatalf("Start() did not start a new job after the first one finished")
+	}
+
+	third.Wait()
+	if third.State != JobStateFailed || len(third.Errors) != 1 || third.Errors[0] != errNoWallpaper.Error() || runs != 2 {
+		t.Errorf("Start() = %+v, want failed job without wallpaper", third)
+	}
+
+	if _, ok := runner.Job("unknown"); ok {
+		t.Errorf("Job(unknown) found a job")
+	}
+}
+
+func TestJobRunnerQueue(t *testing.T) {
+	release := make(chan struct{})
+	var triggers []string
+	runner := NewJobRunner(func(job *Job) error {
+		triggers = append(triggers, job.Trigger)
+		<-release
+		return nil
+	}, nil)
+
+	running, started := runner.Queue(JobTriggerStartup)
+	if !started {
+		t.Fatalf("Queue() did not start a job while none is running")
+	}
+
+	queued, started := runner.Queue(JobTriggerAPI)
+	if started || queued == running || queued.snapshot().State != JobStateQueued {
+		t.Fatalf("Queue() = %+v, %t, want a queued job", queued.snapshot(), started)
+	}
+
+	if coalesced, _ := runner.Queue(JobTriggerAPI); coalesced != queued {
+		t.Errorf("Queue() did not coalesce the request into the queued job")
+	}
+
+	if coalesced, _ := runner.Start(JobTriggerAPI); coalesced != running {
+		t.Errorf("Start() did not coalesce the request into the running job")
+	}
+
+	close(release)
+	queued.Wait()
+	if queued.State != JobStateSucceeded || queued.Coalesced != 1 || running.Coalesced != 1 || len(triggers) != 2 || triggers[1] != JobTriggerAPI {
+		t.Errorf("Queue() = %+v after %v, want the queued job to run after the running one", queued, triggers)
+	}
+}
+
+func TestJobStep(t *testing.T) {
+	var job *Job
+	called := false
+	if err := job.Step(JobStepSet, func() error { called = true; return nil }); err != nil || !called {
+		t.Errorf("Step() on nil job = %v, called %t, want the step to run", err, called)
+	}
+
+	job = &Job{}
+	if err := job.Step(JobStepSet, func() error { return nil }); err != nil || len(job.Steps) != 1 || job.Steps[0].Name != JobStepSet {
+		t.Errorf("Step() = %v, %+v, want the step to be recorded", err, job.Steps)
+	}
+}
@@ -0,0 +1,240 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+	"github.com/spf13/pflag"
+)
+
+var _ pflag.Value = (*Messages)(nil)
+
+// markupTag matches the supported markup tags: [b], [/b], [color=#rrggbb] and [/color].
+var markupTag = regexp.MustCompile(`(?i)\[(/?)(b|color)(?:=([^\]]+))?\]`)
+
+type (
+	// Message is a custom text layer (e.g. message of the day) drawn on the wallpaper.
+	// The text is either provided inline or read from a file or an HTTP(S) URL (source) on every render.
+	// The text supports multiple lines and the markup tags [b]bold[/b] and [color=#rrggbb]colored[/color].
+	// The message is not drawn after it expired.
+	Message struct {
+		Text     string         `json:"text,omitempty"`
+		Source   string         `json:"source,omitempty"`
+		Position types.Position `json:"position"`
+		Expires  time.Time      `json:"expires,omitzero"`
+	}
+
+	// Messages is a list of messages.
+	Messages []Message
+)
+
+// Content returns the text of the message reading it from the source if provided.
+func (m Message) Content() (string, error) {
+	switch {
+	case m.Source == "":
+		return m.Text, nil
+
+	case strings.HasPrefix(m.Source, "http://"), strings.HasPrefix(m.Source, "https://"):
+		content, err := readResponse(client.Get(m.Source))
+		return strings.TrimRight(string(content), "\r\n"), err
+
+	default:
+		content, err := os.ReadFile(m.Source)
+		return strings.TrimRight(string(content), "\r\n"), err
+
+	}
+}
+
+// checkSources checks that the messages read their text only from the sources of the allowed messages.
+// The sources are read by the daemon on every render, hence new sources can be configured by flags only,
+// so that a config patch cannot make the daemon read local files or request arbitrary URLs.
+func (ms Messages) checkSources(allowed Messages) error {
+	for i, message := range ms {
+		if message.Source != "" && !slices.ContainsFunc(allowed, func(m Message) bool { return m.Source == message.Source }) {
+			return fmt.Errorf("message %d: source %q is not configured, only inline text can be added", i, message.Source)
+		}
+	}
+
+	return nil
+}
+
+// Expired returns true if the message expired at the given time.
+func (m Message) Expired(now time.Time) bool {
+	return !m.Expires.IsZero() && !now.Before(m.Expires)
+}
+
+// Set appends a message to the list.
+// The value is either a JSON object, a file path prefixed with @, an HTTP(S) URL or the text itself.
+// Messages which are not given as JSON object are drawn at the top center.
+func (ms *Messages) Set(value string) error {
+	message := Message{Position: types.PositionTopCenter}
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(value), "{"):
+		if err := json.Unmarshal([]byte(value), &message); err != nil {
+			return fmt.Errorf("invalid message: %w", err)
+		}
+
+	case strings.HasPrefix(value, "@"):
+		message.Source = strings.TrimPrefix(value, "@")
+
+	case strings.HasPrefix(value, "http://"), strings.HasPrefix(value, "https://"):
+		message.Source = value
+
+	default:
+		message.Text = value
+
+	}
+
+	if !types.AllowedPositions.Contains(message.Position) {
+		return fmt.Errorf("invalid message position: %d, expected any of: %s", message.Position, types.AllowedPositions)
+	}
+
+	*ms = append(*ms, message)
+	return nil
+}
+
+// String returns the JSON representation of the messages.
+func (ms Messages) String() string {
+	if len(ms) == 0 {
+		return ""
+	}
+
+	raw, _ := json.Marshal(ms)
+	return string(raw)
+}
+
+// Type returns the type of the messages.
+func (ms Messages) Type() string { return "messages" }
+
+// DrawMessages draws the messages, which did not expire, in boxes at their positions.
+// Messages at the same position are drawn in a single box separated by an empty line.
+// Messages, whose source cannot be read or whose markup is invalid, are skipped.
+func (img *Image) DrawMessages(messages Messages, now time.Time, fontName string) error {
+	defer renderDuration.ObserveSince(time.Now(), "messages")
+
+	var positions []types.Position
+	texts := make(map[types.Position][]string)
+	spans := make(map[types.Position][]textSpan)
+	for _, message := range messages {
+		if message.Expired(now) {
+			continue
+		}
+
+		content, err := message.Content()
+		if err != nil {
+			logger.Logger.Printf("Skipping message from %s: %v", message.Source, err)
+			continue
+		}
+
+		text, styled, err := parseMarkup(content)
+		if err != nil {
+			logger.Logger.Printf("Skipping message with invalid markup: %v", err)
+			continue
+		}
+
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		offset := 0
+		if previous, ok := texts[message.Position]; ok {
+			offset = strings.Count(strings.Join(previous, "\n\n"), "\n") + 2
+		} else {
+			positions = append(positions, message.Position)
+		}
+
+		for _, span := range styled {
+			span.line += offset
+			spans[message.Position] = append(spans[message.Position], span)
+		}
+
+		texts[message.Position] = append(texts[message.Position], text)
+	}
+
+	for _, position := range positions {
+		if err := img.drawTextBox(strings.Join(texts[position], "\n\n"), position, fontName, spans[position]...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseMarkup strips the markup tags from the text and returns the styled spans.
+// Unclosed tags apply until the end of the text, unknown tags are kept as they are.
+func parseMarkup(text string) (string, []textSpan, error) {
+	type style struct {
+		bold  int
+		color []types.Color
+	}
+
+	var plain strings.Builder
+	var spans []textSpan
+	var current style
+	line, column := 0, 0
+
+	// write appends the text in the current style
+	write := func(s string) {
+		for i, part := range strings.Split(s, "\n") {
+			if i > 0 {
+				plain.WriteString("\n")
+				line, column = line+1, 0
+			}
+
+			length := len([]rune(part))
+			if length > 0 && (current.bold > 0 || len(current.color) > 0) {
+				span := textSpan{line: line, column: column, length: length, bold: current.bold > 0}
+				if len(current.color) > 0 {
+					span.color = current.color[len(current.color)-1]
+				}
+
+				spans = append(spans, span)
+			}
+
+			plain.WriteString(part)
+			column += length
+		}
+	}
+
+	last := 0
+	for _, match := range markupTag.FindAllStringSubmatchIndex(text, -1) {
+		write(text[last:match[0]])
+		last = match[1]
+
+		closing, tag := text[match[2]:match[3]] == "/", strings.ToLower(text[match[4]:match[5]])
+		switch {
+		case tag == "b" && closing:
+			current.bold = max(0, current.bold-1)
+
+		case tag == "b":
+			current.bold++
+
+		case tag == "color" && closing:
+			if len(current.color) > 0 {
+				current.color = current.color[:len(current.color)-1]
+			}
+
+		case tag == "color" && match[6] >= 0:
+			var c types.Color
+			if err := c.Set(text[match[6]:match[7]]); err != nil {
+				return "", nil, err
+			}
+
+			current.color = append(current.color, c)
+
+		default:
+			write(text[match[0]:match[1]])
+
+		}
+	}
+
+	write(text[last:])
+	return plain.String(), spans, nil
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/extras"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+)
+
+func TestParseMarkup(t *testing.T) {
+	red := types.Color{R: 0xff, A: 0xff}
+	for _, tt := range []struct {
+		name      string
+		args      string
+		want      string
+		wantSpans []textSpan
+		wantErr   bool
+	}{
+		{"test#1", "plain text", "plain text", nil, false},
+		{"test#2", "On-call: [b]Alice[/b]", "On-call: Alice", []textSpan{{line: 0, column: 9, length: 5, bold: true}}, false},
+		{"test#3", "[color=#f00]Freeze\nuntil [b]Friday[/b][/color]!", "Freeze\nuntil Friday!", []textSpan{
+			{line: 0, column: 0, length: 6, color: red},
+			{line: 1, column: 0, length: 6, color: red},
+			{line: 1, column: 6, length: 6, bold: true, color: red},
+		}, false},
+		{"test#4", "[INFO] [B]unclosed", "[INFO] unclosed", []textSpan{{line: 0, column: 7, length: 8, bold: true}}, false},
+		{"test#5", "[color=nope]text[/color]", "", nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotSpans, err := parseMarkup(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseMarkup(%q) error = %v, wantErr %t", tt.args, err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want || !reflect.DeepEqual(gotSpans, tt.wantSpans) {
+				t.Errorf("parseMarkup(%q) = %q, %+v, want %q, %+v", tt.args, got, gotSpans, tt.want, tt.wantSpans)
+			}
+		})
+	}
+}
+
+func TestMessagesSet(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		args    string
+		want    Message
+		wantErr bool
+	}{
+		{"test#1", "Release freeze", Message{Text: "Release freeze", Position: types.PositionTopCenter}, false},
+		{"test#2", "@/etc/motd", Message{Source: "/etc/motd", Position: types.PositionTopCenter}, false},
+		{"test#3", "https://example.com/motd.txt", Message{Source: "https://example.com/motd.txt", Position: types.PositionTopCenter}, false},
+		{"test#4", `{"text": "On-call", "position": 3, "expires": "2024-12-24T00:00:00Z"}`, Message{Text: "On-call", Position: types.PositionBottomRight, Expires: time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)}, false},
+		{"test#5", `{"text": "On-call", "position": 42}`, Message{}, true},
+		{"test#6", `{"text": `, Message{}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Messages
+			err := got.Set(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Set(%q) error = %v, wantErr %t", tt.args, err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && (len(got) != 1 || !reflect.DeepEqual(got[0], tt.want)) {
+				t.Errorf("Set(%q) = %+v, want %+v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageContent(t *testing.T) {
+	content := "Deploy window\nSaturday 10:00"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, content)
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "motd.txt")
+	if err := os.WriteFile(path, []byte(content+"\r\n"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		args    Message
+		want    string
+		wantErr bool
+	}{
+		{"test#1", Message{Text: content}, content, false},
+		{"test#2", Message{Source: path}, content, false},
+		{"test#3", Message{Source: server.URL}, content, false},
+		{"test#4", Message{Source: filepath.Join(t.TempDir(), "missing.txt")}, "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.args.Content()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Content() error = %v, wantErr %t", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("Content() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrawMessages(t *testing.T) {
+	img := SetupTestImage(t)
+	now := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+
+	for _, tt := range []struct {
+		name     string
+		args     Messages
+		wantDraw bool
+	}{
+		{"test#1", Messages{{Text: "[b]On-call:[/b] Alice\n[color=#ff8800]Release freeze[/color]", Position: types.PositionTopCenter}}, true},
+		{"test#2", Messages{{Text: "First", Position: types.PositionCenter}, {Text: "Second", Position: types.PositionCenter}}, true},
+		{"test#3", Messages{{Text: "Expired", Expires: now}}, false},
+		{"test#4", Messages{{Source: missing}}, false},
+		{"test#5", Messages{{Text: "[color=nope]Invalid[/color]"}}, false},
+		{"test#6", Messages{{Text: "Upcoming", Position: types.PositionBottomCenter, Expires: now.Add(time.Hour)}}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SetupTestImage(t)
+
+			if err := got.DrawMessages(tt.args, now, extras.DefaultFontName); err != nil {
+				t.Errorf("DrawMessages() error = %v", err)
+				return
+			}
+
+			if tt.wantDraw == got.Equals(img) {
+				t.Errorf("DrawMessages() drawn = %t, want %t", !got.Equals(img), tt.wantDraw)
+			}
+		})
+	}
+
+	if err := SetupTestImage(t).DrawMessages(Messages{{Text: "Invalid", Position: types.Position(-1)}}, now, extras.DefaultFontName); err == nil {
+		t.Error("DrawMessages() error = nil, want error for invalid position")
+	}
+}
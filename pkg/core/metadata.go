@@ -0,0 +1,716 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// pngSignature is the signature every PNG stream starts with.
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// maxTextChunkSize limits the size of the text chunks read from PNG streams (compressed and decompressed),
+// so that a malformed file cannot force a huge allocation.
+const maxTextChunkSize = 1 << 20
+
+// xmpKeyword is the keyword of the iTXt chunk holding the XMP packet.
+const xmpKeyword = "XML:com.adobe.xmp"
+
+// Identifiers of the JPEG APP1 segments holding the EXIF data and the XMP packet.
+const (
+	exifIdentifier = "Exif\x00\x00"
+	xmpIdentifier  = "http://ns.adobe.com/xap/1.0/\x00"
+)
+
+// EXIF tags of the IFD0 written into JPEG images.
+const (
+	exifTagImageDescription = 0x010e
+	exifTagDateTime         = 0x0132
+	exifTagCopyright        = 0x8298
+)
+
+// XMP namespaces used to describe the wallpaper.
+const (
+	xmpNamespaceDC   = "http://purl.org/dc/elements/1.1/"
+	xmpNamespaceXMP  = "http://ns.adobe.com/xap/1.0/"
+	xmpNamespaceBing = "https://github.com/sarumaj/bing-wallpaper-changer/ns/1.0/"
+	xmpNamespaceRDF  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+)
+
+type (
+	// Metadata describes the wallpaper.
+	// It is embedded into the saved images, so that they remain self-describing once copied anywhere.
+	Metadata struct {
+		Title       string    `json:"title,omitempty"`
+		Copyright   string    `json:"copyright,omitempty"`
+		Description string    `json:"description,omitempty"`
+		Translation string    `json:"translation,omitempty"`
+		Region      string    `json:"region,omitempty"`
+		ID          string    `json:"id,omitempty"`
+		Date        time.Time `json:"date,omitzero"`
+		SearchURL   string    `json:"searchUrl,omitempty"`
+		DownloadURL string    `json:"downloadUrl,omitempty"`
+	}
+
+	// xmpPacket is the subset of an XMP packet read back from the saved images.
+	xmpPacket struct {
+		Descriptions []struct {
+			Title       xmpAlternatives `xml:"http://purl.org/dc/elements/1.1/ title"`
+			Rights      xmpAlternatives `xml:"http://purl.org/dc/elements/1.1/ rights"`
+			Description xmpAlternatives `xml:"http://purl.org/dc/elements/1.1/ description"`
+			Identifier  string          `xml:"http://purl.org/dc/elements/1.1/ identifier"`
+			Source      string          `xml:"http://purl.org/dc/elements/1.1/ source"`
+			CreateDate  string          `xml:"http://ns.adobe.com/xap/1.0/ CreateDate"`
+			Region      string          `xml:"https://github.com/sarumaj/bing-wallpaper-changer/ns/1.0/ Region"`
+			SearchURL   string          `xml:"https://github.com/sarumaj/bing-wallpaper-changer/ns/1.0/ SearchURL"`
+		} `xml:"RDF>Description"`
+	}
+
+	// xmpAlternatives is a language alternative (rdf:Alt) of an XMP property.
+	xmpAlternatives struct {
+		Items []struct {
+			Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Alt>li"`
+	}
+)
+
+// Metadata returns the metadata of the image.
+func (img *Image) Metadata() Metadata {
+	description := img.Description
+	if img.Translation != "" {
+		description = strings.TrimSuffix(description, "\n"+img.Translation)
+	}
+
+	var region string
+	if img.Region != (Image{}).Region {
+		region = img.Region.String()
+	}
+
+	return Metadata{
+		Title:       img.Title,
+		Copyright:   img.Copyright,
+		Description: description,
+		Translation: img.Translation,
+		Region:      region,
+		ID:          img.ID,
+		Date:        img.Date,
+		SearchURL:   img.SearchURL,
+		DownloadURL: img.DownloadURL,
+	}
+}
+
+// ReadMetadata reads the metadata embedded into the image at the given path.
+func ReadMetadata(path string) (*Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	signature, _ := r.Peek(len(pngSignature))
+	switch {
+	case bytes.HasPrefix(signature, []byte(pngSignature)):
+		return readPNGMetadata(r)
+
+	case bytes.HasPrefix(signature, []byte{0xff, 0xd8}):
+		return readJPEGMetadata(r)
+
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", path)
+
+	}
+}
+
+// encodeJPEG encodes the image as JPEG with the metadata embedded as EXIF data and as XMP packet.
+func (img *Image) encodeJPEG(w io.Writer, quality int) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return err
+	}
+
+	// the segments are inserted right after the SOI marker
+	raw := buf.Bytes()
+	if _, err := w.Write(raw[:2]); err != nil {
+		return err
+	}
+
+	metadata := img.Metadata()
+	for _, payload := range [][]byte{
+		append([]byte(exifIdentifier), metadata.exif()...),
+		append([]byte(xmpIdentifier), metadata.xmp()...),
+	} {
+		if err := writeSegment(w, 0xe1, payload); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(raw[2:])
+	return err
+}
+
+// encodePNG encodes the image as PNG with the metadata embedded as iTXt chunks and as XMP packet.
+func (img *Image) encodePNG(w io.Writer, level png.CompressionLevel) error {
+	var buf bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: level}).Encode(&buf, img); err != nil {
+		return err
+	}
+
+	// the chunks are inserted right after the IHDR chunk (length, type, 13 bytes of data and CRC)
+	raw := buf.Bytes()
+	headerEnd := len(pngSignature) + 4 + 4 + 13 + 4
+	if _, err := w.Write(raw[:headerEnd]); err != nil {
+		return err
+	}
+
+	metadata := img.Metadata()
+	for _, entry := range metadata.entries() {
+		if err := writeChunk(w, "iTXt", iTXt(entry[0], entry[1])); err != nil {
+			return err
+		}
+	}
+
+	if err := writeChunk(w, "iTXt", iTXt(xmpKeyword, metadata.xmp())); err != nil {
+		return err
+	}
+
+	_, err := w.Write(raw[headerEnd:])
+	return err
+}
+
+// entries returns the keywords and values of the non-empty metadata fields.
+// The keywords of the PNG specification are used where applicable.
+func (m Metadata) entries() [][2]string {
+	var date string
+	if !m.Date.IsZero() {
+		date = m.Date.Format(time.RFC1123)
+	}
+
+	var entries [][2]string
+	for _, entry := range [][2]string{
+		{"Title", m.Title},
+		{"Copyright", m.Copyright},
+		{"Description", m.Description},
+		{"Translation", m.Translation},
+		{"Region", m.Region},
+		{"Bing ID", m.ID},
+		{"Creation Time", date},
+		{"Search URL", m.SearchURL},
+		{"Source", m.DownloadURL},
+	} {
+		if entry[1] != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// set sets the metadata field of the given keyword.
+func (m *Metadata) set(keyword, value string) {
+	switch keyword {
+	case "Title":
+		m.Title = value
+
+	case "Copyright":
+		m.Copyright = value
+
+	case "Description":
+		m.Description = value
+
+	case "Translation":
+		m.Translation = value
+
+	case "Region":
+		m.Region = value
+
+	case "Bing ID":
+		m.ID = value
+
+	case "Creation Time":
+		m.Date = parseMetadataDate(value)
+
+	case "Search URL":
+		m.SearchURL = value
+
+	case "Source":
+		m.DownloadURL = value
+
+	}
+}
+
+// merge fills the empty fields of the receiver with the fields of the other metadata.
+func (m *Metadata) merge(other Metadata) {
+	for _, entry := range other.entries() {
+		if m.fieldEmpty(entry[0]) {
+			m.set(entry[0], entry[1])
+		}
+	}
+}
+
+// fieldEmpty returns true if the metadata field of the given keyword is empty.
+func (m Metadata) fieldEmpty(keyword string) bool {
+	for _, entry := range m.entries() {
+		if entry[0] == keyword {
+			return false
+		}
+	}
+
+	return true
+}
+
+// exif returns the little-endian TIFF structure with the IFD0 holding the description, the date and the copyright.
+func (m Metadata) exif() []byte {
+	var date string
+	if !m.Date.IsZero() {
+		date = m.Date.Format("2006:01:02 15:04:05")
+	}
+
+	var tags [][]byte
+	var values [][]byte
+	for _, entry := range []struct {
+		tag   uint16
+		value string
+	}{
+		{exifTagImageDescription, m.Description},
+		{exifTagDateTime, date},
+		{exifTagCopyright, m.Copyright},
+	} {
+		if entry.value != "" {
+			tags = append(tags, binary.LittleEndian.AppendUint16(nil, entry.tag))
+			values = append(values, append([]byte(entry.value), 0))
+		}
+	}
+
+	// header, number of entries, entries and offset of the next IFD
+	data := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(tags)))
+	offset := uint32(len(data) + len(tags)*12 + 4)
+	for i, tag := range tags {
+		data = append(data, tag...)
+		data = binary.LittleEndian.AppendUint16(data, 2) // ASCII
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(values[i])))
+		if len(values[i]) <= 4 {
+			data = append(data, make([]byte, 4)...)
+			copy(data[len(data)-4:], values[i])
+			continue
+		}
+
+		data = binary.LittleEndian.AppendUint32(data, offset)
+		offset += uint32(len(values[i]))
+	}
+
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	for _, value := range values {
+		if len(value) > 4 {
+			data = append(data, value...)
+		}
+	}
+
+	return data
+}
+
+// parseEXIF parses the description, the date and the copyright from the IFD0 of the TIFF structure.
+func parseEXIF(data []byte) (Metadata, error) {
+	var order binary.ByteOrder
+	switch {
+	case len(data) < 8:
+		return Metadata{}, fmt.Errorf("malformed EXIF data: truncated header")
+
+	case bytes.HasPrefix(data, []byte("II*\x00")):
+		order = binary.LittleEndian
+
+	case bytes.HasPrefix(data, []byte("MM\x00*")):
+		order = binary.BigEndian
+
+	default:
+		return Metadata{}, fmt.Errorf("malformed EXIF data: invalid header")
+
+	}
+
+	offset := int(order.Uint32(data[4:8]))
+	if offset+2 > len(data) {
+		return Metadata{}, fmt.Errorf("malformed EXIF data: IFD0 out of bounds")
+	}
+
+	var m Metadata
+	count := int(order.Uint16(data[offset:]))
+	for i := range count {
+		entry := offset + 2 + i*12
+		if entry+12 > len(data) {
+			return Metadata{}, fmt.Errorf("malformed EXIF data: entry out of bounds")
+		}
+
+		tag, kind, length := order.Uint16(data[entry:]), order.Uint16(data[entry+2:]), int(order.Uint32(data[entry+4:]))
+		if kind != 2 || (tag != exifTagImageDescription && tag != exifTagDateTime && tag != exifTagCopyright) {
+			continue
+		}
+
+		value := data[entry+8 : entry+12]
+		if length > 4 {
+			start := int(order.Uint32(data[entry+8:]))
+			if start+length > len(data) {
+				return Metadata{}, fmt.Errorf("malformed EXIF data: value out of bounds")
+			}
+			value = data[start : start+length]
+		}
+
+		text := strings.TrimRight(string(value[:min(length, len(value))]), "\x00")
+		switch tag {
+		case exifTagImageDescription:
+			m.Description = text
+
+		case exifTagDateTime:
+			m.Date = parseMetadataDate(text)
+
+		case exifTagCopyright:
+			m.Copyright = text
+
+		}
+	}
+
+	return m, nil
+}
+
+// xmp returns the XMP packet of the metadata.
+func (m Metadata) xmp() string {
+	var b strings.Builder
+	property := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "   <%s>%s</%s>\n", name, xmlEscape(value), name)
+		}
+	}
+
+	alternatives := func(name string, values ...string) {
+		if values[0] == "" {
+			return
+		}
+
+		fmt.Fprintf(&b, "   <%s><rdf:Alt>", name)
+		for i, lang := range []string{"x-default", "en-US"}[:len(values)] {
+			if values[i] != "" {
+				fmt.Fprintf(&b, `<rdf:li xml:lang="%s">%s</rdf:li>`, lang, xmlEscape(values[i]))
+			}
+		}
+		fmt.Fprintf(&b, "</rdf:Alt></%s>\n", name)
+	}
+
+	var date string
+	if !m.Date.IsZero() {
+		date = m.Date.Format(time.DateOnly)
+	}
+
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	fmt.Fprintf(&b, " <rdf:RDF xmlns:rdf=%q>\n", xmpNamespaceRDF)
+	fmt.Fprintf(&b, "  <rdf:Description rdf:about=\"\" xmlns:dc=%q xmlns:xmp=%q xmlns:bing=%q>\n", xmpNamespaceDC, xmpNamespaceXMP, xmpNamespaceBing)
+	alternatives("dc:title", m.Title)
+	alternatives("dc:rights", m.Copyright)
+	alternatives("dc:description", m.Description, m.Translation)
+	property("dc:identifier", m.ID)
+	property("dc:source", m.DownloadURL)
+	property("xmp:CreateDate", date)
+	property("bing:Region", m.Region)
+	property("bing:SearchURL", m.SearchURL)
+	b.WriteString("  </rdf:Description>\n")
+	b.WriteString(" </rdf:RDF>\n")
+	b.WriteString("</x:xmpmeta>\n")
+	b.WriteString("<?xpacket end=\"w\"?>")
+
+	return b.String()
+}
+
+// parseXMP parses the metadata from the XMP packet.
+func parseXMP(packet []byte) (Metadata, error) {
+	var parsed xmpPacket
+	if err := xml.Unmarshal(packet, &parsed); err != nil {
+		return Metadata{}, fmt.Errorf("malformed XMP packet: %w", err)
+	}
+
+	var m Metadata
+	for _, d := range parsed.Descriptions {
+		m.merge(Metadata{
+			Title:       d.Title.get("x-default"),
+			Copyright:   d.Rights.get("x-default"),
+			Description: d.Description.get("x-default"),
+			Translation: d.Description.get("en-US"),
+			Region:      strings.TrimSpace(d.Region),
+			ID:          strings.TrimSpace(d.Identifier),
+			Date:        parseMetadataDate(d.CreateDate),
+			SearchURL:   strings.TrimSpace(d.SearchURL),
+			DownloadURL: strings.TrimSpace(d.Source),
+		})
+	}
+
+	return m, nil
+}
+
+// get returns the value of the given language.
+func (a xmpAlternatives) get(lang string) string {
+	for _, item := range a.Items {
+		if strings.EqualFold(item.Lang, lang) {
+			return item.Value
+		}
+	}
+
+	return ""
+}
+
+// readPNGMetadata reads the metadata from the tEXt, zTXt and iTXt chunks of the PNG stream.
+// The text chunks take precedence over the XMP packet.
+func readPNGMetadata(r io.Reader) (*Metadata, error) {
+	if _, err := io.CopyN(io.Discard, r, int64(len(pngSignature))); err != nil {
+		return nil, err
+	}
+
+	var m, fromXMP Metadata
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, fmt.Errorf("malformed PNG stream: %w", err)
+		}
+
+		length, chunkType := binary.BigEndian.Uint32(header[:4]), string(header[4:])
+		if chunkType == "IEND" {
+			break
+		}
+
+		// the metadata written by this application precedes the image data, which does not need to be read then
+		if chunkType == "IDAT" && (m != Metadata{} || fromXMP != Metadata{}) {
+			break
+		}
+
+		if chunkType != "tEXt" && chunkType != "zTXt" && chunkType != "iTXt" {
+			if _, err := io.CopyN(io.Discard, r, int64(length)+4); err != nil {
+				return nil, fmt.Errorf("malformed PNG stream: %w", err)
+			}
+			continue
+		}
+
+		if length > maxTextChunkSize {
+			return nil, fmt.Errorf("malformed PNG stream: %s chunk of %d bytes exceeds the limit of %d bytes", chunkType, length, maxTextChunkSize)
+		}
+
+		data := make([]byte, int(length)+4)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("malformed PNG stream: %w", err)
+		}
+
+		if crc32.Update(crc32.ChecksumIEEE(header[4:]), crc32.IEEETable, data[:length]) != binary.BigEndian.Uint32(data[length:]) {
+			return nil, fmt.Errorf("malformed PNG stream: checksum mismatch in %s chunk", chunkType)
+		}
+
+		keyword, value, err := parseTextChunk(chunkType, data[:length])
+		if err != nil {
+			return nil, err
+		}
+
+		if keyword == xmpKeyword {
+			if fromXMP, err = parseXMP([]byte(value)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		m.set(keyword, value)
+	}
+
+	m.merge(fromXMP)
+	return &m, nil
+}
+
+// readJPEGMetadata reads the metadata from the XMP packet and the EXIF data of the JPEG stream.
+// The XMP packet takes precedence over the EXIF data.
+func readJPEGMetadata(r io.Reader) (*Metadata, error) {
+	if _, err := io.CopyN(io.Discard, r, 2); err != nil {
+		return nil, err
+	}
+
+	var m, fromEXIF Metadata
+	for {
+		var marker [2]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, fmt.Errorf("malformed JPEG stream: %w", err)
+		}
+
+		if marker[0] != 0xff {
+			return nil, fmt.Errorf("malformed JPEG stream: invalid marker %x", marker)
+		}
+
+		// the metadata precedes the image data (SOS) and the end of the image (EOI)
+		if marker[1] == 0xda || marker[1] == 0xd9 {
+			break
+		}
+
+		// markers without a segment
+		if marker[1] == 0x01 || (marker[1] >= 0xd0 && marker[1] <= 0xd7) {
+			continue
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return nil, fmt.Errorf("malformed JPEG stream: %w", err)
+		}
+
+		size := int(binary.BigEndian.Uint16(length[:])) - 2
+		if size < 0 {
+			return nil, fmt.Errorf("malformed JPEG stream: invalid segment length")
+		}
+
+		if marker[1] != 0xe1 {
+			if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+				return nil, fmt.Errorf("malformed JPEG stream: %w", err)
+			}
+			continue
+		}
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, fmt.Errorf("malformed JPEG stream: %w", err)
+		}
+
+		var err error
+		switch {
+		case bytes.HasPrefix(payload, []byte(exifIdentifier)):
+			fromEXIF, err = parseEXIF(payload[len(exifIdentifier):])
+
+		case bytes.HasPrefix(payload, []byte(xmpIdentifier)):
+			m, err = parseXMP(payload[len(xmpIdentifier):])
+
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m.merge(fromEXIF)
+	return &m, nil
+}
+
+// parseTextChunk returns the keyword and the text of a tEXt, zTXt or iTXt chunk.
+func parseTextChunk(chunkType string, data []byte) (string, string, error) {
+	keyword, rest, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return "", "", fmt.Errorf("malformed %s chunk", chunkType)
+	}
+
+	compressed := chunkType == "zTXt"
+	switch chunkType {
+	case "tEXt":
+		return string(keyword), latin1(rest), nil
+
+	case "zTXt":
+		if len(rest) < 1 {
+			return "", "", fmt.Errorf("malformed %s chunk", chunkType)
+		}
+		rest = rest[1:]
+
+	case "iTXt":
+		if len(rest) < 2 {
+			return "", "", fmt.Errorf("malformed %s chunk", chunkType)
+		}
+		compressed = rest[0] == 1
+
+		// skip the compression flag and method, the language tag and the translated keyword
+		fields := bytes.SplitN(rest[2:], []byte{0}, 3)
+		if len(fields) != 3 {
+			return "", "", fmt.Errorf("malformed %s chunk", chunkType)
+		}
+		rest = fields[2]
+
+	}
+
+	if compressed {
+		zr, err := zlib.NewReader(bytes.NewReader(rest))
+		if err != nil {
+			return "", "", fmt.Errorf("malformed %s chunk: %w", chunkType, err)
+		}
+		defer zr.Close()
+
+		if rest, err = io.ReadAll(io.LimitReader(zr, maxTextChunkSize+1)); err != nil {
+			return "", "", fmt.Errorf("malformed %s chunk: %w", chunkType, err)
+		}
+
+		if len(rest) > maxTextChunkSize {
+			return "", "", fmt.Errorf("malformed %s chunk: decompressed text exceeds the limit of %d bytes", chunkType, maxTextChunkSize)
+		}
+	}
+
+	if chunkType == "zTXt" {
+		return string(keyword), latin1(rest), nil
+	}
+
+	return string(keyword), string(rest), nil
+}
+
+// iTXt returns the data of an uncompressed iTXt chunk without language tag.
+func iTXt(keyword, text string) []byte {
+	data := make([]byte, 0, len(keyword)+len(text)+5)
+	data = append(data, keyword...)
+	data = append(data, 0, 0, 0, 0, 0)
+	return append(data, text...)
+}
+
+// writeChunk writes a PNG chunk with its length and CRC.
+func writeChunk(w io.Writer, chunkType string, data []byte) error {
+	chunk := make([]byte, 8, len(data)+12)
+	binary.BigEndian.PutUint32(chunk[:4], uint32(len(data)))
+	copy(chunk[4:], chunkType)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	_, err := w.Write(chunk)
+	return err
+}
+
+// writeSegment writes a JPEG segment with its marker and length.
+func writeSegment(w io.Writer, marker byte, payload []byte) error {
+	if len(payload)+2 > 0xffff {
+		return fmt.Errorf("segment too large: %d bytes", len(payload))
+	}
+
+	segment := []byte{0xff, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	_, err := w.Write(append(segment, payload...))
+	return err
+}
+
+// latin1 decodes the ISO 8859-1 encoded text.
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+
+	return string(runes)
+}
+
+// parseMetadataDate parses the date written into the metadata.
+func parseMetadataDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC1123, time.RFC3339, time.DateOnly, "2006:01:02 15:04:05"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+
+	return time.Time{}
+}
+
+// xmlEscape escapes the text to be embedded into XML.
+func xmlEscape(text string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
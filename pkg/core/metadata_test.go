@@ -0,0 +1,140 @@
+package core
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadMetadata(t *testing.T) {
+	img := SetupTestImage(t)
+	img.Translation = "Paradise in Greek, Chora, Folegandros Island, Cyclades, Greece (© Francesco Riccardo Iacomino/Getty Images)"
+	img.Description += "\n" + img.Translation
+
+	want := img.Metadata()
+	if want.Description != "Paradies auf Griechisch, "+img.Copyright || want.Region != "de-DE" {
+		t.Errorf("Metadata() = %+v, want description without translation", want)
+	}
+
+	for _, tt := range []struct {
+		name string
+		args EncodeOptions
+	}{
+		{"test#1", EncodeOptions{Format: OutputFormatPNG}},
+		{"test#2", EncodeOptions{Format: OutputFormatJPEG, JPEGQuality: DefaultJPEGQuality}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := img.EncodeAndDump(t.TempDir(), tt.args)
+			if err != nil {
+				t.Fatalf("EncodeAndDump() error = %v", err)
+			}
+
+			got, err := ReadMetadata(path)
+			if err != nil {
+				t.Fatalf("ReadMetadata() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("ReadMetadata() = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestParseEXIF(t *testing.T) {
+	metadata := Metadata{
+		Title:       "Ignored",
+		Copyright:   "© Someone",
+		Description: "Tom & Jerry",
+		Date:        time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC),
+	}
+
+	got, err := parseEXIF(metadata.exif())
+	if err != nil {
+		t.Fatalf("parseEXIF() error = %v", err)
+	}
+
+	if want := (Metadata{Copyright: metadata.Copyright, Description: metadata.Description, Date: metadata.Date}); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseEXIF() = %+v, want %+v", got, want)
+	}
+
+	if got, err := parseEXIF((Metadata{Copyright: "abc"}).exif()); err != nil || got.Copyright != "abc" {
+		t.Errorf("parseEXIF() = %+v, %v, want inline value", got, err)
+	}
+
+	for _, data := range [][]byte{nil, []byte("II*\x00\xff\x00\x00\x00"), []byte("XX*\x00\x08\x00\x00\x00")} {
+		if _, err := parseEXIF(data); err == nil {
+			t.Errorf("parseEXIF(%q) error = nil, want error", data)
+		}
+	}
+}
+
+func TestParseXMP(t *testing.T) {
+	want := Metadata{
+		Title:       `Tom & "Jerry" <3`,
+		Copyright:   "© Someone",
+		Description: "Beschreibung",
+		Translation: "Description",
+		Region:      "ja-JP",
+		ID:          "OHR.Example",
+		Date:        time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC),
+		SearchURL:   "https://www.bing.com/search?q=a&b=c",
+		DownloadURL: "https://www.bing.com/th?id=OHR.Example_1920x1080.jpg",
+	}
+
+	got, err := parseXMP([]byte(want.xmp()))
+	if err != nil {
+		t.Fatalf("parseXMP() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseXMP() = %+v, want %+v", got, want)
+	}
+
+	if _, err := parseXMP([]byte("<x:xmpmeta")); err == nil {
+		t.Error("parseXMP() error = nil, want error for malformed packet")
+	}
+}
+
+func TestReadMetadataErrors(t *testing.T) {
+	dir := t.TempDir()
+	img := SetupTestImage(t)
+
+	var buf bytes.Buffer
+	if err := img.encodePNG(&buf, png.DefaultCompression); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupted := bytes.Clone(buf.Bytes())
+	corrupted[len(pngSignature)+25+20] ^= 0xff
+
+	for _, tt := range []struct {
+		name    string
+		content []byte
+	}{
+		{"test#1", []byte("not an image")},
+		{"test#2", buf.Bytes()[:len(pngSignature)+30]},
+		{"test#3", corrupted},
+		{"test#4", []byte{0xff, 0xd8, 0xff, 0xe1, 0x00}},
+		{"test#5", []byte(pngSignature + "\xff\xff\xff\xf0tEXt")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".png")
+			if err := os.WriteFile(path, tt.content, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := ReadMetadata(path); err == nil {
+				t.Errorf("ReadMetadata(%s) error = nil, want error", tt.name)
+			}
+		})
+	}
+
+	if _, err := ReadMetadata(filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("ReadMetadata() error = nil, want error for missing file")
+	}
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsNamespace is the prefix of the names of the metrics.
+const metricsNamespace = "bing_wallpaper_changer_"
+
+// durationBuckets are the upper bounds of the buckets of the duration histograms in seconds.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// The metrics of the application exposed in the Prometheus text format (see WriteMetrics).
+var (
+	httpRequests        = newMetric("counter", "http_requests_total", "The HTTP requests by host and status code, the status is error if no response has been received.", nil, "host", "status")
+	httpRequestDuration = newMetric("histogram", "http_request_duration_seconds", "The duration of the HTTP requests by host.", durationBuckets, "host")
+	httpRequestRetries  = newMetric("counter", "http_request_retries_total", "The retried HTTP requests by host.", nil, "host")
+	downloadBytes       = newMetric("counter", "download_bytes_total", "The bytes of the downloaded wallpapers.", nil)
+	decodeDuration      = newMetric("histogram", "decode_duration_seconds", "The duration of the decoding of the downloaded wallpapers.", durationBuckets)
+	renderDuration      = newMetric("histogram", "render_duration_seconds", "The duration of the rendering by layer.", durationBuckets, "layer")
+	providerCalls       = newMetric("counter", "provider_calls_total", "The calls of the translation, text-to-speech and furigana providers.", nil, "service", "provider")
+	providerFailures    = newMetric("counter", "provider_failures_total", "The failed calls of the translation, text-to-speech and furigana providers.", nil, "service", "provider")
+	wallpaperSets       = newMetric("counter", "wallpaper_set_total", "The attempts to set the wallpaper by result (success or failure).", nil, "result")
+	refreshes           = newMetric("counter", "refresh_total", "The finished refresh jobs by trigger and state.", nil, "trigger", "state")
+	refreshDuration     = newMetric("histogram", "refresh_duration_seconds", "The duration of the refresh jobs by trigger.", durationBuckets, "trigger")
+	lastRefreshSuccess  = newMetric("gauge", "last_refresh_success_timestamp_seconds", "The time of the last successful refresh job as Unix timestamp, 0 if none has succeeded yet.", nil)
+)
+
+type (
+	// metric is a family of time series of the same name and type, which differ by the values of their labels.
+	metric struct {
+		kind    string
+		name    string
+		help    string
+		labels  []string
+		buckets []float64
+
+		mu     sync.Mutex
+		series map[string]*series
+	}
+
+	// series is a time series of a metric.
+	series struct {
+		labels []string
+		value  float64
+		counts []uint64 // the observations per bucket (not cumulative)
+		count  uint64
+	}
+
+	// metricsTransport records the requests of the HTTP client.
+	metricsTransport struct {
+		next http.RoundTripper
+	}
+)
+
+// metricsRegistry lists the metrics in the order of their registration.
+var metricsRegistry []*metric
+
+// newMetric registers the metric of the given kind (counter, gauge or histogram).
+// Metrics without labels are exposed from the start.
+func newMetric(kind, name, help string, buckets []float64, labels ...string) *metric {
+	m := &metric{kind: kind, name: metricsNamespace + name, help: help, labels: labels, buckets: buckets, series: make(map[string]*series)}
+	if len(labels) == 0 {
+		m.with(nil)
+	}
+
+	metricsRegistry = append(metricsRegistry, m)
+	return m
+}
+
+// with returns the time series of the label values, the lock must be held.
+func (m *metric) with(values []string) *series {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", m.name, len(m.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labels: values, counts: make([]uint64, len(m.buckets))}
+		m.series[key] = s
+	}
+
+	return s
+}
+
+// Add adds the delta to the counter or gauge.
+func (m *metric) Add(delta float64, values ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.with(values).value += delta
+}
+
+// Set sets the gauge.
+func (m *metric) Set(value float64, values ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.with(values).value = value
+}
+
+// Observe records the observation in the histogram.
+func (m *metric) Observe(value float64, values ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.with(values)
+	s.value += value
+	s.count++
+	if i, _ := slices.BinarySearch(m.buckets, value); i < len(m.buckets) {
+		s.counts[i]++
+	}
+}
+
+// ObserveSince records the duration since the start in seconds in the histogram.
+func (m *metric) ObserveSince(start time.Time, values ...string) {
+	m.Observe(time.Since(start).Seconds(), values...)
+}
+
+// write writes the metric in the Prometheus text format, the time series are sorted by their label values.
+func (m *metric) write(w *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		s := m.series[key]
+		if m.kind != "histogram" {
+			_, _ = fmt.Fprintf(w, "%s%s %s\n", m.name, formatLabels(m.labels, s.labels), formatFloat(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, bound := range append(m.buckets, math.Inf(1)) {
+			if i < len(s.counts) {
+				cumulative += s.counts[i]
+			} else {
+				cumulative = s.count
+			}
+
+			labels := formatLabels(append(slices.Clone(m.labels), "le"), append(slices.Clone(s.labels), formatFloat(bound)))
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, labels, cumulative)
+		}
+
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", m.name, formatLabels(m.labels, s.labels), formatFloat(s.value))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", m.name, formatLabels(m.labels, s.labels), s.count)
+	}
+}
+
+// WriteMetrics writes the metrics in the Prometheus text format (version 0.0.4).
+func WriteMetrics(w io.Writer) error {
+	buffered := bufio.NewWriter(w)
+	for _, m := range metricsRegistry {
+		m.write(buffered)
+	}
+
+	return buffered.Flush()
+}
+
+// formatLabels formats the label pairs, e.g. {host="www.bing.com",status="200"}.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(values[i]) + `"`
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat formats the sample value, infinities as +Inf and -Inf.
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+
+	case math.IsInf(value, -1):
+		return "-Inf"
+
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+
+	}
+}
+
+// RoundTrip sends the request and records its status code and duration.
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	httpRequestDuration.ObserveSince(start, req.URL.Host)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+
+	httpRequests.Add(1, req.URL.Host, status)
+	return resp, err
+}
+
+// callProvider calls the provider of the service (e.g. translate by google) and records the call and its failure.
+func callProvider(service, provider string, call func() error) error {
+	providerCalls.Add(1, service, provider)
+	err := call()
+	if err != nil {
+		providerFailures.Add(1, service, provider)
+	}
+
+	return err
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricWrite(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		metric *metric
+		update func(*metric)
+		want   string
+	}{
+		{"test#1", &metric{kind: "counter", name: "requests_total", help: "The requests.", labels: []string{"host", "status"}, series: map[string]*series{}},
+			func(m *metric) {
+				m.Add(1, "www.bing.com", "200")
+				m.Add(2, "www.bing.com", "200")
+				m.Add(1, `"quoted"`, "error")
+			},
+			"# HELP requests_total The requests.\n# TYPE requests_total counter\n" +
+				"requests_total{host=\"\\\"quoted\\\"\",status=\"error\"} 1\n" +
+				"requests_total{host=\"www.bing.com\",status=\"200\"} 3\n"},
+		{"test#2", &metric{kind: "gauge", name: "timestamp_seconds", help: "The time.", series: map[string]*series{}},
+			func(m *metric) { m.Set(1.5) },
+			"# HELP timestamp_seconds The time.\n# TYPE timestamp_seconds gauge\ntimestamp_seconds 1.5\n"},
+		{"test#3", &metric{kind: "histogram", name: "duration_seconds", help: "The duration.", labels: []string{"layer"}, buckets: []float64{0.1, 1}, series: map[string]*series{}},
+			func(m *metric) {
+				m.Observe(0.05, "dim")
+				m.Observe(0.1, "dim")
+				m.Observe(0.5, "dim")
+				m.Observe(2, "dim")
+			},
+			"# HELP duration_seconds The duration.\n# TYPE duration_seconds histogram\n" +
+				"duration_seconds_bucket{layer=\"dim\",le=\"0.1\"} 2\n" +
+				"duration_seconds_bucket{layer=\"dim\",le=\"1\"} 3\n" +
+				"duration_seconds_bucket{layer=\"dim\",le=\"+Inf\"} 4\n" +
+				"duration_seconds_sum{layer=\"dim\"} 2.65\n" +
+				"duration_seconds_count{layer=\"dim\"} 4\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.update(tt.metric)
+
+			var buffer strings.Builder
+			w := bufio.NewWriter(&buffer)
+			tt.metric.write(w)
+			_ = w.Flush()
+
+			if got := buffer.String(); got != tt.want {
+				t.Errorf("write() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	resp, err := (&http.Client{Transport: metricsTransport{next: http.DefaultTransport}}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	_ = resp.Body.Close()
+
+	httpRequests.mu.Lock()
+	defer httpRequests.mu.Unlock()
+	if s, ok := httpRequests.series[host+"\xff418"]; !ok || s.value != 1 {
+		t.Errorf("Expected request to %s with status 418 to be recorded, got %v", host, httpRequests.series)
+	}
+}
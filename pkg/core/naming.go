@@ -0,0 +1,189 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultFileNameTemplate reproduces the file names used by Bing (e.g. OHR.Something_DE-DE123_1920x1080).
+const DefaultFileNameTemplate = "{id}_{resolution}"
+
+// maxFileNameLength limits the length of a path segment in bytes leaving room for suffixes and extensions.
+const maxFileNameLength = 200
+
+// maxFileNameCollisions limits the number of numbered file names tried on collision.
+const maxFileNameCollisions = 1000
+
+// fileNamePlaceholder matches the placeholders of the file name template.
+var fileNamePlaceholder = regexp.MustCompile(`\{([a-z-]+)\}`)
+
+// reservedFileName matches the device names reserved on Windows.
+var reservedFileName = regexp.MustCompile(`(?i)^(con|prn|aux|nul|com[0-9]|lpt[0-9])(\..*)?$`)
+
+// FileName expands the file name template with the metadata of the image and returns the relative path without extension.
+// The placeholders {id}, {date}, {year}, {month}, {day}, {region}, {title-slug} and {resolution} are supported,
+// slashes in the template create subdirectories (e.g. {year}/{month}/{date}_{title-slug}).
+// The path segments are sanitized to be safe on every OS.
+// If the date of the image is unknown, the current date is used.
+func (img *Image) FileName(template string) (string, error) {
+	if template == "" {
+		template = DefaultFileNameTemplate
+	}
+
+	id := img.ID
+	if id == "" {
+		parsed, err := url.Parse(img.DownloadURL)
+		if err != nil {
+			return "", err
+		}
+
+		id = strings.TrimSuffix(parsed.Query().Get("id"), filepath.Ext(parsed.Query().Get("id")))
+	}
+
+	if id == "" {
+		return "", fmt.Errorf("missing file name in URL: %s", img.DownloadURL)
+	}
+
+	date := img.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var resolution string
+	if img.Image != nil {
+		resolution = fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+
+	values := map[string]string{
+		"id":         id,
+		"date":       date.Format(time.DateOnly),
+		"year":       date.Format("2006"),
+		"month":      date.Format("01"),
+		"day":        date.Format("02"),
+		"region":     img.Metadata().Region,
+		"title-slug": slugify(img.Title),
+		"resolution": resolution,
+	}
+
+	var err error
+	expanded := fileNamePlaceholder.ReplaceAllStringFunc(template, func(match string) string {
+		value, ok := values[strings.Trim(match, "{}")]
+		if !ok && err == nil {
+			err = fmt.Errorf("unknown placeholder in file name template: %s", match)
+		}
+
+		// the values must not create subdirectories
+		return strings.NewReplacer("/", "-", "\\", "-").Replace(value)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var segments []string
+	for _, segment := range strings.FieldsFunc(expanded, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment = sanitizeFileName(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	if len(segments) == 0 {
+		return "", fmt.Errorf("file name template %q expands to an empty file name", template)
+	}
+
+	return filepath.Join(segments...), nil
+}
+
+// availablePath returns the path of the file with the given base path and extension.
+// If a file of another wallpaper exists at the path, the suffixes -2, -3, etc. are tried in order.
+// A file of the same wallpaper is overwritten. It is identified by its embedded metadata or, if the metadata cannot be read
+// (e.g. of files saved by older versions), by its sidecar or the Bing id in its name.
+func (img *Image) availablePath(base, ext string) (string, error) {
+	own := img.Metadata()
+	for i := 1; i <= maxFileNameCollisions; i++ {
+		candidate := base + ext
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		}
+
+		existing, err := ReadMetadata(candidate)
+		if err != nil {
+			if sidecar, sidecarErr := ReadSidecar(candidate); sidecarErr == nil {
+				existing, err = &sidecar.Metadata, nil
+			}
+		}
+
+		switch {
+		case err != nil && own.ID != "" && strings.Contains(filepath.Base(candidate), sanitizeFileName(own.ID)):
+			return candidate, nil
+
+		case err != nil:
+			continue
+
+		case (own.ID != "" && existing.ID == own.ID) || (own.ID == "" && existing.DownloadURL == own.DownloadURL):
+			return candidate, nil
+
+		}
+	}
+
+	return "", fmt.Errorf("too many files named %s", base+ext)
+}
+
+// sanitizeFileName replaces the characters, which are not allowed in file names on any OS,
+// trims trailing dots and spaces and escapes the device names reserved on Windows.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	name = strings.TrimLeft(strings.TrimRight(name, ". "), " ")
+	if reservedFileName.MatchString(name) {
+		name = "_" + name
+	}
+
+	for len(name) > maxFileNameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+
+	return strings.TrimRight(name, ". ")
+}
+
+// slugify returns the lower case text with diacritics removed and any other character than letters and digits replaced by hyphens.
+func slugify(text string) string {
+	text, _, _ = transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), text)
+
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+
+		hyphen = true
+	}
+
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileName(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		args    string
+		want    string
+		wantErr bool
+	}{
+		{"test#1", "", "OHR.FolegandrosGreece_DE-DE3993128464_1920x1080", false},
+		{"test#2", "{year}/{month}/{date}_{title-slug}", filepath.Join("2024", "02", "2024-02-10_paradies-auf-griechisch"), false},
+		{"test#3", "{region}\\{day}-{resolution}", filepath.Join("de-DE", "10-1920x1080"), false},
+		{"test#4", "../../{id}", "OHR.FolegandrosGreece_DE-DE3993128464", false},
+		{"test#5", "con", "_con", false},
+		{"test#6", "a:b*c?/ trailing. ", filepath.Join("a_b_c_", "trailing"), false},
+		{"test#7", "{unknown}", "", true},
+		{"test#8", "//", "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SetupTestImage(t).FileName(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FileName(%q) error = %v, wantErr %t", tt.args, err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("FileName(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+
+	img := SetupTestImage(t)
+	img.ID, img.Title = "", "a/b"
+	if got, err := img.FileName("{title-slug}/{id}"); err != nil || got != filepath.Join("a-b", "OHR.FolegandrosGreece_DE-DE3993128464_1920x1080") {
+		t.Errorf("FileName() = %q, %v, want id of the download URL", got, err)
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args string
+		want string
+	}{
+		{"test#1", "Paradies auf Griechisch", "Paradies auf Griechisch"},
+		{"test#2", `a<b>c:d"e|f?g*h`, "a_b_c_d_e_f_g_h"},
+		{"test#3", "tab\there\x00", "tab_here_"},
+		{"test#4", "  dots... ", "dots"},
+		{"test#5", "LPT1.txt", "_LPT1.txt"},
+		{"test#6", "..", ""},
+		{"test#7", strings.Repeat("ä", maxFileNameLength), strings.Repeat("ä", maxFileNameLength/2)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFileName(tt.args); got != tt.want {
+				t.Errorf("sanitizeFileName(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args string
+		want string
+	}{
+		{"test#1", "Paradies auf Griechisch", "paradies-auf-griechisch"},
+		{"test#2", "Crème brûlée, s'il vous plaît!", "creme-brulee-s-il-vous-plait"},
+		{"test#3", "富士山の日の出", "富士山の日の出"},
+		{"test#4", " -- ", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slugify(tt.args); got != tt.want {
+				t.Errorf("slugify(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeAndDumpCollision(t *testing.T) {
+	dir := t.TempDir()
+	opts := EncodeOptions{FileNameTemplate: "{date}"}
+
+	first, err := SetupTestImage(t).EncodeAndDump(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again, err := SetupTestImage(t).EncodeAndDump(dir, opts); err != nil || again != first {
+		t.Errorf("EncodeAndDump() = %q, %v, want %q overwritten for the same wallpaper", again, err, first)
+	}
+
+	other := SetupTestImage(t)
+	other.ID = "OHR.Other_DE-DE1"
+	second, err := other.EncodeAndDump(dir, opts)
+	if err != nil || second != filepath.Join(dir, "2024-02-10-2.png") {
+		t.Errorf("EncodeAndDump() = %q, %v, want numbered file for another wallpaper", second, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "2024-02-10-3.png"), []byte("foreign"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	third := SetupTestImage(t)
+	third.ID = "OHR.Third_DE-DE1"
+	if got, err := third.EncodeAndDump(dir, opts); err != nil || got != filepath.Join(dir, "2024-02-10-4.png") {
+		t.Errorf("EncodeAndDump() = %q, %v, want foreign file skipped", got, err)
+	}
+
+	if got, err := other.EncodeAndDump(dir, opts); err != nil || got != second {
+		t.Errorf("EncodeAndDump() = %q, %v, want %q again", got, err, second)
+	}
+
+	// files without metadata are identified by their sidecar or the Bing id in their name
+	if err := os.WriteFile(first, []byte("legacy"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(sidecarPath(first), []byte(`{"id": "`+SetupTestImage(t).ID+`"}`), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := SetupTestImage(t).EncodeAndDump(dir, opts); err != nil || got != first {
+		t.Errorf("EncodeAndDump() = %q, %v, want %q identified by its sidecar", got, err, first)
+	}
+
+	legacy := filepath.Join(dir, other.ID+".png")
+	if err := os.WriteFile(legacy, []byte("legacy"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := other.EncodeAndDump(dir, EncodeOptions{FileNameTemplate: "{id}"}); err != nil || got != legacy {
+		t.Errorf("EncodeAndDump() = %q, %v, want %q identified by its name", got, err, legacy)
+	}
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+)
+
+// openAPISpec is the OpenAPI specification of the API without the config schema, which is generated from the config.
+//
+//go:embed openapi/openapi.json
+var openAPISpec []byte
+
+// handleOpenAPI handles the OpenAPI endpoint.
+// It returns the OpenAPI specification of the API when GET request is made.
+// The config schema lists the allowed values of the enums as configured, the server is the public URL of the API server (see publicURL).
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		logger.Logger.Printf("Method not allowed: %s", r.Method)
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed: "+r.Method)
+		return
+	}
+
+	cfg := s.controller.config()
+	document, err := openAPIDocument(cfg, publicURL(cfg, ""))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(document)
+}
+
+// openAPIDocument returns the OpenAPI specification of the API served at the server URL.
+// If the API requires a bearer token, the security requirement and the unauthorized responses are added to all operations.
+func openAPIDocument(cfg *Config, serverURL string) (map[string]any, error) {
+	var document map[string]any
+	if err := json.Unmarshal(openAPISpec, &document); err != nil {
+		return nil, err
+	}
+
+	document["servers"] = []any{map[string]any{"url": serverURL}}
+	document["components"].(map[string]any)["schemas"].(map[string]any)["Config"] = configSchema(cfg)
+
+	if cfg.ApiToken == "" {
+		return document, nil
+	}
+
+	document["security"] = []any{map[string]any{"bearer": []any{}}}
+	for _, item := range document["paths"].(map[string]any) {
+		for _, operation := range item.(map[string]any) {
+			// operations with own security requirements are served without token (e.g. the dashboard)
+			if _, ok := operation.(map[string]any)["security"]; ok {
+				continue
+			}
+
+			responses := operation.(map[string]any)["responses"].(map[string]any)
+			responses["401"] = map[string]any{"$ref": "#/components/responses/Unauthorized"}
+		}
+	}
+
+	return document, nil
+}
+
+// configSchema returns the JSON schema of the config document.
+// The names of the enum values are taken from the values allowed by the config.
+func configSchema(cfg *Config) map[string]any {
+	properties := make(map[string]any)
+	value := reflect.ValueOf(cfg).Elem()
+	for i := range value.NumField() {
+		name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("json"), ",")
+		if name == "-" || name == "" {
+			continue
+		}
+
+		schema := configFieldSchema(value.Field(i).Interface())
+		if slices.Contains(readOnlyConfigFields, name) {
+			schema["readOnly"] = true
+		}
+
+		properties[name] = schema
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// configFieldSchema returns the JSON schema of the value of a config field.
+func configFieldSchema(value any) map[string]any {
+	switch value := value.(type) {
+	case types.Color:
+		return map[string]any{"$ref": "#/components/schemas/Color"}
+
+	case types.Percent:
+		return map[string]any{"type": "number", "minimum": 0, "maximum": 100}
+
+	case time.Duration:
+		return map[string]any{"type": "integer", "minimum": 0, "description": "The duration in nanoseconds"}
+
+	case Messages:
+		return map[string]any{"type": "array", "items": map[string]any{"$ref": "#/components/schemas/Message"}}
+
+	case interface{ Names() []string }:
+		// the enums accept the name of the value or the document they are encoded as
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string", "enum": value.Names()},
+			map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"value":  map[string]any{},
+					"name":   map[string]any{"type": "string"},
+					"values": map[string]any{"type": "array"},
+					"alias":  map[string]any{"type": "string"},
+				},
+				"required": []any{"value"},
+			},
+		}}
+
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	default:
+		return map[string]any{}
+
+	}
+}
@@ -4,12 +4,12 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"fmt"
 	"image/png"
 	"math"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -30,17 +30,23 @@ var clipboardErr = clipboard.Init()
 
 // Controller is the controller of the application.
 type Controller struct {
-	img         *Image
-	cfg         *Config
-	execute     func(*Config) *Image
-	refreshLock sync.Mutex
+	img     *Image
+	cfg     *Config
+	cfgLock sync.RWMutex // guards the config, see config and configure
+	execute func(*Config, *Job) *Image
+	jobs    *JobRunner
+	events  *EventBus
+
+	// menu items reflecting the state of the refresh jobs and the audio playback
+	menuLock                                                       sync.Mutex
+	mRefresh, mSpeak, mQuit, mPropertiesAudio, mPropertiesImagePin *systray.MenuItem
+	// configSyncs check the menu items of the config options after the config has changed
+	configSyncs []func()
+	unsubscribe func()
 }
 
 // OnReady initializes the application.
 func (c *Controller) OnReady() {
-	c.refreshLock.Lock()
-	defer c.refreshLock.Unlock()
-
 	// reset the menu
 	systray.ResetMenu()
 
@@ -51,45 +57,43 @@ func (c *Controller) OnReady() {
 	// set the icon
 	systray.SetIcon(readIcon("wallpaper"))
 
-	var mRefresh, mSpeak, mQuit, mPropertiesAudio *systray.MenuItem
+	var mRefresh, mSpeak, mQuit, mPropertiesAudio, mPropertiesImagePin *systray.MenuItem
+	c.configSyncs = nil
+
+	// the menu shows the config as of now, the checkboxes follow its changes (see configSyncs)
+	cfg := c.config()
 
 	// Main section
 	mRefresh = systray.AddMenuItem("Refresh", "Refresh the wallpaper")
 	mRefresh.SetIcon(readIcon("refresh"))
 	mRefresh.Click(func() {
-		modify(func(mi *systray.MenuItem) { mi.Disable() }, mRefresh, mSpeak, mQuit)
-		mPropertiesAudio.Hide()
-		autoPlayAudio := c.cfg.AutoPlayAudio
-		c.cfg.AutoPlayAudio = false
-		c.img.Update(c.execute(c.cfg))
-		c.cfg.AutoPlayAudio = autoPlayAudio
-		modify(func(mi *systray.MenuItem) { mi.Enable() }, mRefresh, mQuit)
-		if c.img != nil && c.img.Audio != nil {
-			mSpeak.Enable()
-			mPropertiesAudio.Show()
-		}
+		job, _ := c.jobs.Start(JobTriggerTray)
+		job.Wait()
 	})
 
 	mSpeak = systray.AddMenuItem("Speak", "Speak the wallpaper description")
 	mSpeak.SetIcon(readIcon("play"))
-	mSpeak.Click(func() {
-		modify(func(mi *systray.MenuItem) { mi.Disable() }, mRefresh, mSpeak, mQuit)
-		if c.img != nil && c.img.Audio != nil {
-			if err := c.img.Audio.Play(); err != nil {
-				logger.Logger.Printf("Failed to play audio: %v", err)
-			}
-		}
-		modify(func(mi *systray.MenuItem) { mi.Enable() }, mRefresh, mSpeak, mQuit)
-	})
+	mSpeak.Click(c.playAudio)
 
 	systray.AddSeparator()
 
 	apiMenu := systray.AddMenuItem("API", "API of the wallpaper")
-	serverAddr := fmt.Sprintf("localhost:%d", c.cfg.ApiPort)
 	apiMenuRetrieveConfig := apiMenu.AddSubMenuItem("Retrieve Config", "Retrieve the config")
-	makeApiCommand(apiMenuRetrieveConfig, serverAddr, http.MethodGet, "/config", "", "")
+	makeApiCommand(apiMenuRetrieveConfig, c.config, http.MethodGet, "/config", "", "")
 	apiMenuUpdateConfig := apiMenu.AddSubMenuItem("Update Config", "Update the config")
-	makeApiCommand(apiMenuUpdateConfig, serverAddr, http.MethodPatch, "/config", "refresh=true", "{...}")
+	makeApiCommand(apiMenuUpdateConfig, c.config, http.MethodPatch, "/config", "refresh=true", "{...}")
+	apiMenuRefresh := apiMenu.AddSubMenuItem("Refresh", "Refresh the wallpaper in a job")
+	makeApiCommand(apiMenuRefresh, c.config, http.MethodPost, "/refresh", "", "")
+	apiMenuDashboard := apiMenu.AddSubMenuItem("Dashboard", "Open the web dashboard in the browser")
+	apiMenuDashboard.SetIcon(readIcon("open"))
+	if cfg.ApiSocket != "" {
+		apiMenuDashboard.Disable()
+	}
+	apiMenuDashboard.Click(func() {
+		if err := browser.OpenURL(dashboardURL(c.config())); err != nil {
+			logger.Logger.Printf("Failed to open dashboard: %v", err)
+		}
+	})
 
 	systray.AddSeparator()
 
@@ -105,7 +109,7 @@ func (c *Controller) OnReady() {
 		types.Day5Ago:  mConfigDay.AddSubMenuItemCheckbox("Five days ago", "Five days ago's wallpaper", false),
 		types.Day6Ago:  mConfigDay.AddSubMenuItemCheckbox("Six days ago", "Six days ago's wallpaper", false),
 		types.Day7Ago:  mConfigDay.AddSubMenuItemCheckbox("Seven days ago", "Seven days ago's wallpaper", false),
-	}, c.cfg, func(c *Config) types.Day { return c.Day.Value() }, func(c *Config, d types.Day) {
+	}, c, func(c *Config) types.Day { return c.Day.Value() }, func(c *Config, d types.Day) {
 		logger.Logger.Printf("Setting Day: %v", d)
 		c.Day.SetDefault(d)
 	})
@@ -118,7 +122,7 @@ func (c *Controller) OnReady() {
 		ModeSpan:    mConfigMode.AddSubMenuItemCheckbox("Span", "Span mode", false),
 		ModeStretch: mConfigMode.AddSubMenuItemCheckbox("Stretch", "Stretch mode", false),
 		ModeTile:    mConfigMode.AddSubMenuItemCheckbox("Tile", "Tile mode", false),
-	}, c.cfg, func(c *Config) Mode { return c.Mode.Value() }, func(c *Config, m Mode) {
+	}, c, func(c *Config) Mode { return c.Mode.Value() }, func(c *Config, m Mode) {
 		logger.Logger.Printf("Setting Mode: %v", m)
 		c.Mode.SetDefault(m)
 	})
@@ -139,7 +143,7 @@ func (c *Controller) OnReady() {
 		types.RegionSpain:         mConfigRegion.AddSubMenuItemCheckbox("Spain", "Spanish region", false),
 		types.RegionUnitedKingdom: mConfigRegion.AddSubMenuItemCheckbox("United Kingdom", "British region", false),
 		types.RegionUnitedStates:  mConfigRegion.AddSubMenuItemCheckbox("United States", "US region", false),
-	}, c.cfg, func(c *Config) types.Region { return c.Region.Value() }, func(c *Config, r types.Region) {
+	}, c, func(c *Config) types.Region { return c.Region.Value() }, func(c *Config, r types.Region) {
 		logger.Logger.Printf("Setting Region: %v", r)
 		c.Region.SetDefault(r)
 	})
@@ -149,17 +153,58 @@ func (c *Controller) OnReady() {
 		types.LowDefinition:       mConfigResolution.AddSubMenuItemCheckbox("Low Definition", "Low Definition resolution", false),
 		types.HighDefinition:      mConfigResolution.AddSubMenuItemCheckbox("High Definition", "High Definition resolution", false),
 		types.UltraHighDefinition: mConfigResolution.AddSubMenuItemCheckbox("Ultra High Definition", "Ultra High Definition resolution", false),
-	}, c.cfg, func(c *Config) types.Resolution { return c.Resolution.Value() }, func(c *Config, r types.Resolution) {
+	}, c, func(c *Config) types.Resolution { return c.Resolution.Value() }, func(c *Config, r types.Resolution) {
 		logger.Logger.Printf("Setting Resolution: %v", r)
 		c.Resolution.SetDefault(r)
 	})
 
+	mConfigWatermarkMode := mConfig.AddSubMenuItem("Watermark Mode", "Placement mode of the watermark")
+	makeConfigSection(map[WatermarkMode]*systray.MenuItem{
+		WatermarkModeStretch: mConfigWatermarkMode.AddSubMenuItemCheckbox("Stretch", "Stretch the watermark to the wallpaper", false),
+		WatermarkModeFit:     mConfigWatermarkMode.AddSubMenuItemCheckbox("Fit", "Fit the watermark into the wallpaper", false),
+		WatermarkModeFill:    mConfigWatermarkMode.AddSubMenuItemCheckbox("Fill", "Fill the wallpaper with the watermark", false),
+		WatermarkModeCenter:  mConfigWatermarkMode.AddSubMenuItemCheckbox("Center", "Center the watermark", false),
+		WatermarkModeCorner:  mConfigWatermarkMode.AddSubMenuItemCheckbox("Corner", "Place the watermark at the watermark position", false),
+		WatermarkModeTile:    mConfigWatermarkMode.AddSubMenuItemCheckbox("Tile", "Tile the watermark", false),
+	}, c, func(c *Config) WatermarkMode { return c.WatermarkMode.Value() }, func(c *Config, m WatermarkMode) {
+		logger.Logger.Printf("Setting WatermarkMode: %v", m)
+		c.WatermarkMode.SetDefault(m)
+	})
+
+	mConfigWatermarkPosition := mConfig.AddSubMenuItem("Watermark Position", "Position of the watermark in corner mode")
+	mConfigWatermarkPositionMap := make(map[types.Position]*systray.MenuItem)
+	for _, p := range types.AllowedPositions {
+		mConfigWatermarkPositionMap[p] = mConfigWatermarkPosition.AddSubMenuItemCheckbox(p.String(), p.String()+" position", false)
+	}
+	makeConfigSection(mConfigWatermarkPositionMap, c, func(c *Config) types.Position { return c.WatermarkPosition.Value() }, func(c *Config, p types.Position) {
+		logger.Logger.Printf("Setting WatermarkPosition: %v", p)
+		c.WatermarkPosition.SetDefault(p)
+	})
+
+	mConfigCalendarView := mConfig.AddSubMenuItem("Calendar View", "Layout of the calendar")
+	makeConfigSection(map[CalendarView]*systray.MenuItem{
+		CalendarViewAgenda: mConfigCalendarView.AddSubMenuItemCheckbox("Agenda", "Show today's agenda", false),
+		CalendarViewMonth:  mConfigCalendarView.AddSubMenuItemCheckbox("Month", "Show the month grid", false),
+	}, c, func(c *Config) CalendarView { return c.CalendarView.Value() }, func(c *Config, v CalendarView) {
+		logger.Logger.Printf("Setting CalendarView: %v", v)
+		c.CalendarView.SetDefault(v)
+	})
+
+	mConfigOutputFormat := mConfig.AddSubMenuItem("Output Format", "Format of the saved wallpaper")
+	makeConfigSection(map[OutputFormat]*systray.MenuItem{
+		OutputFormatPNG:  mConfigOutputFormat.AddSubMenuItemCheckbox("PNG", "Save the wallpaper as PNG", false),
+		OutputFormatJPEG: mConfigOutputFormat.AddSubMenuItemCheckbox("JPEG", "Save the wallpaper as JPEG", false),
+	}, c, func(c *Config) OutputFormat { return c.OutputFormat.Value() }, func(c *Config, f OutputFormat) {
+		logger.Logger.Printf("Setting OutputFormat: %v", f)
+		c.OutputFormat.SetDefault(f)
+	})
+
 	mConfigDimImage := mConfig.AddSubMenuItem("Dim Image", "Dim the image")
 	mConfigDimImageMap := make(map[types.Percent]*systray.MenuItem)
 	for i := 0; i <= 100; i += 10 {
 		mConfigDimImageMap[types.Percent(i)] = mConfigDimImage.AddSubMenuItemCheckbox(fmt.Sprintf("%d%%", i), fmt.Sprintf("%d%% dim", i), false)
 	}
-	makeConfigSection(mConfigDimImageMap, c.cfg, func(c *Config) types.Percent {
+	makeConfigSection(mConfigDimImageMap, c, func(c *Config) types.Percent {
 		// round to the nearest 10% to find the closest matching value
 		return types.Percent(math.Round(float64(c.DimImage)/10) * 10)
 	}, func(c *Config, p types.Percent) {
@@ -167,58 +212,64 @@ func (c *Controller) OnReady() {
 		c.DimImage = p
 	})
 
-	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Draw Description", "Draw the wallpaper description", false), c.cfg,
+	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Draw Description", "Draw the wallpaper description", false), c,
 		func(c *Config) bool { return c.DrawDescription },
 		func(c *Config, b bool) {
 			logger.Logger.Printf("Setting DrawDescription: %v", b)
 			c.DrawDescription = b
 		})
 
-	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Draw QR Code", "Draw the QR code", false), c.cfg,
+	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Draw QR Code", "Draw the QR code", false), c,
 		func(c *Config) bool { return c.DrawQRCode },
 		func(c *Config, b bool) {
 			logger.Logger.Printf("Setting DrawQRCode: %v", b)
 			c.DrawQRCode = b
 		})
 
-	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Download Only", "Download the wallpaper only", false), c.cfg,
+	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Download Only", "Download the wallpaper only", false), c,
 		func(c *Config) bool { return c.DownloadOnly },
 		func(c *Config, b bool) {
 			logger.Logger.Printf("Setting DownloadOnly: %v", b)
 			c.DownloadOnly = b
 		})
 
-	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Rotate Wallpaper counter clockwise", "Rotate the wallpaper counter clockwise", false), c.cfg,
+	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Rotate Wallpaper counter clockwise", "Rotate the wallpaper counter clockwise", false), c,
 		func(c *Config) bool { return c.RotateCounterClockwise },
 		func(c *Config, b bool) {
 			logger.Logger.Printf("Setting RotateWallpaper: %v", b)
 			c.RotateCounterClockwise = b
 		})
 
-	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Use Google Text2Speech Service", "Use Google Text2Speech Service", false), c.cfg,
+	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Use Google Text2Speech Service", "Use Google Text2Speech Service", false), c,
 		func(c *Config) bool { return c.UseGoogleText2SpeechService },
 		func(c *Config, b bool) {
 			logger.Logger.Printf("Setting UseGoogleText2SpeechService: %v", b)
 			c.UseGoogleText2SpeechService = b
 		})
 
-	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Use Google Translate Service", "Use Google Translate Service", false), c.cfg,
+	makeConfigOption(mConfig.AddSubMenuItemCheckbox("Use Google Translate Service", "Use Google Translate Service", false), c,
 		func(c *Config) bool { return c.UseGoogleTranslateService },
 		func(c *Config, b bool) {
 			logger.Logger.Printf("Setting UseGoogleTranslateService: %v", b)
 			c.UseGoogleTranslateService = b
 		})
 
-	makeConfigInfo(mConfig.AddSubMenuItem("Watermark", "Watermark to be drawn on the wallpaper"), false, c.cfg,
+	makeConfigInfo(mConfig.AddSubMenuItem("Watermark", "Watermark to be drawn on the wallpaper"), false, cfg,
 		func(c *Config) string { return c.Watermark }, func(_ *Config, s string) { openDirectory(s) })
 
-	makeConfigInfo(mConfig.AddSubMenuItem("Google App Credentials", "Google App Credentials"), false, c.cfg,
+	makeConfigInfo(mConfig.AddSubMenuItem("Text Watermark", "Text watermark to be drawn on the wallpaper"), false, cfg,
+		func(c *Config) string { return c.TextWatermark }, nil)
+
+	makeConfigInfo(mConfig.AddSubMenuItem("Calendar File", "iCalendar file drawn on the wallpaper"), false, cfg,
+		func(c *Config) string { return c.CalendarFile }, func(_ *Config, s string) { openDirectory(s) })
+
+	makeConfigInfo(mConfig.AddSubMenuItem("Google App Credentials", "Google App Credentials"), false, cfg,
 		func(c *Config) string { return c.GoogleAppCredentials }, func(_ *Config, s string) { openDirectory(s) })
 
-	makeConfigInfo(mConfig.AddSubMenuItem("Furigana API AppId", "Furigana API AppId"), true, c.cfg,
+	makeConfigInfo(mConfig.AddSubMenuItem("Furigana API AppId", "Furigana API AppId"), true, cfg,
 		func(c *Config) string { return c.FuriganaApiAppId }, nil)
 
-	makeConfigInfo(mConfig.AddSubMenuItem("Download Directory", "Download Directory"), false, c.cfg,
+	makeConfigInfo(mConfig.AddSubMenuItem("Download Directory", "Download Directory"), false, cfg,
 		func(c *Config) string { return c.DownloadDirectory }, func(_ *Config, s string) { openDirectory(s) })
 
 	systray.AddSeparator()
@@ -237,6 +288,19 @@ func (c *Controller) OnReady() {
 		})
 	makePropertyOpenAction(mPropertiesImage.AddSubMenuItem("Open", "Open the image in the browser"), c.img,
 		func(i *Image) string { return "file://" + i.Location })
+	mPropertiesImagePin = mPropertiesImage.AddSubMenuItemCheckbox("Pin", "Never delete the wallpaper by the retention policy", false)
+	mPropertiesImagePin.Click(func() {
+		if c.img == nil || c.img.Location == "" {
+			return
+		}
+
+		pinned := !mPropertiesImagePin.Checked()
+		logger.Logger.Printf("Setting Pinned: %v", pinned)
+		if err := PinWallpaper(c.img.Location, pinned); err != nil {
+			logger.Logger.Printf("Failed to pin the wallpaper: %v", err)
+		}
+		syncPinned(mPropertiesImagePin, c.img)
+	})
 
 	mPropertiesAudio = mProperties.
 		AddSubMenuItem("Audio", "Audio data of the wallpaper")
@@ -274,25 +338,92 @@ func (c *Controller) OnReady() {
 	mQuit.SetIcon(readIcon("quit"))
 	mQuit.Click(systray.Quit)
 
+	c.menuLock.Lock()
+	c.mRefresh, c.mSpeak, c.mQuit, c.mPropertiesAudio, c.mPropertiesImagePin = mRefresh, mSpeak, mQuit, mPropertiesAudio, mPropertiesImagePin
+	c.menuLock.Unlock()
+
+	// the tray follows the changes made by the API (e.g. config, audio playback)
+	if c.unsubscribe != nil {
+		c.unsubscribe()
+	}
+	var events <-chan Event
+	events, c.unsubscribe = c.events.Subscribe()
+	go c.watchEvents(events)
+
 	// initial execution
-	modify(func(mi *systray.MenuItem) { mi.Disable() }, mRefresh, mSpeak, mQuit)
-	mPropertiesAudio.Hide()
-	c.img.Update(c.execute(c.cfg))
-	modify(func(mi *systray.MenuItem) { mi.Enable() }, mRefresh, mQuit)
+	job, _ := c.jobs.Start(JobTriggerStartup)
+	job.Wait()
+}
+
+// refresh runs the refresh job disabling the menu items meanwhile.
+// Jobs started before the menu is ready run without touching the menu.
+func (c *Controller) refresh(job *Job) error {
+	c.menuLock.Lock()
+	defer c.menuLock.Unlock()
+
+	if c.mRefresh == nil {
+		return c.update(job)
+	}
+
+	modify(func(mi *systray.MenuItem) { mi.Disable() }, c.mRefresh, c.mSpeak, c.mQuit)
+	c.mPropertiesAudio.Hide()
+	err := c.update(job)
+	modify(func(mi *systray.MenuItem) { mi.Enable() }, c.mRefresh, c.mQuit)
+	syncPinned(c.mPropertiesImagePin, c.img)
 	if c.img != nil && c.img.Audio != nil {
-		mSpeak.Enable()
-		mPropertiesAudio.Show()
+		c.mSpeak.Enable()
+		c.mPropertiesAudio.Show()
+	}
+
+	return err
+}
+
+// watchEvents updates the menu items on the events of the event bus until the subscription is cancelled.
+func (c *Controller) watchEvents(events <-chan Event) {
+	for event := range events {
+		c.menuLock.Lock()
+		switch event.Type {
+		case EventConfigChanged:
+			for _, sync := range c.configSyncs {
+				sync()
+			}
+
+		case EventWallpaperChanged:
+			syncPinned(c.mPropertiesImagePin, c.img)
+
+		case EventAudioStarted:
+			modify(func(mi *systray.MenuItem) { mi.Disable() }, c.mRefresh, c.mSpeak, c.mQuit)
+
+		case EventAudioStopped:
+			modify(func(mi *systray.MenuItem) { mi.Enable() }, c.mRefresh, c.mSpeak, c.mQuit)
+
+		}
+		c.menuLock.Unlock()
 	}
 }
 
 // OnExit is called when the application is closed.
 func (c *Controller) OnExit() {
+	if c.unsubscribe != nil {
+		c.unsubscribe()
+	}
+
 	// close the audio stream
 	if c.img != nil && c.img.Audio != nil {
 		_ = c.img.Audio.Close()
 	}
 }
 
+// syncPinned checks the menu item if the wallpaper is pinned.
+func syncPinned(item *systray.MenuItem, img *Image) {
+	if img != nil && img.Location != "" && IsPinned(img.Location) {
+		item.Check()
+		return
+	}
+
+	item.Uncheck()
+}
+
 // modify modifies the given menu items with the given operation.
 func modify(op func(*systray.MenuItem), items ...*systray.MenuItem) {
 	for _, item := range items {
@@ -300,25 +431,16 @@ func modify(op func(*systray.MenuItem), items ...*systray.MenuItem) {
 	}
 }
 
-// makeApiCommand creates a menu item with a copy action
-func makeApiCommand(item *systray.MenuItem, addr, verb, path, query, payload string) {
+// makeApiCommand creates a menu item copying the curl command calling the API (see apiCommand)
+func makeApiCommand(item *systray.MenuItem, config func() *Config, verb, path, query, payload string) {
 	item.SetIcon(readIcon("copy"))
 	item.Click(func() {
 		if clipboardErr != nil {
 			logger.Logger.Printf("Failed to initialize clipboard: %v", clipboardErr)
 			return
 		}
-		uri := &url.URL{
-			Scheme:   "http",
-			Host:     addr,
-			Path:     path,
-			RawQuery: query,
-		}
-		cmd := fmt.Sprintf("curl -X %s %s", verb, uri.String())
-		if payload != "" {
-			cmd += " -d '" + payload + "'"
-		}
-		_ = clipboard.Write(clipboard.FmtText, []byte(cmd))
+
+		_ = clipboard.Write(clipboard.FmtText, []byte(apiCommand(config(), verb, path, query, payload)))
 	})
 }
 
@@ -341,45 +463,50 @@ func makeConfigInfo(option *systray.MenuItem, sensitive bool, cfg *Config, looku
 }
 
 // makeConfigOption creates a menu item with a checkbox
-func makeConfigOption(option *systray.MenuItem, cfg *Config, lookup func(*Config) bool, editor func(*Config, bool)) {
-	// initialize the menu item
-	if lookup(cfg) {
-		option.Check()
-	} else {
-		option.Uncheck()
-	}
-
-	// define the click event
-	option.Click(func() {
-		editor(cfg, !lookup(cfg))
-		if lookup(cfg) {
+// The changes are published by the controller and the checkbox follows the changes made elsewhere (e.g. by the API).
+func makeConfigOption(option *systray.MenuItem, c *Controller, lookup func(*Config) bool, editor func(*Config, bool)) {
+	sync := func() {
+		if lookup(c.config()) {
 			option.Check()
 		} else {
 			option.Uncheck()
 		}
+	}
+
+	// initialize the menu item
+	sync()
+	c.configSyncs = append(c.configSyncs, sync)
+
+	// define the click event
+	option.Click(func() {
+		_, _ = c.configure(func(cfg *Config) error { editor(cfg, !lookup(cfg)); return nil })
+		sync()
 	})
 }
 
 // makeConfigSection creates a menu item with a sub-menu of checkboxes
-func makeConfigSection[K comparable](section map[K]*systray.MenuItem, cfg *Config, lookup func(*Config) K, editor func(*Config, K)) {
-	for j, item := range section {
-		// initialize the menu items
-		if j == lookup(cfg) {
-			item.Check()
-		} else {
-			item.Uncheck()
+// The changes are published by the controller and the checkboxes follow the changes made elsewhere (e.g. by the API).
+func makeConfigSection[K comparable](section map[K]*systray.MenuItem, c *Controller, lookup func(*Config) K, editor func(*Config, K)) {
+	sync := func() {
+		current := lookup(c.config())
+		for j, item := range section {
+			if j == current {
+				item.Check()
+			} else {
+				item.Uncheck()
+			}
 		}
+	}
 
+	// initialize the menu items
+	sync()
+	c.configSyncs = append(c.configSyncs, sync)
+
+	for j, item := range section {
 		// define the click event
 		item.Click(func() {
-			for k, subItem := range section {
-				if subItem == item {
-					editor(cfg, k)
-					subItem.Check()
-				} else {
-					subItem.Uncheck()
-				}
-			}
+			_, _ = c.configure(func(cfg *Config) error { editor(cfg, j); return nil })
+			sync()
 		})
 	}
 }
@@ -469,14 +596,27 @@ func readIcon(name string) []byte {
 }
 
 // Run executes the given function with the given configuration.
-func Run(execute func(*Config) *Image, cfg *Config) {
-	img := &Image{}
+func Run(execute func(*Config, *Job) *Image, cfg *Config) {
 	if !cfg.Daemon {
-		img.Update(execute(cfg))
+		img := &Image{}
+		img.Update(execute(cfg, nil))
 		return
 	}
 
-	controller := &Controller{img: img, cfg: cfg, execute: execute}
+	if cfg.ApiTokenFile != "" {
+		token, err := LoadApiToken(cfg.ApiTokenFile)
+		if err != nil {
+			logger.Logger.Fatalf("Failed to load API token: %v", err)
+		}
+		cfg.ApiToken = token
+	}
+
+	controller := newController(cfg, execute)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go controller.watchOverlays(ctx)
+
 	server := NewServer(cfg, controller)
 	defer func() {
 		if err := server.Stop(); err != nil {
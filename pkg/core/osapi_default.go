@@ -3,7 +3,9 @@
 package core
 
 import (
+	"context"
 	"net/http"
+	"sync"
 
 	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
 )
@@ -11,27 +13,50 @@ import (
 // Controller is the controller of the application.
 type Controller struct {
 	cfg     *Config
+	cfgLock sync.RWMutex // guards the config, see config and configure
 	img     *Image
-	execute func(*Config) *Image
+	execute func(*Config, *Job) *Image
+	jobs    *JobRunner
+	events  *EventBus
 }
 
 // OnReady is called when the application is ready.
 func (c *Controller) OnReady() {
-	c.img.Update(c.execute(c.cfg))
+	job, _ := c.jobs.Start(JobTriggerStartup)
+	job.Wait()
+}
+
+// refresh runs the refresh job.
+func (c *Controller) refresh(job *Job) error {
+	return c.update(job)
 }
 
 // OnExit is called when the application is closed.
 func (c *Controller) OnExit() {}
 
 // Run executes the given function with the given configuration.
-func Run(execute func(*Config) *Image, cfg *Config) {
-	img := &Image{}
+func Run(execute func(*Config, *Job) *Image, cfg *Config) {
 	if !cfg.Daemon {
-		img.Update(execute(cfg))
+		img := &Image{}
+		img.Update(execute(cfg, nil))
 		return
 	}
 
-	server := NewServer(cfg, &Controller{img: img, cfg: cfg, execute: execute})
+	if cfg.ApiTokenFile != "" {
+		token, err := LoadApiToken(cfg.ApiTokenFile)
+		if err != nil {
+			logger.Logger.Fatalf("Failed to load API token: %v", err)
+		}
+		cfg.ApiToken = token
+	}
+
+	controller := newController(cfg, execute)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go controller.watchOverlays(ctx)
+
+	server := NewServer(cfg, controller)
 	if err := server.Start(); err != nil && err != http.ErrServerClosed {
 		logger.Logger.Fatalf("Failed to start API server: %v", err)
 	}
@@ -0,0 +1,307 @@
+package core
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+)
+
+const (
+	MinPaletteSize = 8
+	MaxPaletteSize = 16
+)
+
+// names of the ANSI colors in the order of the terminal color scheme.
+var ansiColorNames = [8]string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// paletteFormats maps the file names of the exported palette to their writers.
+var paletteFormats = map[string]func(io.Writer, *Image) error{
+	"palette.json":          writePaletteJSON,
+	"colors.json":           writePaletteWal,
+	"colors.Xresources":     writePaletteXresources,
+	"colors-kitty.conf":     writePaletteKitty,
+	"colors-alacritty.toml": writePaletteAlacritty,
+	"colors.css":            writePaletteCSS,
+}
+
+// Palette is a list of dominant colors of an image sorted by luminance (darkest first).
+type Palette []types.Color
+
+// PaletteDocument is the JSON representation of the palette.
+type PaletteDocument struct {
+	Wallpaper  string      `json:"wallpaper"`
+	Background types.Color `json:"background"`
+	Foreground types.Color `json:"foreground"`
+	Colors     Palette     `json:"colors"`
+	Terminal   Palette     `json:"terminal"`
+}
+
+// Background returns the background color of the terminal color scheme.
+func (p Palette) Background() types.Color { return p.Terminal()[0] }
+
+// Foreground returns the foreground color of the terminal color scheme.
+func (p Palette) Foreground() types.Color { return p.Terminal()[15] }
+
+// Terminal stretches the palette to the 16 colors of a terminal color scheme.
+// The first 8 colors are picked evenly from the palette, the bright variants are lightened by 25%.
+func (p Palette) Terminal() Palette {
+	colors := make(Palette, 16)
+	if len(p) == 0 {
+		return colors
+	}
+
+	white := types.Color{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	for i := range 8 {
+		colors[i] = p[i*(len(p)-1)/7]
+		colors[i+8] = colors[i].Blend(white, 0.25)
+	}
+
+	return colors
+}
+
+// Document returns the JSON representation of the palette.
+func (p Palette) Document(wallpaper string) PaletteDocument {
+	return PaletteDocument{
+		Wallpaper:  wallpaper,
+		Background: p.Background(),
+		Foreground: p.Foreground(),
+		Colors:     p,
+		Terminal:   p.Terminal(),
+	}
+}
+
+// ExtractPalette extracts the dominant colors of the image using the median cut algorithm.
+// The size of the palette must be between MinPaletteSize and MaxPaletteSize.
+func (img *Image) ExtractPalette(size int) error {
+	if size < MinPaletteSize || size > MaxPaletteSize {
+		return fmt.Errorf("palette size must be between %d and %d, got %d", MinPaletteSize, MaxPaletteSize, size)
+	}
+
+	imgBounds := img.Bounds()
+	if imgBounds.Empty() {
+		return fmt.Errorf("cannot extract palette from an empty image")
+	}
+
+	// sample at most 65536 pixels to keep the extraction fast for UHD images
+	step := max(1, int(math.Sqrt(float64(imgBounds.Dx()*imgBounds.Dy())/65536)))
+
+	var pixels []types.Color
+	for y := imgBounds.Min.Y; y < imgBounds.Max.Y; y += step {
+		for x := imgBounds.Min.X; x < imgBounds.Max.X; x += step {
+			r, g, b, _ := img.At(x, y).RGBA()
+			pixels = append(pixels, types.Color{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 0xff})
+		}
+	}
+
+	// split the box with the widest channel range at its median until the palette size is reached
+	boxes := [][]types.Color{pixels}
+	for len(boxes) < size {
+		index, channel, span := -1, 0, -1
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+
+			if c, s := widestChannel(box); s > span {
+				index, channel, span = i, c, s
+			}
+		}
+
+		if index < 0 {
+			break
+		}
+
+		box := boxes[index]
+		slices.SortFunc(box, func(a, b types.Color) int {
+			return cmp.Compare(channelOf(a, channel), channelOf(b, channel))
+		})
+
+		boxes[index] = box[:len(box)/2]
+		boxes = append(boxes, box[len(box)/2:])
+	}
+
+	palette := make(Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var r, g, b int
+		for _, c := range box {
+			r, g, b = r+int(c.R), g+int(c.G), b+int(c.B)
+		}
+
+		n := max(1, len(box))
+		palette = append(palette, types.Color{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: 0xff})
+	}
+
+	slices.SortStableFunc(palette, func(a, b types.Color) int {
+		return cmp.Compare(a.Luminance(), b.Luminance())
+	})
+
+	img.Palette = palette
+	return nil
+}
+
+// DumpPalette writes the palette of the image to the target directory.
+// It writes a JSON document, a pywal compatible colors.json, Xresources,
+// kitty and alacritty color schemes and CSS variables.
+func (img *Image) DumpPalette(targetDir string) error {
+	if len(img.Palette) == 0 {
+		return fmt.Errorf("no palette extracted")
+	}
+
+	if err := os.MkdirAll(targetDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(paletteFormats)) {
+		if err := writeFileAtomic(filepath.Join(targetDir, name), func(w io.Writer) error { return paletteFormats[name](w, img) }); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// channelOf returns the value of the given channel (0: red, 1: green, 2: blue).
+func channelOf(c types.Color, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+
+	case 1:
+		return c.G
+
+	default:
+		return c.B
+
+	}
+}
+
+// widestChannel returns the channel with the widest value range in the box and the range itself.
+func widestChannel(box []types.Color) (channel, span int) {
+	for c := range 3 {
+		lo, hi := uint8(0xff), uint8(0)
+		for _, p := range box {
+			v := channelOf(p, c)
+			lo, hi = min(lo, v), max(hi, v)
+		}
+
+		if s := int(hi) - int(lo); s > span {
+			channel, span = c, s
+		}
+	}
+
+	return channel, span
+}
+
+// writePaletteJSON writes the palette as JSON document.
+func writePaletteJSON(w io.Writer, img *Image) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(img.Palette.Document(img.Location))
+}
+
+// writePaletteWal writes the palette in the format of pywal's colors.json.
+func writePaletteWal(w io.Writer, img *Image) error {
+	colors := make(map[string]types.Color, 16)
+	for i, c := range img.Palette.Terminal() {
+		colors[fmt.Sprintf("color%d", i)] = c
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]any{
+		"wallpaper": img.Location,
+		"alpha":     "100",
+		"special": map[string]types.Color{
+			"background": img.Palette.Background(),
+			"foreground": img.Palette.Foreground(),
+			"cursor":     img.Palette.Foreground(),
+		},
+		"colors": colors,
+	})
+}
+
+// writePaletteXresources writes the palette as X resources.
+func writePaletteXresources(w io.Writer, img *Image) error {
+	if _, err := fmt.Fprintf(w, "*.background: %s\n*.foreground: %s\n*.cursorColor: %s\n",
+		img.Palette.Background(), img.Palette.Foreground(), img.Palette.Foreground()); err != nil {
+		return err
+	}
+
+	for i, c := range img.Palette.Terminal() {
+		if _, err := fmt.Fprintf(w, "*.color%d: %s\n", i, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writePaletteKitty writes the palette as kitty color scheme.
+func writePaletteKitty(w io.Writer, img *Image) error {
+	if _, err := fmt.Fprintf(w, "background %s\nforeground %s\ncursor %s\n",
+		img.Palette.Background(), img.Palette.Foreground(), img.Palette.Foreground()); err != nil {
+		return err
+	}
+
+	for i, c := range img.Palette.Terminal() {
+		if _, err := fmt.Fprintf(w, "color%d %s\n", i, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writePaletteAlacritty writes the palette as alacritty color scheme (TOML).
+func writePaletteAlacritty(w io.Writer, img *Image) error {
+	if _, err := fmt.Fprintf(w, "[colors.primary]\nbackground = %q\nforeground = %q\n",
+		img.Palette.Background(), img.Palette.Foreground()); err != nil {
+		return err
+	}
+
+	terminal := img.Palette.Terminal()
+	for i, section := range []string{"normal", "bright"} {
+		if _, err := fmt.Fprintf(w, "\n[colors.%s]\n", section); err != nil {
+			return err
+		}
+
+		for j, name := range ansiColorNames {
+			if _, err := fmt.Fprintf(w, "%s = %q\n", name, terminal[i*8+j].String()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writePaletteCSS writes the palette as CSS variables.
+func writePaletteCSS(w io.Writer, img *Image) error {
+	if _, err := fmt.Fprintf(w, ":root {\n  --wallpaper: url(%q);\n  --background: %s;\n  --foreground: %s;\n  --cursor: %s;\n",
+		filepath.ToSlash(img.Location), img.Palette.Background(), img.Palette.Foreground(), img.Palette.Foreground()); err != nil {
+		return err
+	}
+
+	for i, c := range img.Palette.Terminal() {
+		if _, err := fmt.Fprintf(w, "  --color%d: %s;\n", i, c); err != nil {
+			return err
+		}
+	}
+
+	for i, c := range img.Palette {
+		if _, err := fmt.Fprintf(w, "  --palette%d: %s;\n", i, c); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
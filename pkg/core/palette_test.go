@@ -0,0 +1,91 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestExtractPalette(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{"test#1", MinPaletteSize, false},
+		{"test#2", MaxPaletteSize, false},
+		{"test#3", MinPaletteSize - 1, true},
+		{"test#4", MaxPaletteSize + 1, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			img := SetupTestImage(t)
+
+			err := img.ExtractPalette(tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExtractPalette(%d) error = %v, wantErr %t", tt.size, err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if len(img.Palette) != tt.size {
+				t.Errorf("ExtractPalette(%d) got %d colors", tt.size, len(img.Palette))
+			}
+
+			for i := 1; i < len(img.Palette); i++ {
+				if img.Palette[i-1].Luminance() > img.Palette[i].Luminance() {
+					t.Errorf("ExtractPalette(%d) = %v, want sorted by luminance", tt.size, img.Palette)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestDumpPalette(t *testing.T) {
+	img := SetupTestImage(t)
+	img.Location = "wallpaper.png"
+	if err := img.ExtractPalette(MaxPaletteSize); err != nil {
+		t.Fatal(err)
+	}
+
+	targetDir := t.TempDir()
+	if err := img.DumpPalette(targetDir); err != nil {
+		t.Fatalf("DumpPalette() error = %v", err)
+	}
+
+	for name := range paletteFormats {
+		info, err := os.Stat(filepath.Join(targetDir, name))
+		if err != nil || info.Size() == 0 {
+			t.Errorf("DumpPalette() did not write %s: %v", name, err)
+		} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0o644 {
+			t.Errorf("DumpPalette() wrote %s with mode %s, want %s", name, info.Mode().Perm(), os.FileMode(0o644))
+		}
+	}
+
+	raw, err := os.ReadFile(filepath.Join(targetDir, "colors.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wal struct {
+		Wallpaper string            `json:"wallpaper"`
+		Special   map[string]string `json:"special"`
+		Colors    map[string]string `json:"colors"`
+	}
+	if err := json.Unmarshal(raw, &wal); err != nil {
+		t.Fatalf("colors.json is not valid JSON: %v", err)
+	}
+
+	if wal.Wallpaper != img.Location || len(wal.Colors) != 16 || wal.Special["background"] != wal.Colors["color0"] {
+		t.Errorf("colors.json = %+v, want pywal compatible document", wal)
+	}
+
+	if err := (&Image{}).DumpPalette(targetDir); err == nil {
+		t.Error("DumpPalette() without palette error = nil, want error")
+	}
+}
@@ -0,0 +1,329 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The media types of the patch formats accepted by the config endpoint.
+const (
+	MediaTypeMergePatch = "application/merge-patch+json"
+	MediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+// errInvalidPatch is returned for patches, which are malformed as opposed to patches, which cannot be applied.
+var errInvalidPatch = errors.New("invalid patch")
+
+// JSONPatchOperation is an operation of a JSON patch (RFC 6902).
+type JSONPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// decodeDocument decodes the JSON document keeping the numbers as they are.
+func decodeDocument(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// mergePatch applies the merge patch (RFC 7396) to the target document.
+// Objects are merged recursively, null removes the member and any other value replaces it.
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any)
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+
+	return targetObject
+}
+
+// applyJSONPatch applies the operations of the JSON patch (RFC 6902) to the document in order.
+// Malformed operations are reported as errInvalidPatch, operations, which cannot be applied (e.g. a failed test), as other errors.
+func applyJSONPatch(document any, operations []JSONPatchOperation) (any, error) {
+	for i, operation := range operations {
+		var err error
+		if document, err = applyJSONPatchOperation(document, operation); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+	}
+
+	return document, nil
+}
+
+// applyJSONPatchOperation applies the operation to the document.
+func applyJSONPatchOperation(document any, operation JSONPatchOperation) (any, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if slices.Contains([]string{"add", "replace", "test"}, operation.Op) {
+		if len(operation.Value) == 0 {
+			return nil, fmt.Errorf("%w: missing value", errInvalidPatch)
+		}
+
+		if value, err = decodeDocument(operation.Value); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidPatch, err)
+		}
+	}
+
+	switch operation.Op {
+	case "add":
+		return updatePointer(document, path, func(container any, token string) (any, error) {
+			return addMember(container, token, value)
+		})
+
+	case "remove":
+		return updatePointer(document, path, removeMember)
+
+	case "replace":
+		return updatePointer(document, path, func(container any, token string) (any, error) {
+			if _, err := getMember(container, token); err != nil {
+				return nil, err
+			}
+
+			container, _ = removeMember(container, token)
+			return addMember(container, token, value)
+		})
+
+	case "move", "copy":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+
+		if value, err = resolvePointer(document, from); err != nil {
+			return nil, err
+		}
+
+		if operation.Op == "move" {
+			if len(path) > len(from) && slices.Equal(path[:len(from)], from) {
+				return nil, fmt.Errorf("%w: cannot move a value into itself", errInvalidPatch)
+			}
+
+			if document, err = updatePointer(document, from, removeMember); err != nil {
+				return nil, err
+			}
+		} else {
+			// copy the value, so that the copies do not share containers
+			raw, _ := json.Marshal(value)
+			value, _ = decodeDocument(raw)
+		}
+
+		return updatePointer(document, path, func(container any, token string) (any, error) {
+			return addMember(container, token, value)
+		})
+
+	case "test":
+		actual, err := resolvePointer(document, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !equalDocuments(actual, value) {
+			return nil, fmt.Errorf("test failed")
+		}
+
+		return document, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unknown operation: %q", errInvalidPatch, operation.Op)
+
+	}
+}
+
+// parsePointer splits the JSON pointer (RFC 6901) into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: invalid pointer: %q", errInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return tokens, nil
+}
+
+// resolvePointer returns the value the tokens of the pointer refer to.
+func resolvePointer(document any, path []string) (any, error) {
+	for _, token := range path {
+		var err error
+		if document, err = getMember(document, token); err != nil {
+			return nil, err
+		}
+	}
+
+	return document, nil
+}
+
+// updatePointer calls the update with the container of the last token of the path and stores the returned container.
+// The root document cannot be updated.
+func updatePointer(document any, path []string, update func(container any, token string) (any, error)) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: the root document cannot be patched", errInvalidPatch)
+	}
+
+	if len(path) == 1 {
+		return update(document, path[0])
+	}
+
+	child, err := getMember(document, path[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if child, err = updatePointer(child, path[1:], update); err != nil {
+		return nil, err
+	}
+
+	return setMember(document, path[0], child)
+}
+
+// getMember returns the member of the object or the element of the array.
+func getMember(container any, token string) (any, error) {
+	switch container := container.(type) {
+	case map[string]any:
+		value, ok := container[token]
+		if !ok {
+			return nil, fmt.Errorf("member not found: %q", token)
+		}
+		return value, nil
+
+	case []any:
+		index, err := arrayIndex(token, len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		return container[index], nil
+
+	default:
+		return nil, fmt.Errorf("cannot resolve %q in a scalar value", token)
+
+	}
+}
+
+// setMember replaces the existing member of the object or the element of the array.
+func setMember(container any, token string, value any) (any, error) {
+	switch container := container.(type) {
+	case map[string]any:
+		container[token] = value
+		return container, nil
+
+	case []any:
+		index, err := arrayIndex(token, len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		container[index] = value
+		return container, nil
+
+	default:
+		return nil, fmt.Errorf("cannot resolve %q in a scalar value", token)
+
+	}
+}
+
+// addMember adds the member to the object or inserts the element into the array ("-" appends).
+func addMember(container any, token string, value any) (any, error) {
+	switch container := container.(type) {
+	case map[string]any:
+		container[token] = value
+		return container, nil
+
+	case []any:
+		if token == "-" {
+			return append(container, value), nil
+		}
+
+		index, err := arrayIndex(token, len(container))
+		if err != nil {
+			return nil, err
+		}
+		return slices.Insert(container, index, value), nil
+
+	default:
+		return nil, fmt.Errorf("cannot add %q to a scalar value", token)
+
+	}
+}
+
+// removeMember removes the existing member of the object or the element of the array.
+func removeMember(container any, token string) (any, error) {
+	if _, err := getMember(container, token); err != nil {
+		return nil, err
+	}
+
+	switch container := container.(type) {
+	case map[string]any:
+		delete(container, token)
+		return container, nil
+
+	default:
+		index, _ := arrayIndex(token, len(container.([]any))-1)
+		return slices.Delete(container.([]any), index, index+1), nil
+
+	}
+}
+
+// arrayIndex parses the array index of the token, which must not exceed the maximum.
+func arrayIndex(token string, maximum int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index: %q", errInvalidPatch, token)
+	}
+
+	if index > maximum {
+		return 0, fmt.Errorf("array index out of range: %d", index)
+	}
+
+	return index, nil
+}
+
+// equalDocuments compares the JSON values regardless of the representation of their numbers.
+func equalDocuments(a, b any) bool {
+	var normalized [2]any
+	for i, value := range []any{a, b} {
+		raw, err := json.Marshal(value)
+		if err != nil || json.Unmarshal(raw, &normalized[i]) != nil {
+			return false
+		}
+	}
+
+	return reflect.DeepEqual(normalized[0], normalized[1])
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		target string
+		patch  string
+		want   string
+	}{
+		{"test#1", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"test#2", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"test#3", `{"a":"b"}`, `{"a":null}`, `{}`},
+		{"test#4", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"test#5", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{"test#6", `{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{"test#7", `["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{"test#8", `{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			target, _ := decodeDocument([]byte(tt.target))
+			patch, _ := decodeDocument([]byte(tt.patch))
+			want, _ := decodeDocument([]byte(tt.want))
+
+			if got := mergePatch(target, patch); !equalDocuments(got, want) {
+				raw, _ := json.Marshal(got)
+				t.Errorf("mergePatch() = %s, want %s", raw, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		document string
+		patch    string
+		want     string
+		invalid  bool
+		conflict bool
+	}{
+		{"test#1", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`, false, false},
+		{"test#2", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, false, false},
+		{"test#3", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"baz"}]`, `{"foo":["bar","baz"]}`, false, false},
+		{"test#4", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, false, false},
+		{"test#5", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, false, false},
+		{"test#6", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, false, false},
+		{"test#7", `{"foo":{"bar":1}}`, `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`,
+			`{"foo":{"bar":1},"baz":{"bar":2}}`, false, false},
+		{"test#8", `{"a/b":{"m~n":1.0}}`, `[{"op":"test","path":"/a~1b/m~0n","value":1},{"op":"remove","path":"/a~1b/m~0n"}]`, `{"a/b":{}}`, false, false},
+		{"test#9", `{"foo":"bar"}`, `[{"op":"test","path":"/foo","value":"baz"}]`, "", false, true},
+		{"test#10", `{"foo":"bar"}`, `[{"op":"replace","path":"/missing","value":1}]`, "", false, true},
+		{"test#11", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":1}]`, "", false, true},
+		{"test#12", `{"foo":"bar"}`, `[{"op":"invalid","path":"/foo"}]`, "", true, false},
+		{"test#13", `{"foo":"bar"}`, `[{"op":"add","path":"foo","value":1}]`, "", true, false},
+		{"test#14", `{"foo":"bar"}`, `[{"op":"add","path":"/baz"}]`, "", true, false},
+		{"test#15", `{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, "", true, false},
+		{"test#16", `{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/01"}]`, "", true, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			document, _ := decodeDocument([]byte(tt.document))
+
+			var operations []JSONPatchOperation
+			if err := json.Unmarshal([]byte(tt.patch), &operations); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := applyJSONPatch(document, operations)
+			switch {
+			case tt.invalid || tt.conflict:
+				if err == nil || errors.Is(err, errInvalidPatch) != tt.invalid {
+					t.Errorf("applyJSONPatch() error = %v, want invalid %t", err, tt.invalid)
+				}
+
+			case err != nil:
+				t.Errorf("applyJSONPatch() error = %v", err)
+
+			default:
+				want, _ := decodeDocument([]byte(tt.want))
+				if !equalDocuments(got, want) {
+					raw, _ := json.Marshal(got)
+					t.Errorf("applyJSONPatch() = %s, want %s", raw, tt.want)
+				}
+
+			}
+		})
+	}
+}
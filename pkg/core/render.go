@@ -0,0 +1,175 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/extras"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+)
+
+// renderLock serializes the rendering and publishing of wallpapers.
+var renderLock sync.Mutex
+
+// HasOverlays returns true if any overlay is enabled.
+func (c *Config) HasOverlays() bool {
+	return c.DrawSystemInfo || c.CalendarFile != "" || c.DrawWeather || len(c.Messages) > 0
+}
+
+// DrawOverlays draws the overlays, which are refreshed independently of the Bing wallpaper.
+// The image without overlays is kept, so that the overlays can be redrawn without downloading the wallpaper again.
+func (img *Image) DrawOverlays(cfg *Config) error {
+	if img.base == nil {
+		img.base = img.Image
+	} else {
+		img.Image = img.base
+	}
+
+	if cfg.DrawSystemInfo {
+		text := cfg.SystemInfoTemplate
+		if cfg.SystemInfoTemplateFile != "" {
+			raw, err := os.ReadFile(cfg.SystemInfoTemplateFile)
+			if err != nil {
+				return err
+			}
+
+			text = string(raw)
+		}
+
+		if text == "" {
+			text = DefaultSystemInfoTemplate
+		}
+
+		info := GatherSystemInfo(cfg.SystemInfoOwner, systemRoot(), cfg.DownloadDirectory)
+		if err := img.DrawSystemInfo(info, text, cfg.SystemInfoPosition.Value(), extras.DefaultFontName); err != nil {
+			return err
+		}
+	}
+
+	if cfg.CalendarFile != "" {
+		calendar, err := LoadCalendar(cfg.CalendarFile)
+		if err != nil {
+			return err
+		}
+
+		if err := img.DrawCalendar(calendar, time.Now(), cfg.CalendarView.Value(), cfg.CalendarPosition.Value(), extras.DefaultFontName); err != nil {
+			return err
+		}
+	}
+
+	if cfg.DrawWeather {
+		// the weather is optional, hence the layer is skipped if the provider is not reachable (e.g. offline)
+		weatherCache.SetTTL(cfg.WeatherCacheTTL)
+		weather, err := weatherCache.Weather(cfg.WeatherLatitude, cfg.WeatherLongitude, cfg.WeatherForecastDays)
+		if err != nil {
+			logger.Logger.Printf("Skipping weather overlay: %v", err)
+		} else if err := img.DrawWeather(weather, cfg.WeatherLocationName, cfg.WeatherPosition.Value(), extras.DefaultFontName); err != nil {
+			return err
+		}
+	}
+
+	if len(cfg.Messages) > 0 {
+		if err := img.DrawMessages(cfg.Messages, time.Now(), extras.DefaultFontName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Publish extracts the palette, saves the image to the download directory and sets it as wallpaper.
+// The post-set hook is run after the wallpaper has been set.
+func (img *Image) Publish(cfg *Config) (string, error) {
+	if cfg.ExtractPalette {
+		if err := img.ExtractPalette(cfg.PaletteSize); err != nil {
+			return "", err
+		}
+	}
+
+	path, err := img.EncodeAndDump(cfg.DownloadDirectory, cfg.EncodeOptions())
+	if err != nil {
+		return "", err
+	}
+
+	logger.Logger.Printf("Wallpaper saved to: %s", path)
+	if cfg.ExtractPalette {
+		paletteDirectory := filepath.Join(cfg.DownloadDirectory, "palette")
+		if err := img.DumpPalette(paletteDirectory); err != nil {
+			return path, err
+		}
+
+		logger.Logger.Printf("Palette saved to: %s", paletteDirectory)
+	}
+
+	if policy := cfg.RetentionPolicy(); policy.Enabled() {
+		pruned, err := Prune(cfg.DownloadDirectory, policy, false)
+		if err != nil {
+			logger.Logger.Printf("Failed to prune the download directory: %v", err)
+		}
+
+		for _, entry := range pruned {
+			logger.Logger.Printf("Wallpaper pruned: %s", entry.Path)
+		}
+	}
+
+	if cfg.DownloadOnly {
+		return path, nil
+	}
+
+	if err := SetWallpaper(path, cfg.Mode.Value()); err != nil {
+		wallpaperSets.Add(1, "failure")
+		return path, err
+	}
+	wallpaperSets.Add(1, "success")
+
+	logger.Logger.Printf("Wallpaper set to: %s", path)
+	return path, RunHook(cfg.PostSetHook, img)
+}
+
+// republish saves the image to the download directory and sets it as wallpaper again, e.g. after the overlays have been redrawn.
+// Unlike Publish, the audio, the palette and the archive are left alone and the post-set hook is not run.
+func (img *Image) republish(cfg *Config) error {
+	opts := cfg.EncodeOptions()
+	opts.ImageOnly = true
+	path, err := img.EncodeAndDump(cfg.DownloadDirectory, opts)
+	if err != nil {
+		return err
+	}
+
+	if cfg.DownloadOnly {
+		return nil
+	}
+
+	return SetWallpaper(path, cfg.Mode.Value())
+}
+
+// EncodeOptions returns the options to encode the wallpaper with as configured.
+func (c *Config) EncodeOptions() EncodeOptions {
+	return EncodeOptions{
+		Format:           c.OutputFormat.Value(),
+		JPEGQuality:      c.JPEGQuality,
+		PNGCompression:   c.PNGCompression.Value(),
+		KeepOriginal:     c.KeepOriginal,
+		FileNameTemplate: c.FileNameTemplate,
+		Sidecar:          c.WriteSidecar,
+		Settings:         c,
+	}
+}
+
+// RefreshOverlays redraws the overlays of the image and sets it as wallpaper again (see republish).
+func (img *Image) RefreshOverlays(cfg *Config) error {
+	renderLock.Lock()
+	defer renderLock.Unlock()
+
+	if img == nil || img.Image == nil || img.base == nil {
+		return nil
+	}
+
+	if err := img.DrawOverlays(cfg); err != nil {
+		return err
+	}
+
+	return img.republish(cfg)
+}
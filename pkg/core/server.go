@@ -1,24 +1,37 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net"
 	"net/http"
-	"reflect"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
 	"strconv"
-	"sync"
+	"strings"
+	"time"
 
 	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
 )
 
+// eventKeepAliveInterval is the interval in which a comment is sent on idle event streams.
+const eventKeepAliveInterval = 15 * time.Second
+
 type Server struct {
 	config     *Config
 	controller *Controller
-	updateLock sync.Mutex
 	listener   net.Listener
 	server     *http.Server
+	// closing is closed when the server is stopped to end the event streams
+	closing chan struct{}
 }
 
 // NewServer creates a new server.
@@ -26,106 +39,225 @@ func NewServer(config *Config, controller *Controller) *Server {
 	return &Server{
 		config:     config,
 		controller: controller,
+		closing:    make(chan struct{}),
 	}
 }
 
 // GetPort returns the port number of the server.
 func (s *Server) GetPort() int {
-	if s.listener == nil {
-		return s.config.ApiPort
+	if s.listener != nil {
+		if addr, ok := s.listener.Addr().(*net.TCPAddr); ok {
+			return addr.Port
+		}
 	}
 
-	return s.listener.Addr().(*net.TCPAddr).Port
+	return s.controller.config().ApiPort
 }
 
 // Start starts the server.
+// The URL of the dashboard is logged once the server listens, i.e. with the port chosen by the listener.
 func (s *Server) Start() error {
+	cfg := s.controller.config()
+	if (cfg.ApiTLSCert == "") != (cfg.ApiTLSKey == "") {
+		return fmt.Errorf("both the TLS certificate and the TLS key of the API server are required")
+	}
+
+	if uri, err := url.Parse(cfg.ApiPublicURL); cfg.ApiPublicURL != "" && (err != nil || (uri.Scheme != "http" && uri.Scheme != "https") || uri.Host == "") {
+		return fmt.Errorf("the public URL of the API server must be an absolute HTTP(S) URL: %s", cfg.ApiPublicURL)
+	}
+
+	if err := s.listen(cfg); err != nil {
+		return err
+	}
+
+	if cfg.ApiSocket == "" {
+		logger.Logger.Printf("Dashboard available at: %s", apiURL(cfg, "/ui/", ""))
+	}
+
+	s.server = &http.Server{Handler: s.handler()}
+	if cfg.ApiTLSCert != "" {
+		return s.server.ServeTLS(s.listener, cfg.ApiTLSCert, cfg.ApiTLSKey)
+	}
+
+	return s.server.Serve(s.listener)
+}
+
+// handler returns the handler routing the requests to the endpoints.
+// The API requires the bearer token, if any, the dashboard is served without token and sends the token with its API requests.
+// The health endpoints are served without token for the probes of the monitoring.
+func (s *Server) handler() http.Handler {
 	router := http.NewServeMux()
 	router.HandleFunc("/config", s.handleConfig)
+	router.HandleFunc("/palette", s.handlePalette)
+	router.HandleFunc("/refresh", s.handleRefresh)
+	router.HandleFunc("/jobs/{id}", s.handleJob)
+	router.HandleFunc("/events", s.handleEvents)
+	router.HandleFunc("/wallpaper", s.handleWallpaper)
+	router.HandleFunc("/wallpaper/image", s.handleWallpaperImage)
+	router.HandleFunc("/wallpaper/original", s.handleWallpaperOriginal)
+	router.HandleFunc("/wallpaper/audio", s.handleWallpaperAudio)
+	router.HandleFunc("/wallpaper/description", s.handleWallpaperDescription)
+	router.HandleFunc("/archive/", s.handleArchive)
+	router.HandleFunc("/openapi.json", s.handleOpenAPI)
+	router.HandleFunc("/metrics", s.handleMetrics)
+	for _, format := range AllowedFeedFormats {
+		router.HandleFunc("/"+format.FileName(), s.handleFeed(format))
+	}
+
 	router.HandleFunc("/", s.handleRoot)
 
+	handler := http.NewServeMux()
+	handler.Handle("/ui/", s.handleUI())
+	handler.HandleFunc("/healthz", s.handleHealth)
+	handler.HandleFunc("/readyz", s.handleReady)
+	handler.Handle("/", requireToken(s.controller.config().ApiToken, router))
+
+	return handler
+}
+
+// listen opens the Unix domain socket, if configured, or the TCP address of the server.
+// The socket is accessible by the owner only, a socket left over by a previous run is replaced.
+func (s *Server) listen(cfg *Config) error {
+	if cfg.ApiSocket != "" {
+		if info, err := os.Lstat(cfg.ApiSocket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(cfg.ApiSocket)
+		}
+
+		var err error
+		if s.listener, err = net.Listen("unix", cfg.ApiSocket); err != nil {
+			return err
+		}
+
+		if err := os.Chmod(cfg.ApiSocket, 0o600); err != nil {
+			_ = s.listener.Close()
+			return err
+		}
+
+		logger.Logger.Printf("Starting API server on socket %s", cfg.ApiSocket)
+		return nil
+	}
+
 	var err error
-	s.listener, err = net.Listen("tcp", fmt.Sprintf(":%d", s.config.ApiPort))
-	if err != nil {
+	if s.listener, err = net.Listen("tcp", net.JoinHostPort(cfg.ApiBind, strconv.Itoa(cfg.ApiPort))); err != nil {
 		return err
 	}
 
-	s.config.ApiPort = s.listener.Addr().(*net.TCPAddr).Port
-	logger.Logger.Printf("Starting API server on port %d", s.config.ApiPort)
-
-	s.server = &http.Server{Handler: router}
-	return s.server.Serve(s.listener)
+	// the port is recorded in the config, e.g. if it has been chosen by the OS
+	cfg.ApiPort = s.listener.Addr().(*net.TCPAddr).Port
+	s.controller.cfgLock.Lock()
+	s.config.ApiPort = cfg.ApiPort
+	s.controller.cfgLock.Unlock()
+	logger.Logger.Printf("Starting API server on %s", s.listener.Addr())
+	return nil
 }
 
 // Stop stops the server.
 func (s *Server) Stop() error {
+	close(s.closing)
 	return s.server.Shutdown(context.Background())
 }
 
 // handleConfig handles the config endpoint.
 // It returns the current config when GET request is made.
-// It updates the config when PATCH request is made.
-// It refreshes the wallpaper when PATCH request with query parameter refresh=true is made.
+// It updates the config when PATCH request is made, the changed fields are validated (see Config.Patch)
+// and a list of field errors is returned with status 422 if any field is invalid, unknown or read-only.
+// The config document is identified by its ETag: GET request honours If-None-Match and PATCH request If-Match,
+// a PATCH request of a modified config is answered with status 412.
+// The patch is either a merge patch (RFC 7396, application/json or application/merge-patch+json)
+// or a JSON patch (RFC 6902, application/json-patch+json).
+// It starts a refresh job when PATCH request with query parameter refresh=true is made, even if no field has changed,
+// the job is linked by the Location header.
+// It redraws the overlays when the messages are updated without refreshing the wallpaper.
 func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	switch r.Method {
 	case http.MethodGet:
-		_ = json.NewEncoder(w).Encode(s.config)
+		raw, err := json.Marshal(s.controller.config())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		etag := configETag(raw)
+		w.Header().Set("ETag", etag)
+		if matchETag(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		_, _ = w.Write(append(raw, '\n'))
 
 	case http.MethodPatch:
-		s.updateLock.Lock()
-		defer s.updateLock.Unlock()
-
-		target := reflect.ValueOf(s.config).Elem()
-
-		// Create a new struct with the same fields as the original config
-		// but with pointers to the fields instead of the fields themselves
-		// so that we know which fields were provided in the request
-		fields := make([]reflect.StructField, 0, target.NumField())
-		for i := range target.NumField() {
-			field := target.Type().Field(i)
-			fields = append(fields, reflect.StructField{
-				Name: field.Name,
-				Type: reflect.PointerTo(field.Type),
-				Tag:  field.Tag,
-			})
-		}
-		source := reflect.New(reflect.StructOf(fields))
-
-		if err := json.NewDecoder(r.Body).Decode(source.Interface()); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		// Update the original config with the new values
-		updatedFields := 0
-		for i := range target.NumField() {
-			sourceField := source.Elem().Field(i)
-			targetField := target.Field(i)
-			if sourceField.IsNil() {
-				continue
+		// the entity tag is checked and the patch is applied under the config lock shared with the tray,
+		// so that no change made in between is overwritten
+		var status int
+		var fieldErrs []ConfigFieldError
+		var etag string
+		changes, err := s.controller.configure(func(cfg *Config) error {
+			if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !matchETag(ifMatch, cfg.ETag()) {
+				status, etag = http.StatusPreconditionFailed, cfg.ETag()
+				return errors.New("Config has been modified, the entity tag does not match")
 			}
 
-			if !reflect.DeepEqual(targetField.Interface(), sourceField.Elem().Interface()) {
-				targetField.Set(sourceField.Elem())
-				updatedFields++
+			document, code, err := patchedConfigDocument(cfg, r.Header.Get("Content-Type"), body)
+			if err != nil {
+				status = code
+				return err
 			}
+
+			patched, errs := cfg.PatchDocument(document)
+			if len(errs) > 0 {
+				status, fieldErrs = http.StatusUnprocessableEntity, errs
+				return errors.New("Invalid config")
+			}
+
+			*cfg = *patched
+			etag = cfg.ETag()
+			return nil
+		})
+		if err != nil {
+			if etag != "" {
+				w.Header().Set("ETag", etag)
+			}
+
+			if fieldErrs != nil {
+				w.WriteHeader(status)
+				_ = json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "fields": fieldErrs})
+				return
+			}
+
+			writeError(w, status, err.Error())
+			return
 		}
 
+		_, messagesUpdated := changes["messages"]
 		query := r.URL.Query()
-		if result, err := strconv.ParseBool(query.Get("refresh")); updatedFields > 0 && err == nil && result {
-			go func() {
-				s.updateLock.Lock()
-				defer s.updateLock.Unlock()
+		if result, err := strconv.ParseBool(query.Get("refresh")); err == nil && result {
+			// a running job has read the config already, the changes are applied by a job of their own
+			start := s.controller.jobs.Start
+			if len(changes) > 0 {
+				start = s.controller.jobs.Queue
+			}
 
-				autoPlayAudio := s.config.AutoPlayAudio
-				s.config.AutoPlayAudio = false
-				s.controller.OnReady()
-				s.config.AutoPlayAudio = autoPlayAudio
+			job, _ := start(JobTriggerAPI)
+			w.Header().Set("Location", "/jobs/"+job.ID)
+
+		} else if messagesUpdated {
+			go func() {
+				if err := s.controller.img.RefreshOverlays(s.controller.config()); err != nil {
+					logger.Logger.Printf("Failed to refresh overlays: %v", err)
+				}
 			}()
 		}
 
+		w.Header().Set("ETag", etag)
 		w.WriteHeader(http.StatusAccepted)
 
 	default:
@@ -136,6 +268,247 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// patchedConfigDocument applies the patch of the given media type to the config document.
+// Merge patches (RFC 7396, also sent as application/json) and JSON patches (RFC 6902) are supported.
+// If the patch cannot be applied, the status code of the error is returned.
+func patchedConfigDocument(cfg *Config, mediaType string, body []byte) (map[string]any, int, error) {
+	document, err := cfg.Document()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	contentType, _, _ := mime.ParseMediaType(mediaType)
+	switch contentType {
+	case "", "application/json", MediaTypeMergePatch:
+		patch, err := decodeDocument(body)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+
+		if _, ok := patch.(map[string]any); !ok {
+			return nil, http.StatusBadRequest, fmt.Errorf("%w: the patch must be an object", errInvalidPatch)
+		}
+
+		return mergePatch(document, patch).(map[string]any), 0, nil
+
+	case MediaTypeJSONPatch:
+		var operations []JSONPatchOperation
+		if err := json.Unmarshal(body, &operations); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+
+		patched, err := applyJSONPatch(document, operations)
+		switch {
+		case errors.Is(err, errInvalidPatch):
+			return nil, http.StatusBadRequest, err
+
+		case err != nil:
+			return nil, http.StatusConflict, err
+
+		}
+
+		return patched.(map[string]any), 0, nil
+
+	default:
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported media type: %s, expected any of: application/json, %s, %s", contentType, MediaTypeMergePatch, MediaTypeJSONPatch)
+
+	}
+}
+
+// matchETag returns true if any entity tag of the If-Match or If-None-Match header matches the entity tag.
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handlePalette handles the palette endpoint.
+// It returns the color palette of the current wallpaper when GET request is made.
+func (s *Server) handlePalette(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		logger.Logger.Printf("Method not allowed: %s", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed: " + r.Method})
+		return
+	}
+
+	img := s.currentWallpaper()
+	if img == nil || len(img.Palette) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "Palette not available"})
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(img.Palette.Document(img.Location))
+}
+
+// handleRefresh handles the refresh endpoint.
+// It starts a refresh job when POST request is made and returns it, the job is linked by the Location header.
+// If a job is running already, the request is coalesced into it.
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.Logger.Printf("Method not allowed: %s", r.Method)
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed: "+r.Method)
+		return
+	}
+
+	job, _ := s.controller.jobs.Start(JobTriggerAPI)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// handleJob handles the job endpoint.
+// It returns the state, the step timings and the errors of the refresh job when GET request is made.
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Logger.Printf("Method not allowed: %s", r.Method)
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed: "+r.Method)
+		return
+	}
+
+	job, ok := s.controller.jobs.Job(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Job not found: "+r.PathValue("id"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// handleEvents handles the events endpoint.
+// It streams the events of the event bus as server-sent events when GET request is made until the client disconnects.
+// The query parameter types limits the stream to the comma separated event types (e.g. wallpaper.changed,config.changed).
+// A comment is sent periodically to keep idle connections open.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.Logger.Printf("Method not allowed: %s", r.Method)
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed: "+r.Method)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	var types []string
+	if query := r.URL.Query().Get("types"); query != "" {
+		types = strings.Split(query, ",")
+	}
+
+	events, cancel := s.controller.events.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-s.closing:
+			return
+
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+
+		case event := <-events:
+			if len(types) > 0 && !slices.Contains(types, event.Type) {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.Logger.Printf("Failed to encode event: %v", err)
+				continue
+			}
+
+			_, _ = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// handleArchive handles the archive endpoint.
+// It serves the wallpapers of the download directory and their sidecars (e.g. audio) linked from the feeds.
+// Other files of the download directory are not exposed.
+func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		logger.Logger.Printf("Method not allowed: %s", r.Method)
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed: "+r.Method)
+		return
+	}
+
+	downloadDirectory := s.controller.config().DownloadDirectory
+	archive, err := LoadArchive(downloadDirectory)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	requested := filepath.Join(downloadDirectory, filepath.FromSlash(path.Clean("/"+strings.TrimPrefix(r.URL.Path, "/archive/"))))
+	for _, entry := range archive {
+		if requested == entry.Path || slices.Contains(entry.Sidecars, requested) {
+			http.ServeFile(w, r, requested)
+			return
+		}
+	}
+
+	s.handleRoot(w, r)
+}
+
+// handleFeed returns the handler of the feed endpoint of the given format.
+// It returns the feed of the most recently archived wallpapers when GET request is made,
+// the number of wallpapers can be limited by the query parameter limit. The files are linked by the public URL (see publicURL).
+func (s *Server) handleFeed(format FeedFormat) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			logger.Logger.Printf("Method not allowed: %s", r.Method)
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed: "+r.Method)
+			return
+		}
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		cfg := s.controller.config()
+		archive, err := LoadArchive(cfg.DownloadDirectory)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		var buffer bytes.Buffer
+		if err := WriteFeed(&buffer, archive, cfg.DownloadDirectory, format, FeedOptions{
+			BaseURL: publicURL(cfg, "/archive/"),
+			SelfURL: publicURL(cfg, "/"+format.FileName()),
+			Limit:   limit,
+		}); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		_, _ = buffer.WriteTo(w)
+	}
+}
+
 // handleRoot handles the root endpoint.
 // It returns a 404 error when the request is not found.
 func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
@@ -143,3 +516,10 @@ func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
 	_ = json.NewEncoder(w).Encode(map[string]string{"error": "Not found: " + r.URL.Path})
 }
+
+// writeError writes the error as JSON document.
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
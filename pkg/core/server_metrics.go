@@ -0,0 +1,95 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+)
+
+// HealthDocument reports the health of the daemon and the outcome of its refresh jobs.
+type HealthDocument struct {
+	Status                string    `json:"status"`
+	Reason                string    `json:"reason,omitempty"`
+	LastRefresh           time.Time `json:"lastRefresh,omitzero"`
+	LastRefreshState      string    `json:"lastRefreshState,omitempty"`
+	LastSuccessfulRefresh time.Time `json:"lastSuccessfulRefresh,omitzero"`
+}
+
+// handleMetrics handles the metrics endpoint.
+// It returns the metrics in the Prometheus text format when GET request is made.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		logger.Logger.Printf("Method not allowed: %s", r.Method)
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed: "+r.Method)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := WriteMetrics(w); err != nil {
+		logger.Logger.Printf("Failed to write metrics: %v", err)
+	}
+}
+
+// handleHealth handles the liveness endpoint.
+// It reports the last refresh and the last successful refresh when GET request is made, the daemon is alive as long as it responds.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		logger.Logger.Printf("Method not allowed: %s", r.Method)
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed: "+r.Method)
+		return
+	}
+
+	document := s.healthDocument()
+	document.Status = "ok"
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(document)
+}
+
+// handleReady handles the readiness endpoint.
+// The daemon is ready once a refresh has succeeded, which is not older than the maximum age (if any).
+// Otherwise, it answers 503 Service Unavailable, e.g. to alert when the wallpaper is not updated anymore.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		logger.Logger.Printf("Method not allowed: %s", r.Method)
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed: "+r.Method)
+		return
+	}
+
+	document := s.healthDocument()
+	document.Status = "ready"
+
+	maxAge := s.controller.config().ApiReadyMaxAge
+	switch age := time.Since(document.LastSuccessfulRefresh); {
+	case document.LastSuccessfulRefresh.IsZero():
+		document.Status, document.Reason = "not ready", "No refresh has succeeded yet"
+
+	case maxAge > 0 && age > maxAge:
+		document.Status, document.Reason = "not ready", fmt.Sprintf("The last successful refresh is older than %s", maxAge)
+
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if document.Reason != "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(document)
+}
+
+// healthDocument returns the outcome of the last refresh jobs.
+func (s *Server) healthDocument() HealthDocument {
+	var document HealthDocument
+	finished, succeeded := s.controller.jobs.LastFinished()
+	if finished != nil {
+		document.LastRefresh, document.LastRefreshState = finished.Finished, finished.State.String()
+	}
+
+	if succeeded != nil {
+		document.LastSuccessfulRefresh = succeeded.Finished
+	}
+
+	return document
+}
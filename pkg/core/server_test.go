@@ -2,26 +2,32 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	apiclient "github.com/sarumaj/bing-wallpaper-changer/pkg/client"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
 )
 
 func setupController(t *testing.T, cfg *Config, executed *bool) *Controller {
 	t.Helper()
 	img := &Image{}
-	return &Controller{
-		img: img,
-		cfg: cfg,
-		execute: func(cfg *Config) *Image {
-			if executed != nil {
-				*executed = true
-			}
-			return img
-		},
-	}
+	controller := newController(cfg, func(cfg *Config, job *Job) *Image {
+		if executed != nil {
+			*executed = true
+		}
+		return img
+	})
+	controller.img = img
+	return controller
 }
 
 func TestHandleConfigGET(t *testing.T) {
@@ -48,6 +54,32 @@ func TestHandleConfigGET(t *testing.T) {
 	}
 }
 
+func TestHandleConfigGETClient(t *testing.T) {
+	cfg := &Config{DimImage: 5, Messages: Messages{{Text: "Hello", Position: types.PositionTopCenter}}}
+	cfg.Mode.SetValues(AllowedModes...)
+	cfg.Mode.SetDefault(ModeCrop)
+	server := NewServer(cfg, setupController(t, cfg, nil))
+
+	w := httptest.NewRecorder()
+	server.handleConfig(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	// the config of the client mirrors the config document field by field
+	decoder := json.NewDecoder(w.Body)
+	decoder.DisallowUnknownFields()
+	var response apiclient.Config
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.DimImage != 5 || string(response.Mode) != cfg.Mode.String() || len(response.Messages) != 1 || response.Messages[0].Position != int(types.PositionTopCenter) {
+		t.Errorf("Expected the client config to mirror %+v, got %+v", cfg, response)
+	}
+
+	if fields := reflect.TypeFor[apiclient.Config]().NumField(); fields != len(configFieldIndex()) {
+		t.Errorf("Expected the client config to have %d fields, got %d", len(configFieldIndex()), fields)
+	}
+}
+
 func TestHandleConfigPATCH(t *testing.T) {
 	cfg := &Config{}
 	controller := setupController(t, cfg, nil)
@@ -96,6 +128,108 @@ func TestHandleConfigPATCHWithRefresh(t *testing.T) {
 	}
 }
 
+func TestHandleConfigPATCHUnchangedWithRefresh(t *testing.T) {
+	cfg := &Config{DimImage: 10}
+	executed := false
+	controller := setupController(t, cfg, &executed)
+	server := NewServer(cfg, controller)
+
+	req := httptest.NewRequest(http.MethodPatch, "/config?refresh=true", bytes.NewBufferString(`{"dimImage": 10}`))
+	w := httptest.NewRecorder()
+
+	server.handleConfig(w, req)
+
+	if w.Code != http.StatusAccepted || !strings.HasPrefix(w.Header().Get("Location"), "/jobs/") {
+		t.Fatalf("Expected status code %d and a job, got %d and %q", http.StatusAccepted, w.Code, w.Header().Get("Location"))
+	}
+
+	job, ok := controller.jobs.Job(strings.TrimPrefix(w.Header().Get("Location"), "/jobs/"))
+	if !ok {
+		t.Fatal("Expected the job to be found")
+	}
+
+	job.Wait()
+	if !executed {
+		t.Error("Expected OnReady to be called")
+	}
+}
+
+func TestHandleConfigPATCHMessages(t *testing.T) {
+	cfg := &Config{DownloadOnly: true, DownloadDirectory: t.TempDir()}
+	controller := setupController(t, cfg, nil)
+	server := NewServer(cfg, controller)
+
+	img := SetupTestImage(t)
+	img.base = img.Image
+	controller.img = img
+
+	body := []byte(`{"messages": [{"text": "[b]Release freeze[/b] until Friday", "position": 4}]}`)
+	req := httptest.NewRequest(http.MethodPatch, "/config", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	server.handleConfig(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	if len(cfg.Messages) != 1 || cfg.Messages[0].Text != "[b]Release freeze[/b] until Friday" || cfg.Messages[0].Position != types.PositionTopCenter {
+		t.Fatalf("Expected messages to be updated, got %+v", cfg.Messages)
+	}
+
+	// Give some time for the goroutine to redraw the overlays
+	redrawn := false
+	for range 100 {
+		renderLock.Lock()
+		redrawn = img.Image != img.base
+		renderLock.Unlock()
+		if redrawn {
+			break
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if !redrawn {
+		t.Error("Expected overlays to be redrawn")
+	}
+}
+
+func TestHandleConfigPATCHMessagesRejected(t *testing.T) {
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := &Config{DownloadOnly: true, DownloadDirectory: t.TempDir(), JPEGQuality: 90, Messages: Messages{{Text: "old", Position: types.PositionTopCenter, Expires: expires}}}
+	controller := setupController(t, cfg, nil)
+	server := NewServer(cfg, controller)
+
+	body := []byte(`{"messages": [{"text": "new", "position": 1}], "jpegQuality": 0}`)
+	req := httptest.NewRequest(http.MethodPatch, "/config", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	server.handleConfig(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+
+	if len(cfg.Messages) != 1 || cfg.Messages[0].Text != "old" || !cfg.Messages[0].Expires.Equal(expires) {
+		t.Fatalf("Expected messages to be unchanged, got %+v", cfg.Messages)
+	}
+
+	body = []byte(`{"messages": [{"text": "new", "position": 1}]}`)
+	req = httptest.NewRequest(http.MethodPatch, "/config", bytes.NewBuffer(body))
+	w = httptest.NewRecorder()
+
+	server.handleConfig(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	if len(cfg.Messages) != 1 || cfg.Messages[0].Text != "new" || !cfg.Messages[0].Expires.IsZero() {
+		t.Errorf("Expected messages to be replaced, got %+v", cfg.Messages)
+	}
+}
+
 func TestHandleConfigInvalidMethod(t *testing.T) {
 	cfg := &Config{}
 	controller := setupController(t, cfg, nil)
@@ -111,6 +245,42 @@ func TestHandleConfigInvalidMethod(t *testing.T) {
 	}
 }
 
+func TestHandlePalette(t *testing.T) {
+	cfg := &Config{}
+	controller := setupController(t, cfg, nil)
+	server := NewServer(cfg, controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/palette", nil)
+	w := httptest.NewRecorder()
+
+	server.handlePalette(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	controller.img.Update(SetupTestImage(t))
+	if err := controller.img.ExtractPalette(MinPaletteSize); err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	server.handlePalette(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response PaletteDocument
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(response.Colors) != MinPaletteSize || len(response.Terminal) != 16 {
+		t.Errorf("Expected %d colors and 16 terminal colors, got %d and %d", MinPaletteSize, len(response.Colors), len(response.Terminal))
+	}
+}
+
 func TestHandleRoot(t *testing.T) {
 	cfg := &Config{}
 	controller := setupController(t, cfg, nil)
@@ -125,3 +295,555 @@ func TestHandleRoot(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
 	}
 }
+
+func TestHandleFeed(t *testing.T) {
+	cfg := &Config{DownloadDirectory: t.TempDir(), ApiPublicURL: "https://wallpapers.example.org/bwc/"}
+	server := NewServer(cfg, setupController(t, cfg, nil))
+	paths := setupTestArchive(t, cfg.DownloadDirectory, 2, "{id}")
+
+	for _, format := range AllowedFeedFormats {
+		req := httptest.NewRequest(http.MethodGet, "/"+format.FileName(), nil)
+		req.Host = "attacker.example.com"
+		w := httptest.NewRecorder()
+
+		server.handleFeed(format)(w, req)
+
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != format.ContentType() {
+			t.Errorf("Expected status code %d and %s, got %d and %s", http.StatusOK, format.ContentType(), w.Code, w.Header().Get("Content-Type"))
+		}
+
+		if want := "https://wallpapers.example.org/bwc/archive/" + filepath.Base(paths[0]); !strings.Contains(w.Body.String(), want) || strings.Contains(w.Body.String(), req.Host) {
+			t.Errorf("Expected the feed to link %s, got %s", want, w.Body.String())
+		}
+	}
+
+	for path, want := range map[string]int{
+		"/archive/" + filepath.Base(paths[1]):    http.StatusOK,
+		"/archive/../" + filepath.Base(paths[1]): http.StatusBadRequest,
+		"/archive/palette/palette.json":          http.StatusNotFound,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/archive/", nil)
+		req.URL.Path = path
+		w := httptest.NewRecorder()
+
+		server.handleArchive(w, req)
+
+		if w.Code != want {
+			t.Errorf("Expected status code %d for %s, got %d", want, path, w.Code)
+		}
+	}
+}
+
+func TestHandleWallpaper(t *testing.T) {
+	cfg := &Config{DownloadDirectory: t.TempDir()}
+	controller := setupController(t, cfg, nil)
+	server := NewServer(cfg, controller)
+
+	req := httptest.NewRequest(http.MethodGet, "/wallpaper", nil)
+	w := httptest.NewRecorder()
+	server.handleWallpaper(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d without wallpaper, got %d", http.StatusNotFound, w.Code)
+	}
+
+	img := SetupTestImage(t)
+	img.Translation = "Folegandros, Greece"
+	img.original = []byte("original")
+	img.Audio = &Audio{Encoding: "MP3", Source: bytes.NewReader([]byte("0123456789"))}
+	if _, err := img.EncodeAndDump(cfg.DownloadDirectory, EncodeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	controller.img = img
+
+	w = httptest.NewRecorder()
+	server.handleWallpaper(w, req)
+
+	var document WallpaperDocument
+	if err := json.NewDecoder(w.Body).Decode(&document); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if document.ID != img.ID || document.Width != 1920 || document.Original == "" || document.Audio == "" {
+		t.Errorf("Expected the metadata of the wallpaper, got %+v", document)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleWallpaperImage(w, httptest.NewRequest(http.MethodGet, "/wallpaper/image", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" || etag == "" {
+		t.Errorf("Expected the image with ETag, got %d %v", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/wallpaper/image", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	server.handleWallpaperImage(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotModified, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleWallpaperOriginal(w, httptest.NewRequest(http.MethodGet, "/wallpaper/original", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "original" || w.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("Expected the original image, got %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/wallpaper/audio", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	w = httptest.NewRecorder()
+	server.handleWallpaperAudio(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" || w.Header().Get("Content-Type") != "audio/mpeg" {
+		t.Errorf("Expected the requested range of the audio, got %d %q", w.Code, w.Body.String())
+	}
+
+	for lang, want := range map[string]string{
+		"":      img.Title + ", " + img.Copyright,
+		"de-DE": img.Title + ", " + img.Copyright,
+		"en":    img.Translation,
+		"fr":    "",
+	} {
+		w = httptest.NewRecorder()
+		server.handleWallpaperDescription(w, httptest.NewRequest(http.MethodGet, "/wallpaper/description?lang="+lang, nil))
+
+		var document DescriptionDocument
+		_ = json.NewDecoder(w.Body).Decode(&document)
+		if (want == "" && w.Code != http.StatusNotFound) || document.Description != want {
+			t.Errorf("Expected description %q for lang=%s, got %d %+v", want, lang, w.Code, document)
+		}
+	}
+}
+
+func TestHandleRefresh(t *testing.T) {
+	cfg := &Config{}
+	executed := false
+	server := NewServer(cfg, setupController(t, cfg, &executed))
+
+	w := httptest.NewRecorder()
+	server.handleRefresh(w, httptest.NewRequest(http.MethodPost, "/refresh", nil))
+
+	if w.Code != http.StatusAccepted || !strings.HasPrefix(w.Header().Get("Location"), "/jobs/") {
+		t.Fatalf("Expected status code %d and job location, got %d %v", http.StatusAccepted, w.Code, w.Header())
+	}
+
+	var started struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&started); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	job, ok := server.controller.jobs.Job(started.ID)
+	if !ok {
+		t.Fatalf("Expected job %s to be tracked", started.ID)
+	}
+	job.Wait()
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/"+started.ID, nil)
+	req.SetPathValue("id", started.ID)
+	w = httptest.NewRecorder()
+	server.handleJob(w, req)
+
+	var status struct {
+		State   string `json:"state"`
+		Trigger string `json:"trigger"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if w.Code != http.StatusOK || status.State != "succeeded" || status.Trigger != JobTriggerAPI || !executed {
+		t.Errorf("Expected succeeded job, got %d %+v", w.Code, status)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/jobs/unknown", nil)
+	req.SetPathValue("id", "unknown")
+	w = httptest.NewRecorder()
+	server.handleJob(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleRefresh(w, httptest.NewRequest(http.MethodGet, "/refresh", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHandleEvents(t *testing.T) {
+	cfg := &Config{}
+	server := NewServer(cfg, setupController(t, cfg, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.handleEvents(w, httptest.NewRequest(http.MethodGet, "/events?types=config.changed,refresh.succeeded", nil).WithContext(ctx))
+	}()
+
+	// wait for the stream to subscribe
+	for subscribed := false; !subscribed; time.Sleep(10 * time.Millisecond) {
+		server.controller.events.mu.Lock()
+		subscribed = len(server.controller.events.subscribers) > 0
+		server.controller.events.mu.Unlock()
+	}
+
+	patch := httptest.NewRecorder()
+	server.handleConfig(patch, httptest.NewRequest(http.MethodPatch, "/config?refresh=true", strings.NewReader(`{"dimImage": 25}`)))
+	if patch.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code %d, got %d", http.StatusAccepted, patch.Code)
+	}
+
+	job, ok := server.controller.jobs.Job(strings.TrimPrefix(patch.Header().Get("Location"), "/jobs/"))
+	if !ok {
+		t.Fatalf("Expected refresh job to be started")
+	}
+	job.Wait()
+
+	// wait for the last event to be streamed
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	if w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected event stream, got %s", w.Header().Get("Content-Type"))
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"event: config.changed\n",
+		`"dimImage":{"old":0,"new":25}`,
+		"event: refresh.succeeded\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected stream to contain %q, got %s", want, body)
+		}
+	}
+
+	if strings.Contains(body, "event: refresh.started") {
+		t.Errorf("Expected stream to skip filtered events, got %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleEvents(w, httptest.NewRequest(http.MethodPost, "/events", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHandleConfigPATCHValidation(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		body   string
+		want   int
+		fields []string
+	}{
+		{"test#1", `{"region": "ja-JP", "dimImage": 30, "jpegQuality": 80}`, http.StatusAccepted, nil},
+		{"test#2", `{"mode": {"value": 2, "values": [2]}}`, http.StatusAccepted, nil},
+		{"test#3", `{"region": "xx-XX", "dimImage": 130, "jpegQuality": 0}`, http.StatusUnprocessableEntity, []string{"dimImage", "jpegQuality", "region"}},
+		{"test#4", `{"apiPort": 1, "unknown": true}`, http.StatusUnprocessableEntity, []string{"apiPort", "unknown"}},
+		{"test#5", `{"daemon": false, "downloadDirectory": "/nonexistent/directory"}`, http.StatusUnprocessableEntity, []string{"daemon", "downloadDirectory"}},
+		{"test#6", `{"messages": [{"text": "Hello", "position": 42}], "fileNameTemplate": "{unknown}"}`, http.StatusUnprocessableEntity, []string{"fileNameTemplate", "messages"}},
+		{"test#7", `{"daemon": true, "downloadDirectory": "` + filepath.ToSlash(os.TempDir()) + `"}`, http.StatusAccepted, nil},
+		{"test#8", `{"dimImage": "ten", "mode": {"value": 42}}`, http.StatusUnprocessableEntity, []string{"dimImage", "mode"}},
+		{"test#9", `{"textWatermarkSize": 1e6}`, http.StatusUnprocessableEntity, []string{"textWatermarkSize"}},
+		{"test#10", `{"messages": [{"source": "/etc/passwd", "position": 4}]}`, http.StatusUnprocessableEntity, []string{"messages"}},
+		{"test#11", `{"messages": [{"source": "http://169.254.169.254/latest/meta-data/", "position": 4}]}`, http.StatusUnprocessableEntity, []string{"messages"}},
+		{"test#12", `{"messages": [{"source": "https://example.com/motd.txt", "position": 4}, {"text": "Hello", "position": 1}]}`, http.StatusAccepted, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Daemon: true, JPEGQuality: DefaultJPEGQuality, Messages: Messages{{Source: "https://example.com/motd.txt", Position: types.PositionTopCenter}}}
+			cfg.Region.SetDefault(types.RegionGermany)
+			cfg.Region.SetValues(types.AllowedRegions...)
+			cfg.Mode.SetValues(AllowedModes...)
+			server := NewServer(cfg, setupController(t, cfg, nil))
+			original := *cfg
+
+			w := httptest.NewRecorder()
+			server.handleConfig(w, httptest.NewRequest(http.MethodPatch, "/config", strings.NewReader(tt.body)))
+
+			if w.Code != tt.want {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.want, w.Code, w.Body)
+			}
+
+			if tt.want != http.StatusUnprocessableEntity {
+				return
+			}
+
+			var response struct {
+				Fields []ConfigFieldError `json:"fields"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			var fields []string
+			for _, field := range response.Fields {
+				fields = append(fields, field.Field)
+			}
+
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("Expected errors of fields %v, got %+v", tt.fields, response.Fields)
+			}
+
+			if len(diffConfig(&original, cfg)) > 0 {
+				t.Errorf("Expected config to be unchanged, got %v", diffConfig(&original, cfg))
+			}
+		})
+	}
+}
+
+func TestHandleConfigConcurrency(t *testing.T) {
+	cfg := &Config{DimImage: 5, DownloadDirectory: t.TempDir()}
+	server := NewServer(cfg, setupController(t, cfg, nil))
+
+	w := httptest.NewRecorder()
+	server.handleConfig(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || etag != cfg.ETag() {
+		t.Fatalf("Expected status code %d and ETag %s, got %d %q", http.StatusOK, cfg.ETag(), w.Code, etag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/config", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	server.handleConfig(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotModified, w.Code)
+	}
+
+	for _, tt := range []struct {
+		name        string
+		contentType string
+		ifMatch     string
+		body        string
+		want        int
+		dimImage    types.Percent
+	}{
+		{"test#1", MediaTypeMergePatch, etag, `{"dimImage": 10}`, http.StatusAccepted, 10},
+		{"test#2", MediaTypeMergePatch, etag, `{"dimImage": 20}`, http.StatusPreconditionFailed, 10},
+		{"test#3", MediaTypeJSONPatch, "*", `[{"op": "test", "path": "/dimImage", "value": 10}, {"op": "replace", "path": "/dimImage", "value": 30}]`, http.StatusAccepted, 30},
+		{"test#4", MediaTypeJSONPatch, "", `[{"op": "test", "path": "/dimImage", "value": 10}, {"op": "replace", "path": "/dimImage", "value": 40}]`, http.StatusConflict, 30},
+		{"test#5", MediaTypeJSONPatch, "", `[{"op": "remove", "path": "/dimImage"}]`, http.StatusUnprocessableEntity, 30},
+		{"test#6", MediaTypeJSONPatch, "", `[{"op": "copy", "from": "/dimImage", "path": "/watermarkOpacity"}]`, http.StatusAccepted, 30},
+		{"test#7", MediaTypeJSONPatch, "", `{"op": "replace"}`, http.StatusBadRequest, 30},
+		{"test#8", "text/plain", "", `dimImage=50`, http.StatusUnsupportedMediaType, 30},
+		{"test#9", "application/json; charset=utf-8", "", `[1]`, http.StatusBadRequest, 30},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/config", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			w := httptest.NewRecorder()
+			server.handleConfig(w, req)
+			if w.Code != tt.want || cfg.DimImage != tt.dimImage {
+				t.Errorf("Expected status code %d and DimImage %g, got %d %g: %s", tt.want, tt.dimImage, w.Code, cfg.DimImage, w.Body)
+			}
+
+			if (w.Code == http.StatusAccepted || w.Code == http.StatusPreconditionFailed) && w.Header().Get("ETag") != cfg.ETag() {
+				t.Errorf("Expected current ETag %s, got %s", cfg.ETag(), w.Header().Get("ETag"))
+			}
+		})
+	}
+
+	if cfg.WatermarkOpacity != 30 {
+		t.Errorf("Expected WatermarkOpacity to be copied, got %g", cfg.WatermarkOpacity)
+	}
+
+	// an edit from the tray between reading and patching the config invalidates the entity tag
+	etag = cfg.ETag()
+	if _, err := server.controller.configure(func(cfg *Config) error { cfg.DrawQRCode = true; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/config", strings.NewReader(`{"drawQRCode": false}`))
+	req.Header.Set("Content-Type", MediaTypeMergePatch)
+	req.Header.Set("If-Match", etag)
+	w = httptest.NewRecorder()
+	server.handleConfig(w, req)
+	if w.Code != http.StatusPreconditionFailed || !cfg.DrawQRCode {
+		t.Errorf("Expected status code %d and the edit from the tray to be kept, got %d %t", http.StatusPreconditionFailed, w.Code, cfg.DrawQRCode)
+	}
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	cfg := &Config{ApiToken: "secret", ApiPort: 8080}
+	cfg.QRCodePosition.SetValues(types.PositionTopLeft, types.PositionBottomRight)
+	server := NewServer(cfg, setupController(t, cfg, nil))
+
+	w := httptest.NewRecorder()
+	server.handleOpenAPI(w, httptest.NewRequest(http.MethodGet, "http://attacker.example.com/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var document struct {
+		OpenAPI string `json:"openapi"`
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Paths map[string]map[string]struct {
+			Responses map[string]any `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					Type     string `json:"type"`
+					ReadOnly bool   `json:"readOnly"`
+					OneOf    []struct {
+						Enum []string `json:"enum"`
+					} `json:"oneOf"`
+				} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&document); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if document.OpenAPI != "3.1.0" || len(document.Servers) != 1 || document.Servers[0].URL != "http://localhost:8080" {
+		t.Errorf("Expected OpenAPI 3.1.0 served at http://localhost:8080, got %s at %+v", document.OpenAPI, document.Servers)
+	}
+
+	for _, path := range []string{"/config", "/palette", "/refresh", "/jobs/{id}", "/events", "/wallpaper", "/wallpaper/image", "/wallpaper/description", "/feed.atom", "/openapi.json"} {
+		if _, ok := document.Paths[path]; !ok {
+			t.Errorf("Expected path %s to be specified", path)
+		}
+	}
+
+	if _, ok := document.Paths["/config"]["get"].Responses["401"]; !ok {
+		t.Errorf("Expected unauthorized response to be specified, got %v", document.Paths["/config"]["get"].Responses)
+	}
+
+	if _, ok := document.Paths["/ui/"]["get"].Responses["401"]; ok {
+		t.Errorf("Expected dashboard to be served without token")
+	}
+
+	properties := document.Components.Schemas["Config"].Properties
+	if _, ok := properties["apiPort"]; ok || len(properties) == 0 {
+		t.Errorf("Expected hidden fields to be skipped, got %v", properties)
+	}
+
+	if !properties["daemon"].ReadOnly || properties["daemon"].Type != "boolean" {
+		t.Errorf("Expected read-only boolean daemon, got %+v", properties["daemon"])
+	}
+
+	if oneOf := properties["qrCodePosition"].OneOf; len(oneOf) != 2 || strings.Join(oneOf[0].Enum, ",") != "TopLeft,BottomRight" {
+		t.Errorf("Expected enum values TopLeft,BottomRight, got %+v", oneOf)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleOpenAPI(w, httptest.NewRequest(http.MethodPost, "/openapi.json", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHandleUI(t *testing.T) {
+	cfg := &Config{ApiToken: "secret"}
+	server := NewServer(cfg, setupController(t, cfg, nil))
+	handler := server.handler()
+
+	for _, tt := range []struct {
+		name        string
+		method      string
+		path        string
+		wantCode    int
+		contentType string
+	}{
+		{"test#1", http.MethodGet, "/ui", http.StatusTemporaryRedirect, ""},
+		{"test#2", http.MethodGet, "/ui/", http.StatusOK, "text/html"},
+		{"test#3", http.MethodGet, "/ui/app.js", http.StatusOK, "text/javascript"},
+		{"test#4", http.MethodGet, "/ui/style.css", http.StatusOK, "text/css"},
+		{"test#5", http.MethodPost, "/ui/", http.StatusMethodNotAllowed, "application/json"},
+		{"test#6", http.MethodGet, "/config", http.StatusUnauthorized, "application/json"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status code %d, got %d", tt.wantCode, w.Code)
+			}
+
+			if !strings.HasPrefix(w.Header().Get("Content-Type"), tt.contentType) {
+				t.Errorf("Expected content type %s, got %s", tt.contentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestHandleHealth(t *testing.T) {
+	cfg := &Config{ApiToken: "secret"}
+	server := NewServer(cfg, setupController(t, cfg, nil))
+	handler := server.handler()
+
+	probe := func(path string) (int, HealthDocument) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		var document HealthDocument
+		if err := json.NewDecoder(w.Body).Decode(&document); err != nil {
+			t.Fatalf("Failed to decode response of %s: %v", path, err)
+		}
+		return w.Code, document
+	}
+
+	if code, document := probe("/healthz"); code != http.StatusOK || document.Status != "ok" || !document.LastRefresh.IsZero() {
+		t.Errorf("Expected alive daemon without refresh, got %d %+v", code, document)
+	}
+
+	if code, document := probe("/readyz"); code != http.StatusServiceUnavailable || document.Reason == "" {
+		t.Errorf("Expected daemon not to be ready before the first refresh, got %d %+v", code, document)
+	}
+
+	job, _ := server.controller.jobs.Start(JobTriggerAPI)
+	job.Wait()
+
+	code, document := probe("/readyz")
+	if code != http.StatusOK || document.Status != "ready" || document.LastRefreshState != "succeeded" || !document.LastSuccessfulRefresh.Equal(job.Finished) {
+		t.Errorf("Expected daemon to be ready after the refresh, got %d %+v", code, document)
+	}
+
+	cfg.ApiReadyMaxAge = time.Nanosecond
+	if code, document := probe("/readyz"); code != http.StatusServiceUnavailable || !strings.Contains(document.Reason, "older than") {
+		t.Errorf("Expected daemon not to be ready after the maximum age, got %d %+v", code, document)
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	cfg := &Config{}
+	server := NewServer(cfg, setupController(t, cfg, nil))
+
+	job, _ := server.controller.jobs.Start(JobTriggerTray)
+	job.Wait()
+
+	w := httptest.NewRecorder()
+	server.handleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("Expected metrics in text format, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE bing_wallpaper_changer_http_requests_total counter\n",
+		"# TYPE bing_wallpaper_changer_render_duration_seconds histogram\n",
+		"bing_wallpaper_changer_download_bytes_total ",
+		`bing_wallpaper_changer_refresh_total{trigger="tray",state="succeeded"} `,
+		`bing_wallpaper_changer_refresh_duration_seconds_bucket{trigger="tray",le="+Inf"} `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q, got %s", want, body)
+		}
+	}
+
+	if strings.Contains(body, "bing_wallpaper_changer_last_refresh_success_timestamp_seconds 0\n") {
+		t.Errorf("Expected time of the last successful refresh to be recorded")
+	}
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+)
+
+// uiFiles are the static files of the web dashboard.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// handleUI returns the handler of the web dashboard served at /ui/.
+// The dashboard consists of static files only and is served without bearer token,
+// it calls the API with the token entered by the user (or passed in the fragment of the URL, e.g. /ui/#token=...).
+func (s *Server) handleUI() http.Handler {
+	files, _ := fs.Sub(uiFiles, "ui")
+	fileServer := http.StripPrefix("/ui/", http.FileServerFS(files))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			logger.Logger.Printf("Method not allowed: %s", r.Method)
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed: "+r.Method)
+			return
+		}
+
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; img-src 'self' blob:; media-src 'self' blob:")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		fileServer.ServeHTTP(w, r)
+	})
+}
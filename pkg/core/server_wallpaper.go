@@ -0,0 +1,221 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/logger"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+)
+
+type (
+	// WallpaperDocument describes the current wallpaper.
+	WallpaperDocument struct {
+		Metadata
+		Furigana string `json:"furigana,omitempty"`
+		Location string `json:"location"`
+		Width    int    `json:"width"`
+		Height   int    `json:"height"`
+		Image    string `json:"image"`
+		Original string `json:"original,omitempty"`
+		Audio    string `json:"audio,omitempty"`
+	}
+
+	// DescriptionDocument is the description of the current wallpaper in the requested language.
+	DescriptionDocument struct {
+		Language    string `json:"language"`
+		Description string `json:"description"`
+		Furigana    string `json:"furigana,omitempty"`
+	}
+)
+
+// currentWallpaper returns a copy of the current wallpaper or nil if no wallpaper has been set yet.
+func (s *Server) currentWallpaper() *Image {
+	renderLock.Lock()
+	defer renderLock.Unlock()
+
+	img := s.controller.img
+	if img == nil || img.Image == nil {
+		return nil
+	}
+
+	current := *img
+	if img.Audio != nil {
+		audio := *img.Audio
+		current.Audio = &audio
+	}
+
+	return &current
+}
+
+// handleWallpaper handles the wallpaper endpoint.
+// It returns the metadata of the current wallpaper and the links to its image, original image and audio description when GET request is made.
+func (s *Server) handleWallpaper(w http.ResponseWriter, r *http.Request) {
+	img, ok := s.wallpaperRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newWallpaperDocument(img))
+}
+
+// newWallpaperDocument describes the wallpaper, it is also the payload of the wallpaper changed event.
+func newWallpaperDocument(img *Image) WallpaperDocument {
+	document := WallpaperDocument{
+		Metadata: img.Metadata(),
+		Furigana: img.Furigana,
+		Location: img.Location,
+		Image:    "/wallpaper/image",
+	}
+
+	if img.Image != nil {
+		document.Width, document.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	}
+
+	if len(img.original) > 0 {
+		document.Original = "/wallpaper/original"
+	}
+
+	if img.Audio != nil && img.Audio.Location != "" {
+		document.Audio = "/wallpaper/audio"
+	}
+
+	return document
+}
+
+// handleWallpaperImage handles the wallpaper image endpoint.
+// It returns the rendered image of the current wallpaper as saved in the download directory when GET request is made.
+// The ETag is the hash of the image, hence conditional and range requests are supported.
+func (s *Server) handleWallpaperImage(w http.ResponseWriter, r *http.Request) {
+	img, ok := s.wallpaperRequest(w, r)
+	if !ok {
+		return
+	}
+
+	content, err := os.ReadFile(img.Location)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Wallpaper image not available")
+		return
+	}
+
+	serveWallpaperContent(w, r, img.Location, content)
+}
+
+// handleWallpaperOriginal handles the wallpaper original endpoint.
+// It returns the image of the current wallpaper as downloaded from Bing when GET request is made.
+func (s *Server) handleWallpaperOriginal(w http.ResponseWriter, r *http.Request) {
+	img, ok := s.wallpaperRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if len(img.original) == 0 {
+		writeError(w, http.StatusNotFound, "Original wallpaper not available")
+		return
+	}
+
+	name := "original.jpg"
+	if parsed, err := url.Parse(img.DownloadURL); err == nil && filepath.Ext(parsed.Query().Get("id")) != "" {
+		name = parsed.Query().Get("id")
+	}
+
+	serveWallpaperContent(w, r, name, img.original)
+}
+
+// handleWallpaperAudio handles the wallpaper audio endpoint.
+// It returns the audio description of the current wallpaper when GET request is made.
+// Range requests are supported, so that the audio can be streamed.
+func (s *Server) handleWallpaperAudio(w http.ResponseWriter, r *http.Request) {
+	img, ok := s.wallpaperRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if img.Audio == nil || img.Audio.Location == "" {
+		writeError(w, http.StatusNotFound, "Audio description not available")
+		return
+	}
+
+	content, err := os.ReadFile(img.Audio.Location)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Audio description not available")
+		return
+	}
+
+	serveWallpaperContent(w, r, img.Audio.Location, content)
+}
+
+// handleWallpaperDescription handles the wallpaper description endpoint.
+// It returns the description of the current wallpaper when GET request is made.
+// The query parameter lang selects the language (e.g. en or de-DE): the language of the region returns the original description,
+// English returns the translation. Without the parameter, the description as drawn is returned.
+func (s *Server) handleWallpaperDescription(w http.ResponseWriter, r *http.Request) {
+	img, ok := s.wallpaperRequest(w, r)
+	if !ok {
+		return
+	}
+
+	metadata := img.Metadata()
+	document := DescriptionDocument{Language: metadata.Region, Description: metadata.Description}
+	lang := strings.ToLower(r.URL.Query().Get("lang"))
+	primary, _, _ := strings.Cut(lang, "-")
+
+	switch {
+	case lang == "":
+		// the description as drawn
+
+	case img.Region != (types.Region{}) && primary == img.Region.LanguageCode:
+		document.Description, document.Furigana = img.Title+", "+img.Copyright, img.Furigana
+		if img.Title == "" {
+			document.Description = metadata.Description
+		}
+
+	case primary == types.RegionUnitedStates.LanguageCode && img.Translation != "":
+		document.Language, document.Description = types.RegionUnitedStates.String(), img.Translation
+
+	default:
+		writeError(w, http.StatusNotFound, "Description not available in language: "+lang)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(document)
+}
+
+// wallpaperRequest checks the method of the request and returns the current wallpaper.
+// If the request cannot be served, the error is written and false is returned.
+func (s *Server) wallpaperRequest(w http.ResponseWriter, r *http.Request) (*Image, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		logger.Logger.Printf("Method not allowed: %s", r.Method)
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed: "+r.Method)
+		return nil, false
+	}
+
+	img := s.currentWallpaper()
+	if img == nil {
+		writeError(w, http.StatusNotFound, "Wallpaper not available")
+		return nil, false
+	}
+
+	return img, true
+}
+
+// serveWallpaperContent serves the content with the media type derived from the name
+// and the hash of the content as ETag, http.ServeContent handles conditional and range requests.
+func serveWallpaperContent(w http.ResponseWriter, r *http.Request, name string, content []byte) {
+	w.Header().Set("Content-Type", mediaType(name))
+
+	sum := sha256.Sum256(content)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
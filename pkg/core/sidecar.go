@@ -0,0 +1,109 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sidecarExtension is the extension of the JSON sidecar describing a saved wallpaper.
+const sidecarExtension = ".json"
+
+type (
+	// Sidecar describes a saved wallpaper, so that the download directory is self-describing without any database.
+	// It is saved as JSON file next to the wallpaper sharing its base name.
+	Sidecar struct {
+		Metadata
+		Furigana string                 `json:"furigana,omitempty"`
+		Drawn    string                 `json:"drawnDescription,omitempty"`
+		Bing     json.RawMessage        `json:"bing,omitempty"`
+		Palette  Palette                `json:"palette,omitempty"`
+		Files    map[string]SidecarFile `json:"files"`
+		Config   *Config                `json:"config,omitempty"`
+		SavedAt  time.Time              `json:"savedAt"`
+	}
+
+	// SidecarFile describes a file of the saved wallpaper (e.g. image, original image, audio).
+	// The name is relative to the directory of the sidecar.
+	SidecarFile struct {
+		Name   string `json:"name"`
+		Size   int64  `json:"size"`
+		SHA256 string `json:"sha256"`
+	}
+)
+
+// ReadSidecar reads the sidecar of the wallpaper at the given path.
+func ReadSidecar(path string) (*Sidecar, error) {
+	raw, err := os.ReadFile(sidecarPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var sidecar Sidecar
+	if err := json.Unmarshal(raw, &sidecar); err != nil {
+		return nil, err
+	}
+
+	return &sidecar, nil
+}
+
+// dumpSidecar writes the sidecar of the wallpaper saved at the given path along with the files of the given roles.
+// Secrets are removed from the snapshot of the config.
+func (img *Image) dumpSidecar(path string, files map[string]string, cfg *Config) error {
+	sidecar := Sidecar{
+		Metadata: img.Metadata(),
+		Furigana: img.Furigana,
+		Drawn:    img.Description,
+		Bing:     img.Bing,
+		Palette:  img.Palette,
+		Files:    make(map[string]SidecarFile),
+		SavedAt:  time.Now(),
+	}
+
+	if cfg != nil {
+		snapshot := *cfg
+		snapshot.FuriganaApiAppId = ""
+		sidecar.Config = &snapshot
+	}
+
+	for role, file := range files {
+		described, err := describeFile(file)
+		if err != nil {
+			return err
+		}
+
+		sidecar.Files[role] = described
+	}
+
+	return writeFileAtomic(sidecarPath(path), func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(sidecar)
+	})
+}
+
+// describeFile returns the name, the size and the SHA-256 hash of the file.
+func describeFile(path string) (SidecarFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SidecarFile{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return SidecarFile{}, err
+	}
+
+	return SidecarFile{Name: filepath.Base(path), Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// sidecarPath returns the path of the sidecar of the wallpaper at the given path.
+func sidecarPath(path string) string {
+	return path[:len(path)-len(filepath.Ext(path))] + sidecarExtension
+}
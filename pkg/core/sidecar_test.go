@@ -0,0 +1,97 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestDumpSidecar(t *testing.T) {
+	f, err := testData.Open("bing.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	original, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		args      EncodeOptions
+		wantFiles []string
+	}{
+		{"test#1", EncodeOptions{}, nil},
+		{"test#2", EncodeOptions{Sidecar: true}, []string{"image"}},
+		{"test#3", EncodeOptions{Sidecar: true, KeepOriginal: true, Settings: &Config{FuriganaApiAppId: "secret", KeepOriginal: true}}, []string{"image", "original"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			img := SetupTestImage(t)
+			img.original = original
+			img.Furigana = "富士山[ふじさん]"
+
+			path, err := img.EncodeAndDump(t.TempDir(), tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sidecar, err := ReadSidecar(path)
+			if len(tt.wantFiles) == 0 {
+				if !os.IsNotExist(err) {
+					t.Errorf("ReadSidecar() error = %v, want not exist", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sidecar.ID != img.ID || sidecar.Title != img.Title || sidecar.Furigana != img.Furigana || sidecar.Drawn != img.Description {
+				t.Errorf("ReadSidecar() = %+v, does not describe %+v", sidecar, img)
+			}
+
+			if got, want := gjson.GetBytes(sidecar.Bing, "urlbase").String(), gjson.GetBytes(img.Bing, "urlbase").String(); got == "" || got != want {
+				t.Errorf("ReadSidecar().Bing.urlbase = %q, want %q", got, want)
+			}
+
+			if len(sidecar.Files) != len(tt.wantFiles) {
+				t.Errorf("ReadSidecar().Files = %v, want %v", sidecar.Files, tt.wantFiles)
+			}
+
+			for _, role := range tt.wantFiles {
+				file, ok := sidecar.Files[role]
+				if !ok {
+					t.Errorf("ReadSidecar().Files[%q] missing", role)
+					continue
+				}
+
+				content, err := os.ReadFile(filepath.Join(filepath.Dir(path), file.Name))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if sum := sha256.Sum256(content); file.SHA256 != hex.EncodeToString(sum[:]) || file.Size != int64(len(content)) {
+					t.Errorf("ReadSidecar().Files[%q] = %+v, does not match the file", role, file)
+				}
+			}
+
+			if tt.args.Settings != nil {
+				if sidecar.Config == nil || sidecar.Config.FuriganaApiAppId != "" || !sidecar.Config.KeepOriginal {
+					t.Errorf("ReadSidecar().Config = %+v, want redacted snapshot", sidecar.Config)
+				}
+
+				if tt.args.Settings.FuriganaApiAppId != "secret" {
+					t.Errorf("dumpSidecar() modified the config")
+				}
+			}
+		})
+	}
+}
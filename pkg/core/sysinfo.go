@@ -0,0 +1,200 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+)
+
+// DefaultSystemInfoTemplate is the default template of the system information overlay.
+const DefaultSystemInfoTemplate = `{{.Hostname}}{{with .Owner}} ({{.}}){{end}}
+{{with .Addresses}}IP: {{join . ", "}}
+{{end}}OS: {{.OSRelease}}
+Kernel: {{.Kernel}}
+{{with .Uptime}}Uptime: {{duration .}}
+{{end}}{{range .Disks}}Disk {{.Path}}: {{bytes .Used}} / {{bytes .Total}} ({{printf "%.0f" .Percent}}%)
+{{end}}`
+
+// root of the file system to read the system information from.
+var sysinfoRoot = "/"
+
+// SystemInfo represents information about the local machine.
+type SystemInfo struct {
+	Hostname  string
+	Owner     string
+	Addresses []string
+	OSRelease string
+	Kernel    string
+	Uptime    time.Duration
+	Disks     []DiskUsage
+}
+
+// DiskUsage represents the usage of the file system containing Path.
+type DiskUsage struct {
+	Path  string
+	Total uint64
+	Free  uint64
+}
+
+// Used returns the number of used bytes.
+func (d DiskUsage) Used() uint64 { return d.Total - d.Free }
+
+// Percent returns the used space in percent.
+func (d DiskUsage) Percent() float64 {
+	if d.Total == 0 {
+		return 0
+	}
+
+	return float64(d.Used()) / float64(d.Total) * 100
+}
+
+// GatherSystemInfo gathers information about the local machine.
+// It reads /proc and /etc/os-release and lists the addresses of the network interfaces.
+// Information which is not available on the current platform is left empty.
+func GatherSystemInfo(owner string, diskPaths ...string) SystemInfo {
+	info := SystemInfo{Owner: owner, OSRelease: runtime.GOOS, Kernel: runtime.GOOS}
+	info.Hostname, _ = os.Hostname()
+
+	if raw, err := os.ReadFile(filepath.Join(sysinfoRoot, "etc", "os-release")); err == nil {
+		release := parseOSRelease(raw)
+		info.OSRelease = types.Map[string, string](release).Get("PRETTY_NAME", release["NAME"]+" "+release["VERSION"])
+	}
+
+	if raw, err := os.ReadFile(filepath.Join(sysinfoRoot, "proc", "sys", "kernel", "osrelease")); err == nil {
+		info.Kernel = strings.TrimSpace(string(raw))
+	}
+
+	if raw, err := os.ReadFile(filepath.Join(sysinfoRoot, "proc", "uptime")); err == nil {
+		if fields := strings.Fields(string(raw)); len(fields) > 0 {
+			if seconds, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				info.Uptime = time.Duration(seconds * float64(time.Second)).Truncate(time.Minute)
+			}
+		}
+	}
+
+	if interfaces, err := net.Interfaces(); err == nil {
+		for _, iface := range interfaces {
+			if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+				continue
+			}
+
+			addresses, err := iface.Addrs()
+			if err != nil {
+				continue
+			}
+
+			for _, address := range addresses {
+				if ipNet, ok := address.(*net.IPNet); ok && ipNet.IP.IsGlobalUnicast() {
+					info.Addresses = append(info.Addresses, ipNet.IP.String())
+				}
+			}
+		}
+	}
+
+	for _, path := range diskPaths {
+		if path == "" {
+			continue
+		}
+
+		usage, err := diskUsage(path)
+		if err != nil || slices.ContainsFunc(info.Disks, func(d DiskUsage) bool { return d.Total == usage.Total && d.Free == usage.Free }) {
+			continue
+		}
+
+		info.Disks = append(info.Disks, usage)
+	}
+
+	return info
+}
+
+// Render renders the system information using the given template.
+func (info SystemInfo) Render(text string) (string, error) {
+	tmpl, err := template.New("sysinfo").Funcs(template.FuncMap{
+		"bytes":    formatBytes,
+		"duration": formatDuration,
+		"join":     strings.Join,
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid system info template: %w", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, info); err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(buffer.String(), "\n"), nil
+}
+
+// DrawSystemInfo draws the system information in a box at the given position.
+func (img *Image) DrawSystemInfo(info SystemInfo, text string, position types.Position, fontName string) error {
+	defer renderDuration.ObserveSince(time.Now(), "system_info")
+
+	rendered, err := info.Render(text)
+	if err != nil {
+		return err
+	}
+
+	return img.drawTextBox(rendered, position, fontName)
+}
+
+// formatBytes formats the number of bytes in a human readable format.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatDuration formats the duration in days, hours and minutes.
+func formatDuration(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	}
+
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// parseOSRelease parses the key-value pairs of the os-release file.
+func parseOSRelease(raw []byte) map[string]string {
+	release := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if key, value, ok := strings.Cut(line, "="); ok {
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+
+			release[key] = strings.Trim(value, `'"`)
+		}
+	}
+
+	return release
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/extras"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+)
+
+func setupSystemInfoRoot(t *testing.T) {
+	t.Helper()
+
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"etc/os-release":            "NAME=\"Test Linux\"\nVERSION=\"1.0\"\nPRETTY_NAME=\"Test Linux 1.0 (Lab)\"\n",
+		"proc/sys/kernel/osrelease": "6.1.0-test\n",
+		"proc/uptime":               "93784.12 1234.56\n",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(path)), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backup := sysinfoRoot
+	sysinfoRoot = root
+	t.Cleanup(func() { sysinfoRoot = backup })
+}
+
+func TestGatherSystemInfo(t *testing.T) {
+	setupSystemInfoRoot(t)
+
+	info := GatherSystemInfo("lab team", t.TempDir())
+	if info.OSRelease != "Test Linux 1.0 (Lab)" {
+		t.Errorf("GatherSystemInfo().OSRelease = %q, want %q", info.OSRelease, "Test Linux 1.0 (Lab)")
+	}
+
+	if info.Kernel != "6.1.0-test" {
+		t.Errorf("GatherSystemInfo().Kernel = %q, want %q", info.Kernel, "6.1.0-test")
+	}
+
+	if want := 26*time.Hour + 3*time.Minute; info.Uptime != want {
+		t.Errorf("GatherSystemInfo().Uptime = %s, want %s", info.Uptime, want)
+	}
+
+	if info.Owner != "lab team" || info.Hostname == "" {
+		t.Errorf("GatherSystemInfo() = %+v, want owner and hostname", info)
+	}
+
+	if len(info.Disks) != 1 || info.Disks[0].Total == 0 {
+		t.Errorf("GatherSystemInfo().Disks = %+v, want usage of one disk", info.Disks)
+	}
+}
+
+func TestSystemInfoRender(t *testing.T) {
+	info := SystemInfo{
+		Hostname:  "ci-monitor",
+		Owner:     "lab team",
+		Addresses: []string{"10.0.0.2", "fd00::2"},
+		OSRelease: "Test Linux 1.0",
+		Kernel:    "6.1.0-test",
+		Uptime:    26*time.Hour + 3*time.Minute,
+		Disks:     []DiskUsage{{Path: "/", Total: 4 << 30, Free: 1 << 30}},
+	}
+
+	for _, tt := range []struct {
+		name    string
+		args    string
+		want    string
+		wantErr bool
+	}{
+		{"test#1", DefaultSystemInfoTemplate, "ci-monitor (lab team)\nIP: 10.0.0.2, fd00::2\nOS: Test Linux 1.0\nKernel: 6.1.0-test\nUptime: 1d 2h 3m\nDisk /: 3.0 GiB / 4.0 GiB (75%)", false},
+		{"test#2", "{{.Hostname}}", "ci-monitor", false},
+		{"test#3", "{{.Hostname", "", true},
+		{"test#4", "sysinfo.go", "sysinfo.go", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := info.Render(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Render(%q) error = %v, wantErr %t", tt.args, err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrawSystemInfo(t *testing.T) {
+	img := SetupTestImage(t)
+	info := SystemInfo{Hostname: "ci-monitor", OSRelease: "Test Linux 1.0", Kernel: "6.1.0-test"}
+
+	for _, tt := range []struct {
+		name     string
+		position types.Position
+		wantErr  bool
+	}{
+		{"test#1", types.PositionBottomLeft, false},
+		{"test#2", types.PositionTopRight, false},
+		{"test#3", types.Position(-1), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SetupTestImage(t)
+
+			err := got.DrawSystemInfo(info, DefaultSystemInfoTemplate, tt.position, extras.DefaultFontName)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DrawSystemInfo(%q) error = %v, wantErr %t", tt.position, err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr != got.Equals(img) {
+				t.Errorf("DrawSystemInfo(%q) = %v, want %v", tt.position, got, img)
+			}
+		})
+	}
+}
+
+func TestDrawOverlays(t *testing.T) {
+	setupSystemInfoRoot(t)
+
+	img := SetupTestImage(t)
+	original := img.Image
+
+	cfg := &Config{DrawSystemInfo: true}
+	cfg.SystemInfoPosition.SetDefault(types.PositionTopLeft)
+	cfg.SystemInfoPosition.SetValues(types.AllowedPositions...)
+
+	for range 2 {
+		if err := img.DrawOverlays(cfg); err != nil {
+			t.Fatalf("DrawOverlays() error = %v", err)
+		}
+
+		if img.base != original {
+			t.Fatal("DrawOverlays() did not keep the image without overlays")
+		}
+	}
+
+	cfg.DrawSystemInfo = false
+	if err := img.DrawOverlays(cfg); err != nil {
+		t.Fatalf("DrawOverlays() error = %v", err)
+	}
+
+	if img.Image != original {
+		t.Error("DrawOverlays() without overlays did not restore the original image")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package core
+
+import "golang.org/x/sys/unix"
+
+// diskUsage returns the usage of the file system containing the given path.
+func diskUsage(path string) (DiskUsage, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return DiskUsage{}, err
+	}
+
+	return DiskUsage{
+		Path:  path,
+		Total: stat.Blocks * uint64(stat.Bsize),
+		Free:  stat.Bavail * uint64(stat.Bsize),
+	}, nil
+}
+
+// systemRoot returns the root of the system file system.
+func systemRoot() string { return "/" }
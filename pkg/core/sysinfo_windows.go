@@ -0,0 +1,27 @@
+//go:build windows
+
+package core
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// diskUsage returns the usage of the file system containing the given path.
+func diskUsage(path string) (DiskUsage, error) {
+	pointer, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(pointer, &free, &total, &totalFree); err != nil {
+		return DiskUsage{}, err
+	}
+
+	return DiskUsage{Path: path, Total: total, Free: free}, nil
+}
+
+// systemRoot returns the root of the system file system.
+func systemRoot() string { return os.Getenv("SystemDrive") + `\` }
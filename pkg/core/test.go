@@ -2,6 +2,7 @@ package core
 
 import (
 	"embed"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
@@ -11,8 +12,11 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
 	"github.com/tidwall/gjson"
 )
 
@@ -61,7 +65,7 @@ func FromMock(t testing.TB) bool {
 	return false
 }
 
-// MockServers sets up mock servers for the Bing and Hiragana API.
+// MockServers sets up mock servers for the Bing, Hiragana and Open-Meteo API.
 func MockServers(t testing.TB) {
 	t.Helper()
 
@@ -70,6 +74,7 @@ func MockServers(t testing.TB) {
 	serveMux.Handle("/th", getHandler(t, "bing.jpg"))
 	serveMux.Handle("/api/hiragana", getHandler(t, "hiragana.json"))
 	serveMux.Handle("/search/", getHandler(t, "jisho.html"))
+	serveMux.Handle("/v1/forecast", getHandler(t, "openmeteo.json"))
 	server := httptest.NewServer(serveMux)
 
 	backupCfg := crawlerConfig{
@@ -77,12 +82,14 @@ func MockServers(t testing.TB) {
 		furiganaApiUrl:   cfg.furiganaApiUrl,
 		furiganaApiAppId: cfg.furiganaApiAppId,
 		jishoOrgUrl:      cfg.jishoOrgUrl,
+		openMeteoUrl:     cfg.openMeteoUrl,
 	}
 
 	cfg.bingUrl = server.URL
 	cfg.furiganaApiUrl = server.URL
 	cfg.furiganaApiAppId = "test"
 	cfg.jishoOrgUrl = server.URL
+	cfg.openMeteoUrl = server.URL
 
 	t.Cleanup(func() {
 		server.Close()
@@ -91,6 +98,7 @@ func MockServers(t testing.TB) {
 		cfg.furiganaApiUrl = backupCfg.furiganaApiUrl
 		cfg.furiganaApiAppId = backupCfg.furiganaApiAppId
 		cfg.jishoOrgUrl = backupCfg.jishoOrgUrl
+		cfg.openMeteoUrl = backupCfg.openMeteoUrl
 	})
 }
 
@@ -138,14 +146,25 @@ func SetupTestImage(t testing.TB) *Image {
 	parsedRequestUri.Host = remoteHostUrl.Host
 	parsedRequestUri.Scheme = remoteHostUrl.Scheme
 
+	date, err := time.Parse("20060102", gjson.GetBytes(jsonRaw, "images.0.startdate").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	return &Image{
+		Title:     gjson.GetBytes(jsonRaw, "images.0.title").String(),
+		Copyright: gjson.GetBytes(jsonRaw, "images.0.copyright").String(),
 		Description: fmt.Sprintf(
 			"%s, %s",
 			gjson.GetBytes(jsonRaw, "images.0.title").String(),
 			gjson.GetBytes(jsonRaw, "images.0.copyright").String(),
 		),
+		Region:      types.RegionGermany,
+		ID:          strings.TrimPrefix(gjson.GetBytes(jsonRaw, "images.0.urlbase").String(), "/th?id="),
+		Date:        date,
 		DownloadURL: parsedRequestUri.String(),
 		SearchURL:   gjson.GetBytes(jsonRaw, "images.0.copyrightlink").String(),
+		Bing:        json.RawMessage(gjson.GetBytes(jsonRaw, "images.0").Raw),
 		Image:       img,
 	}
 }
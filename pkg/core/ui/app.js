@@ -0,0 +1,470 @@
+"use strict";
+
+// The dashboard of the Bing Wallpaper Changer, it is a client of the HTTP API (see /openapi.json).
+// The bearer token, if required, is kept in the local storage, it may be passed in the fragment of the URL (#token=...).
+
+const $ = (id) => document.getElementById(id);
+
+const state = {
+  config: null, // the config document
+  etag: "", // the entity tag of the config document
+  schema: null, // the config schema of the OpenAPI specification
+  job: "", // the ID of the tracked refresh job
+  urls: new Map(), // the object URLs of the loaded media by element
+};
+
+class ApiError extends Error {
+  constructor(status, message, fields) {
+    super(message);
+    this.status = status;
+    this.fields = fields || [];
+  }
+}
+
+// api calls the API with the bearer token, failed requests are thrown as ApiError.
+async function api(path, options = {}) {
+  const headers = new Headers(options.headers);
+  const token = localStorage.getItem("token");
+  if (token) {
+    headers.set("Authorization", "Bearer " + token);
+  }
+
+  const resp = await fetch(path, { ...options, headers });
+  if (resp.ok || resp.status === 304) {
+    return resp;
+  }
+
+  let body = {};
+  try {
+    body = await resp.json();
+  } catch {
+    // not a JSON error document
+  }
+
+  if (resp.status === 401) {
+    $("login").hidden = false;
+  }
+
+  throw new ApiError(resp.status, body.error || resp.statusText, body.fields);
+}
+
+// showMedia loads the media with the bearer token and shows it in the element.
+async function showMedia(element, path) {
+  const blob = await (await api(path)).blob();
+  const url = URL.createObjectURL(blob);
+  if (state.urls.has(element)) {
+    URL.revokeObjectURL(state.urls.get(element));
+  }
+
+  state.urls.set(element, url);
+  element.src = url;
+}
+
+function setStatus(element, message, failed) {
+  element.textContent = message;
+  element.classList.toggle("error", Boolean(failed));
+}
+
+// Wallpaper
+
+async function loadWallpaper() {
+  let wallpaper;
+  try {
+    wallpaper = await (await api("/wallpaper")).json();
+  } catch (err) {
+    $("title").textContent = err.status === 404 ? "No wallpaper has been set yet" : err.message;
+    return;
+  }
+
+  $("title").textContent = wallpaper.title || wallpaper.location;
+  $("copyright").textContent = wallpaper.copyright || "";
+  await showMedia($("preview"), wallpaper.image + "?id=" + encodeURIComponent(wallpaper.id || wallpaper.location));
+
+  $("audio").hidden = !wallpaper.audio;
+  if (wallpaper.audio) {
+    await showMedia($("audio"), wallpaper.audio + "?id=" + encodeURIComponent(wallpaper.id || wallpaper.location));
+  }
+
+  await loadDescription();
+}
+
+async function loadDescription() {
+  const lang = $("lang").value;
+  try {
+    const description = await (await api("/wallpaper/description" + (lang ? "?lang=" + encodeURIComponent(lang) : ""))).json();
+    $("description").textContent = description.description;
+    $("furigana").textContent = description.furigana || "";
+  } catch (err) {
+    $("description").textContent = err.message;
+    $("furigana").textContent = "";
+  }
+}
+
+// History
+
+async function loadHistory() {
+  const gallery = $("gallery");
+  let feed;
+  try {
+    feed = await (await api("/feed.json?limit=12")).json();
+  } catch (err) {
+    gallery.textContent = err.message;
+    return;
+  }
+
+  gallery.replaceChildren();
+  if (!feed.items.length) {
+    gallery.textContent = "No wallpapers have been archived yet";
+  }
+
+  for (const item of feed.items) {
+    const figure = document.createElement("figure");
+    const img = document.createElement("img");
+    const caption = document.createElement("figcaption");
+    img.alt = item.title;
+    caption.textContent = new Date(item.date_published).toLocaleDateString() + " " + item.title;
+    caption.title = item.content_text;
+    figure.append(img, caption);
+    figure.addEventListener("click", () => showMedia($("preview"), new URL(item.image).pathname));
+    gallery.append(figure);
+    showMedia(img, new URL(item.image).pathname).catch(() => {});
+  }
+}
+
+// Refresh jobs
+
+async function refresh() {
+  $("refresh").disabled = true;
+  try {
+    const job = await (await api("/refresh", { method: "POST" })).json();
+    await trackJob(job.id);
+  } catch (err) {
+    setStatus($("job"), err.message, true);
+  } finally {
+    $("refresh").disabled = false;
+  }
+}
+
+// trackJob polls the refresh job and shows the progress of its steps until it has finished.
+async function trackJob(id) {
+  if (state.job === id) {
+    return;
+  }
+
+  state.job = id;
+  while (state.job === id) {
+    const job = await (await api("/jobs/" + encodeURIComponent(id))).json();
+    setStatus($("job"), "Refresh " + job.state + (job.errors ? ": " + job.errors.join(", ") : ""), job.state === "failed");
+    $("steps").replaceChildren(...job.steps.map((step) => {
+      const li = document.createElement("li");
+      li.textContent = step.name + " " + (step.duration / 1e6).toFixed(0) + " ms" + (step.error ? " (" + step.error + ")" : "");
+      return li;
+    }));
+
+    if (job.state !== "running" && job.state !== "queued") {
+      state.job = "";
+      break;
+    }
+
+    await new Promise((resolve) => setTimeout(resolve, 500));
+  }
+}
+
+// Config
+
+// enumIndex returns the index of the value of the enum document in its values.
+function enumIndex(document) {
+  return (document.values || []).findIndex((value) => JSON.stringify(value) === JSON.stringify(document.value));
+}
+
+// fieldValue returns the value of the config field as sent in a merge patch, enums are sent by their name.
+function fieldValue(name, input) {
+  const schema = state.schema.properties[name];
+  if (schema.oneOf) {
+    return input.value;
+  }
+
+  switch (schema.type) {
+    case "boolean":
+      return input.checked;
+    case "integer":
+    case "number":
+      return Number(input.value);
+    case "array":
+      return input.value.trim() ? JSON.parse(input.value) : null;
+    default:
+      return input.value;
+  }
+}
+
+// originalValue returns the value of the config field as loaded in the representation of fieldValue.
+function originalValue(name) {
+  const schema = state.schema.properties[name];
+  const value = state.config[name];
+  if (schema.oneOf) {
+    return schema.oneOf[0].enum[enumIndex(value)];
+  }
+
+  return schema.type === "array" && !value ? null : value;
+}
+
+function createInput(name, schema, value) {
+  let input;
+  if (schema.oneOf) {
+    input = document.createElement("select");
+    for (const option of schema.oneOf[0].enum) {
+      input.add(new Option(option, option));
+    }
+    input.selectedIndex = enumIndex(value);
+    return input;
+  }
+
+  switch (schema.type) {
+    case "boolean":
+      input = document.createElement("input");
+      input.type = "checkbox";
+      input.checked = value;
+      break;
+
+    case "integer":
+    case "number":
+      input = document.createElement("input");
+      input.type = "number";
+      input.step = schema.type === "integer" ? "1" : "any";
+      if (schema.minimum !== undefined) {
+        input.min = schema.minimum;
+      }
+      if (schema.maximum !== undefined) {
+        input.max = schema.maximum;
+      }
+      input.value = value;
+      break;
+
+    case "array":
+      input = document.createElement("textarea");
+      input.value = value && value.length ? JSON.stringify(value, null, 2) : "";
+      input.placeholder = '[{"text": "...", "position": 4}]';
+      break;
+
+    default:
+      input = document.createElement("input");
+      input.type = "text";
+      if (schema.$ref === "#/components/schemas/Color") {
+        input.pattern = "#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})";
+      }
+      input.value = value ?? "";
+      break;
+  }
+
+  return input;
+}
+
+async function loadConfig() {
+  if (!state.schema) {
+    const spec = await (await api("/openapi.json")).json();
+    state.schema = spec.components.schemas.Config;
+  }
+
+  const resp = await api("/config");
+  state.etag = resp.headers.get("ETag") || "";
+  state.config = await resp.json();
+  renderConfig();
+}
+
+function renderConfig() {
+  const fields = $("fields");
+  fields.replaceChildren();
+
+  // the fields are listed in the order of the config document
+  for (const [name, value] of Object.entries(state.config)) {
+    const schema = state.schema.properties[name];
+    if (!schema) {
+      continue;
+    }
+
+    const field = document.createElement("div");
+    const label = document.createElement("label");
+    const input = createInput(name, schema, value);
+    field.className = "field";
+    field.dataset.name = name;
+    label.textContent = name;
+    label.htmlFor = input.id = "config-" + name;
+    input.name = name;
+    input.disabled = Boolean(schema.readOnly);
+    if (schema.description) {
+      label.title = schema.description;
+    }
+
+    input.addEventListener("input", () => field.classList.toggle("changed", isChanged(name, input)));
+    input.addEventListener("change", () => field.classList.toggle("changed", isChanged(name, input)));
+    field.append(label, input);
+    fields.append(field);
+  }
+}
+
+function isChanged(name, input) {
+  try {
+    return JSON.stringify(fieldValue(name, input)) !== JSON.stringify(originalValue(name));
+  } catch {
+    return true; // e.g. invalid JSON of the messages
+  }
+}
+
+function isDirty() {
+  return document.querySelector(".field.changed") !== null;
+}
+
+async function saveConfig(event) {
+  event.preventDefault();
+
+  const patch = {};
+  for (const field of document.querySelectorAll(".field")) {
+    const name = field.dataset.name;
+    const input = field.querySelector("input, select, textarea");
+    field.classList.remove("invalid");
+    field.querySelector(".error")?.remove();
+    if (input.disabled || !isChanged(name, input)) {
+      continue;
+    }
+
+    try {
+      patch[name] = fieldValue(name, input);
+    } catch (err) {
+      showFieldError(name, err.message);
+      return;
+    }
+  }
+
+  if (!Object.keys(patch).length) {
+    setStatus($("config-status"), "No changes");
+    return;
+  }
+
+  try {
+    const refreshAfterSave = $("refresh-after-save").checked;
+    const resp = await api("/config" + (refreshAfterSave ? "?refresh=true" : ""), {
+      method: "PATCH",
+      headers: { "Content-Type": "application/merge-patch+json", "If-Match": state.etag },
+      body: JSON.stringify(patch),
+    });
+
+    setStatus($("config-status"), "Saved " + Object.keys(patch).join(", "));
+    await loadConfig();
+
+    const location = resp.headers.get("Location");
+    if (location) {
+      await trackJob(location.replace("/jobs/", ""));
+    }
+  } catch (err) {
+    if (err.status === 412) {
+      setStatus($("config-status"), "The config has been modified meanwhile, it has been reloaded", true);
+      await loadConfig();
+      return;
+    }
+
+    setStatus($("config-status"), err.message, true);
+    for (const fieldError of err.fields) {
+      showFieldError(fieldError.field, fieldError.error);
+    }
+  }
+}
+
+function showFieldError(name, message) {
+  const field = document.querySelector(`.field[data-name="${CSS.escape(name)}"]`);
+  if (!field) {
+    return;
+  }
+
+  const error = document.createElement("span");
+  error.className = "error";
+  error.textContent = message;
+  field.classList.add("invalid");
+  field.append(error);
+}
+
+// Events
+
+// streamEvents follows the server-sent events, the stream is read with fetch to send the bearer token.
+async function streamEvents() {
+  for (;;) {
+    try {
+      const resp = await api("/events?types=wallpaper.changed,config.changed,refresh.started");
+      const reader = resp.body.pipeThrough(new TextDecoderStream()).getReader();
+      let buffer = "";
+      for (;;) {
+        const { value, done } = await reader.read();
+        if (done) {
+          break;
+        }
+
+        buffer += value;
+        let end;
+        while ((end = buffer.indexOf("\n\n")) >= 0) {
+          const block = buffer.slice(0, end);
+          buffer = buffer.slice(end + 2);
+          const data = block.split("\n").filter((line) => line.startsWith("data:")).map((line) => line.slice(5).trim()).join("");
+          if (data) {
+            handleEvent(JSON.parse(data));
+          }
+        }
+      }
+    } catch {
+      // reconnect below
+    }
+
+    await new Promise((resolve) => setTimeout(resolve, 5000));
+  }
+}
+
+function handleEvent(event) {
+  switch (event.type) {
+    case "wallpaper.changed":
+      loadWallpaper().catch(() => {});
+      loadHistory().catch(() => {});
+      break;
+
+    case "config.changed":
+      if (!isDirty()) {
+        loadConfig().catch((err) => setStatus($("config-status"), err.message, true));
+      }
+      break;
+
+    case "refresh.started":
+      trackJob(event.data.id).catch((err) => setStatus($("job"), err.message, true));
+      break;
+  }
+}
+
+// Setup
+
+function start() {
+  loadWallpaper().catch(() => {});
+  loadHistory().catch(() => {});
+  loadConfig().catch((err) => setStatus($("config-status"), err.message, true));
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+  const fragment = new URLSearchParams(location.hash.slice(1));
+  if (fragment.has("token")) {
+    localStorage.setItem("token", fragment.get("token"));
+    history.replaceState(null, "", location.pathname + location.search);
+  }
+
+  $("login").addEventListener("submit", (event) => {
+    event.preventDefault();
+    localStorage.setItem("token", $("token").value.trim());
+    $("login").hidden = true;
+    start();
+  });
+
+  $("refresh").addEventListener("click", refresh);
+  $("lang").addEventListener("change", loadDescription);
+  $("config").addEventListener("submit", saveConfig);
+  $("reset").addEventListener("click", () => {
+    renderConfig();
+    setStatus($("config-status"), "");
+  });
+
+  start();
+  streamEvents();
+});
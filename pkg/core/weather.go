@@ -0,0 +1,315 @@
+package core
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/extras"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	DefaultWeatherCacheTTL = 30 * time.Minute
+	MaxWeatherForecastDays = 7
+)
+
+// compassPoints are the names of the wind directions starting from north clockwise.
+var compassPoints = [8]string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// weatherCache caches the weather of the Open-Meteo provider across renders.
+var weatherCache = NewWeatherCache(&OpenMeteo{}, DefaultWeatherCacheTTL)
+
+type (
+	// WeatherProvider provides the current weather conditions and the daily forecast for a location.
+	WeatherProvider interface {
+		Weather(latitude, longitude float64, days int) (*Weather, error)
+	}
+
+	// Weather represents the current weather conditions and the daily forecast.
+	Weather struct {
+		Current         WeatherConditions `json:"current"`
+		Forecast        []WeatherForecast `json:"forecast"`
+		TemperatureUnit string            `json:"temperatureUnit"`
+		WindSpeedUnit   string            `json:"windSpeedUnit"`
+	}
+
+	// WeatherCode is a WMO weather interpretation code.
+	WeatherCode int
+
+	// WeatherConditions represents the current weather conditions.
+	WeatherConditions struct {
+		Time          time.Time   `json:"time"`
+		Code          WeatherCode `json:"code"`
+		Temperature   float64     `json:"temperature"`
+		WindSpeed     float64     `json:"windSpeed"`
+		WindDirection float64     `json:"windDirection"`
+	}
+
+	// WeatherForecast represents the forecast of a single day.
+	WeatherForecast struct {
+		Date           time.Time   `json:"date"`
+		Code           WeatherCode `json:"code"`
+		TemperatureMin float64     `json:"temperatureMin"`
+		TemperatureMax float64     `json:"temperatureMax"`
+		WindSpeedMax   float64     `json:"windSpeedMax"`
+	}
+
+	// OpenMeteo is a weather provider for the Open-Meteo forecast API (open-meteo.com).
+	// If the URL is empty, the configured Open-Meteo URL is used.
+	OpenMeteo struct {
+		URL string
+	}
+
+	// WeatherCache caches the weather of a provider for the TTL.
+	WeatherCache struct {
+		provider WeatherProvider
+		ttl      time.Duration
+		mutex    sync.Mutex
+		entries  map[string]weatherCacheEntry
+	}
+
+	weatherCacheEntry struct {
+		weather   *Weather
+		fetchedAt time.Time
+	}
+)
+
+// Icon returns the name of the embedded icon of the weather code.
+func (c WeatherCode) Icon() string {
+	switch {
+	case c == 0 || c == 1:
+		return "clear.png"
+
+	case c == 2:
+		return "partly-cloudy.png"
+
+	case c == 45 || c == 48:
+		return "fog.png"
+
+	case c >= 51 && c <= 57:
+		return "drizzle.png"
+
+	case (c >= 61 && c <= 67) || (c >= 80 && c <= 82):
+		return "rain.png"
+
+	case (c >= 71 && c <= 77) || c == 85 || c == 86:
+		return "snow.png"
+
+	case c >= 95:
+		return "thunderstorm.png"
+
+	default:
+		return "cloudy.png"
+
+	}
+}
+
+// String returns the description of the weather code.
+func (c WeatherCode) String() string {
+	s, ok := map[WeatherCode]string{
+		0:  "Clear sky",
+		1:  "Mainly clear",
+		2:  "Partly cloudy",
+		3:  "Overcast",
+		45: "Fog",
+		48: "Rime fog",
+		51: "Light drizzle",
+		53: "Drizzle",
+		55: "Dense drizzle",
+		56: "Freezing drizzle",
+		57: "Freezing drizzle",
+		61: "Light rain",
+		63: "Rain",
+		65: "Heavy rain",
+		66: "Freezing rain",
+		67: "Freezing rain",
+		71: "Light snow",
+		73: "Snow",
+		75: "Heavy snow",
+		77: "Snow grains",
+		80: "Rain showers",
+		81: "Rain showers",
+		82: "Violent showers",
+		85: "Snow showers",
+		86: "Snow showers",
+		95: "Thunderstorm",
+		96: "Thunderstorm, hail",
+		99: "Thunderstorm, hail",
+	}[c]
+	if !ok {
+		return "Unknown"
+	}
+	return s
+}
+
+// Weather fetches the current weather conditions and the forecast of the given number of days.
+func (p *OpenMeteo) Weather(latitude, longitude float64, days int) (*Weather, error) {
+	if days < 1 || days > MaxWeatherForecastDays {
+		return nil, fmt.Errorf("forecast days must be between 1 and %d, got %d", MaxWeatherForecastDays, days)
+	}
+
+	baseUrl := p.URL
+	if baseUrl == "" {
+		baseUrl = cfg.openMeteoUrl
+	}
+
+	jsonRaw, err := readResponse(client.Get(baseUrl + "/v1/forecast?" + url.Values{
+		"latitude":      {fmt.Sprintf("%.4f", latitude)},
+		"longitude":     {fmt.Sprintf("%.4f", longitude)},
+		"current":       {"temperature_2m,weather_code,wind_speed_10m,wind_direction_10m"},
+		"daily":         {"weather_code,temperature_2m_max,temperature_2m_min,wind_speed_10m_max"},
+		"forecast_days": {fmt.Sprintf("%d", days)},
+		"timezone":      {"auto"},
+	}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	if reason := gjson.GetBytes(jsonRaw, "reason"); gjson.GetBytes(jsonRaw, "error").Bool() {
+		return nil, fmt.Errorf("weather request failed: %s", reason.String())
+	}
+
+	location := time.FixedZone(gjson.GetBytes(jsonRaw, "timezone_abbreviation").String(), int(gjson.GetBytes(jsonRaw, "utc_offset_seconds").Int()))
+	current := gjson.GetBytes(jsonRaw, "current")
+	currentTime, err := time.ParseInLocation("2006-01-02T15:04", current.Get("time").String(), location)
+	if err != nil {
+		return nil, fmt.Errorf("malformed weather response: %w", err)
+	}
+
+	weather := &Weather{
+		Current: WeatherConditions{
+			Time:          currentTime,
+			Code:          WeatherCode(current.Get("weather_code").Int()),
+			Temperature:   current.Get("temperature_2m").Float(),
+			WindSpeed:     current.Get("wind_speed_10m").Float(),
+			WindDirection: current.Get("wind_direction_10m").Float(),
+		},
+		TemperatureUnit: gjson.GetBytes(jsonRaw, "current_units.temperature_2m").String(),
+		WindSpeedUnit:   gjson.GetBytes(jsonRaw, "current_units.wind_speed_10m").String(),
+	}
+
+	daily := gjson.GetBytes(jsonRaw, "daily")
+	for i, date := range daily.Get("time").Array() {
+		day, err := time.ParseInLocation(time.DateOnly, date.String(), location)
+		if err != nil {
+			return nil, fmt.Errorf("malformed weather response: %w", err)
+		}
+
+		weather.Forecast = append(weather.Forecast, WeatherForecast{
+			Date:           day,
+			Code:           WeatherCode(daily.Get(fmt.Sprintf("weather_code.%d", i)).Int()),
+			TemperatureMin: daily.Get(fmt.Sprintf("temperature_2m_min.%d", i)).Float(),
+			TemperatureMax: daily.Get(fmt.Sprintf("temperature_2m_max.%d", i)).Float(),
+			WindSpeedMax:   daily.Get(fmt.Sprintf("wind_speed_10m_max.%d", i)).Float(),
+		})
+	}
+
+	return weather, nil
+}
+
+// NewWeatherCache returns a weather provider caching the weather of the given provider for the TTL.
+func NewWeatherCache(provider WeatherProvider, ttl time.Duration) *WeatherCache {
+	return &WeatherCache{provider: provider, ttl: ttl, entries: make(map[string]weatherCacheEntry)}
+}
+
+// SetTTL sets the time to live of the cached weather.
+func (c *WeatherCache) SetTTL(ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.ttl = ttl
+}
+
+// Weather returns the cached weather of the location or fetches it from the provider if the cache expired.
+func (c *WeatherCache) Weather(latitude, longitude float64, days int) (*Weather, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := fmt.Sprintf("%.4f,%.4f,%d", latitude, longitude, days)
+	if entry, ok := c.entries[key]; ok && time.Since(entry.fetchedAt) < c.ttl {
+		return entry.weather, nil
+	}
+
+	weather, err := c.provider.Weather(latitude, longitude, days)
+	if err != nil {
+		return nil, err
+	}
+
+	c.entries[key] = weatherCacheEntry{weather: weather, fetchedAt: time.Now()}
+	return weather, nil
+}
+
+// DrawWeather draws the current weather conditions and the forecast with their icons in a box at the given position.
+// If the name of the location is provided, it is used as a title.
+func (img *Image) DrawWeather(weather *Weather, name string, position types.Position, fontName string) error {
+	defer renderDuration.ObserveSince(time.Now(), "weather")
+
+	text, icons, err := weather.layout(name)
+	if err != nil {
+		return err
+	}
+
+	return img.drawTextBox(text, position, fontName, icons...)
+}
+
+// layout returns the text of the weather box and the spans of the icons.
+func (w *Weather) layout(name string) (string, []textSpan, error) {
+	var lines []string
+	var icons []textSpan
+	if name != "" {
+		lines = append(lines, name)
+	}
+
+	row := func(label string, code WeatherCode, temperature, wind string) error {
+		icon, err := loadWeatherIcon(code)
+		if err != nil {
+			return err
+		}
+
+		icons = append(icons, textSpan{line: len(lines), column: 0, length: 2, icon: icon})
+		lines = append(lines, fmt.Sprintf("   %-5s %-9s %-16s %s", label, temperature, code, wind))
+		return nil
+	}
+
+	if err := row("Now", w.Current.Code,
+		fmt.Sprintf("%.0f%s", w.Current.Temperature, w.TemperatureUnit),
+		fmt.Sprintf("%.0f %s %s", w.Current.WindSpeed, w.WindSpeedUnit, compassPoint(w.Current.WindDirection)),
+	); err != nil {
+		return "", nil, err
+	}
+
+	for _, day := range w.Forecast {
+		if err := row(day.Date.Format("Mon"), day.Code,
+			fmt.Sprintf("%.0f/%.0f%s", day.TemperatureMin, day.TemperatureMax, w.TemperatureUnit),
+			fmt.Sprintf("%.0f %s", day.WindSpeedMax, w.WindSpeedUnit),
+		); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return strings.Join(lines, "\n"), icons, nil
+}
+
+// compassPoint returns the name of the wind direction given in degrees.
+func compassPoint(degrees float64) string {
+	index := int(math.Round(math.Mod(math.Mod(degrees, 360)+360, 360)/45)) % len(compassPoints)
+	return compassPoints[index]
+}
+
+// loadWeatherIcon decodes the embedded icon of the weather code.
+func loadWeatherIcon(code WeatherCode) (image.Image, error) {
+	r, ok := extras.EmbeddedWeatherIcons.Open(code.Icon())
+	if !ok {
+		return nil, fmt.Errorf("weather icon %q not found", code.Icon())
+	}
+	defer r.Close()
+
+	return png.Decode(r)
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/extras"
+	"github.com/sarumaj/bing-wallpaper-changer/pkg/types"
+)
+
+type countingWeatherProvider struct {
+	calls int
+	err   error
+}
+
+func (p *countingWeatherProvider) Weather(latitude, longitude float64, days int) (*Weather, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return &Weather{Current: WeatherConditions{Temperature: latitude + longitude}}, nil
+}
+
+func TestOpenMeteoWeather(t *testing.T) {
+	if FromMock(t) {
+		MockServers(t)
+	}
+
+	got, err := (&OpenMeteo{}).Weather(52.52, 13.41, 3)
+	if err != nil {
+		t.Fatalf("Weather() error = %v", err)
+	}
+
+	if len(got.Forecast) != 3 {
+		t.Fatalf("Weather().Forecast = %d days, want 3", len(got.Forecast))
+	}
+
+	if !FromMock(t) {
+		return
+	}
+
+	if got.Current.Code != 3 || got.Current.Temperature != -3.4 || got.Current.WindDirection != 268 || got.TemperatureUnit != "°C" {
+		t.Errorf("Weather().Current = %+v, want overcast at -3.4°C from the west", got.Current)
+	}
+
+	if want := time.Date(2024, 1, 9, 8, 0, 0, 0, time.FixedZone("CET", 3600)); !got.Current.Time.Equal(want) {
+		t.Errorf("Weather().Current.Time = %s, want %s", got.Current.Time, want)
+	}
+
+	if day := got.Forecast[1]; day.Code != 71 || day.TemperatureMin != -4.1 || day.TemperatureMax != 0.4 || day.WindSpeedMax != 22.3 {
+		t.Errorf("Weather().Forecast[1] = %+v, want light snow", day)
+	}
+
+	for _, days := range []int{0, MaxWeatherForecastDays + 1} {
+		if _, err := (&OpenMeteo{}).Weather(52.52, 13.41, days); err == nil {
+			t.Errorf("Weather(%d days) error = nil, want error", days)
+		}
+	}
+}
+
+func TestWeatherCache(t *testing.T) {
+	provider := &countingWeatherProvider{}
+	cache := NewWeatherCache(provider, time.Hour)
+
+	for range 3 {
+		if _, err := cache.Weather(1, 2, 3); err != nil {
+			t.Fatalf("Weather() error = %v", err)
+		}
+	}
+
+	if _, err := cache.Weather(2, 1, 3); err != nil {
+		t.Fatalf("Weather() error = %v", err)
+	}
+
+	if provider.calls != 2 {
+		t.Errorf("Weather() fetched %d times, want 2 (one per location)", provider.calls)
+	}
+
+	cache.SetTTL(0)
+	if _, err := cache.Weather(1, 2, 3); err != nil || provider.calls != 3 {
+		t.Errorf("Weather() error = %v, calls = %d, want refetch after expiry", err, provider.calls)
+	}
+
+	provider.err = fmt.Errorf("offline")
+	if _, err := cache.Weather(1, 2, 3); err == nil {
+		t.Error("Weather() error = nil, want error of the provider")
+	}
+}
+
+func TestWeatherLayout(t *testing.T) {
+	weather := &Weather{
+		Current:         WeatherConditions{Code: 3, Temperature: -3.4, WindSpeed: 12.2, WindDirection: 268},
+		Forecast:        []WeatherForecast{{Date: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), Code: 71, TemperatureMin: -4.1, TemperatureMax: 0.4, WindSpeedMax: 22.3}},
+		TemperatureUnit: "°C",
+		WindSpeedUnit:   "km/h",
+	}
+
+	text, icons, err := weather.layout("Berlin")
+	if err != nil {
+		t.Fatalf("layout() error = %v", err)
+	}
+
+	want := "Berlin\n   Now   -3°C      Overcast         12 km/h W\n   Wed   -4/0°C    Light snow       22 km/h"
+	if text != want {
+		t.Errorf("layout() = %q, want %q", text, want)
+	}
+
+	if len(icons) != 2 || icons[0].line != 1 || icons[1].line != 2 || icons[0].icon == nil {
+		t.Errorf("layout() icons = %+v, want one icon per row", icons)
+	}
+
+	for _, tt := range []struct {
+		name string
+		args float64
+		want string
+	}{
+		{"test#1", 0, "N"},
+		{"test#2", 44, "NE"},
+		{"test#3", 268, "W"},
+		{"test#4", 350, "N"},
+		{"test#5", -90, "W"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compassPoint(tt.args); got != tt.want {
+				t.Errorf("compassPoint(%v) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+
+	for code := range WeatherCode(100) {
+		if _, ok := extras.EmbeddedWeatherIcons[code.Icon()]; !ok {
+			t.Errorf("WeatherCode(%d).Icon() = %q, not embedded", code, code.Icon())
+		}
+	}
+}
+
+func TestDrawWeather(t *testing.T) {
+	img := SetupTestImage(t)
+	weather := &Weather{
+		Current:         WeatherConditions{Code: 95, Temperature: 21, WindSpeed: 30, WindDirection: 180},
+		Forecast:        []WeatherForecast{{Date: time.Now(), Code: 0, TemperatureMin: 14, TemperatureMax: 25, WindSpeedMax: 12}},
+		TemperatureUnit: "°C",
+		WindSpeedUnit:   "km/h",
+	}
+
+	for _, tt := range []struct {
+		name     string
+		position types.Position
+		wantErr  bool
+	}{
+		{"test#1", types.PositionTopLeft, false},
+		{"test#2", types.PositionBottomRight, false},
+		{"test#3", types.Position(-1), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SetupTestImage(t)
+
+			err := got.DrawWeather(weather, "Berlin", tt.position, extras.DefaultFontName)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DrawWeather(%q) error = %v, wantErr %t", tt.position, err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr != got.Equals(img) {
+				t.Errorf("DrawWeather(%q) = %v, want %v", tt.position, got, img)
+			}
+		})
+	}
+}
+
+func TestDrawOverlaysOffline(t *testing.T) {
+	server := httptest.NewServer(nil)
+	server.Close()
+
+	backupCache, backupUrl := weatherCache, cfg.openMeteoUrl
+	weatherCache, cfg.openMeteoUrl = NewWeatherCache(&OpenMeteo{}, time.Hour), server.URL
+	t.Cleanup(func() { weatherCache, cfg.openMeteoUrl = backupCache, backupUrl })
+
+	img := SetupTestImage(t)
+	original := img.Image
+
+	config := &Config{DrawWeather: true, WeatherForecastDays: 3, WeatherCacheTTL: time.Hour}
+	config.WeatherPosition.SetDefault(types.PositionTopLeft)
+	config.WeatherPosition.SetValues(types.AllowedPositions...)
+
+	if err := img.DrawOverlays(config); err != nil {
+		t.Fatalf("DrawOverlays() error = %v, want weather skipped", err)
+	}
+
+	if img.Image != original {
+		t.Error("DrawOverlays() drew the weather while offline")
+	}
+}
@@ -1,6 +1,6 @@
 /*
 Package extras contains extra functions that are not part of the main package.
-Currently, custom watermarks are implemented here.
+Currently, custom watermarks, fonts and weather icons are embedded here.
 */
 package extras
 
@@ -25,7 +25,8 @@ func (e Embedded) ToFiles(name string) (string, error) {
 		return "", err
 	}
 
-	for k, v := range e {
+	for k := range e {
+		v, _ := e.Open(k)
 		path := filepath.Join(dir, k)
 		f, err := os.Create(path)
 		if err != nil {
@@ -42,6 +43,16 @@ func (e Embedded) ToFiles(name string) (string, error) {
 	return dir, nil
 }
 
+// Open returns a reader of the embedded file with its own offset, so that the readers of a file do not interfere.
+func (e Embedded) Open(name string) (io.ReadCloser, bool) {
+	r, ok := e[name]
+	if shared, isShared := r.(*multiReadReader); isShared {
+		return &multiReadReader{data: shared.data}, true
+	}
+
+	return r, ok
+}
+
 // Keys returns the keys of the embedded map.
 func (e Embedded) Keys() []string {
 	names := make([]string, 0, len(e))
@@ -19,3 +19,30 @@ func Test_multiReadReader(t *testing.T) {
 		}
 	}
 }
+
+func TestEmbeddedOpen(t *testing.T) {
+	embedded := Embedded{"test.txt": &multiReadReader{data: []byte("test")}}
+
+	first, ok := embedded.Open("test.txt")
+	if !ok {
+		t.Fatalf("Open() = %v, want %v", ok, true)
+	}
+
+	partial := make([]byte, 2)
+	if _, err := first.Read(partial); err != nil {
+		t.Errorf("Read() error = %v, wantErr %v", err, false)
+	}
+
+	second, _ := embedded.Open("test.txt")
+	if got, _ := io.ReadAll(second); string(got) != "test" {
+		t.Errorf("ReadAll() of a second reader = %v, want %v", got, "test")
+	}
+
+	if got, _ := io.ReadAll(embedded["test.txt"]); string(got) != "test" {
+		t.Errorf("ReadAll() of the shared reader = %v, want %v", got, "test")
+	}
+
+	if _, ok := embedded.Open("unknown.txt"); ok {
+		t.Errorf("Open(unknown) = %v, want %v", ok, false)
+	}
+}
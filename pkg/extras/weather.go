@@ -0,0 +1,9 @@
+package extras
+
+import "embed"
+
+//go:embed weather/*.png.gz
+var weatherIcons embed.FS
+
+// EmbeddedWeatherIcons returns a map of weather icons named by the condition (e.g. rain.png).
+var EmbeddedWeatherIcons = getEmbedded(weatherIcons, "weather")
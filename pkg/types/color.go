@@ -0,0 +1,80 @@
+package types
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+var _ pflag.Value = (*Color)(nil)
+var _ color.Color = Color{}
+
+// Color is a hex encoded RGBA color (#rrggbb or #rrggbbaa).
+type Color color.RGBA
+
+// RGBA implements the color.Color interface.
+func (c Color) RGBA() (r, g, b, a uint32) {
+	return color.RGBA(c).RGBA()
+}
+
+// String returns the hex representation of the Color.
+// The alpha channel is omitted if the color is fully opaque.
+func (c Color) String() string {
+	if c.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// Set sets the Color from the given hex string.
+// Supported formats are #rgb, #rrggbb and #rrggbbaa, the leading hash is optional.
+func (c *Color) Set(value string) error {
+	hex := strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	if len(hex) != 8 {
+		return fmt.Errorf("invalid color: %q, expected format #rrggbb or #rrggbbaa", value)
+	}
+
+	parsed, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return fmt.Errorf("invalid color: %q: %w", value, err)
+	}
+
+	*c = Color{R: uint8(parsed >> 24), G: uint8(parsed >> 16), B: uint8(parsed >> 8), A: uint8(parsed)}
+	return nil
+}
+
+// Type returns the type of the Color.
+func (c Color) Type() string { return "color" }
+
+// Luminance returns the relative luminance of the Color (0.0 to 1.0).
+func (c Color) Luminance() float64 {
+	return (0.2126*float64(c.R) + 0.7152*float64(c.G) + 0.0722*float64(c.B)) / 255
+}
+
+// Blend returns the Color mixed with the other color by the given ratio (0.0 to 1.0).
+func (c Color) Blend(other Color, ratio float64) Color {
+	mix := func(a, b uint8) uint8 { return uint8(float64(a)*(1-ratio) + float64(b)*ratio + 0.5) }
+	return Color{R: mix(c.R, other.R), G: mix(c.G, other.G), B: mix(c.B, other.B), A: mix(c.A, other.A)}
+}
+
+// MarshalText marshals the Color to its hex representation.
+func (c Color) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText unmarshals the Color from its hex representation.
+func (c *Color) UnmarshalText(text []byte) error {
+	return c.Set(string(text))
+}
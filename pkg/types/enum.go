@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 
 	"github.com/spf13/pflag"
 )
@@ -63,14 +64,26 @@ func (e Enum[K, L]) Value() K { return e.value }
 // Values returns the enum values.
 func (e Enum[K, L]) Values() L { return e.values }
 
+// Names returns the names of the enum values in the order of the values, each of them is accepted by Set.
+func (e Enum[K, L]) Names() []string {
+	names := make([]string, 0, len(e.values))
+	for _, v := range e.values {
+		names = append(names, Enum[K, L]{value: v, values: e.values, alias: e.alias}.String())
+	}
+	return names
+}
+
 // MarshalJSON marshals the enum value to JSON.
+// The document lists the value, its name accepted by Set, the allowed values and the alias of the value.
 func (e Enum[K, L]) MarshalJSON() ([]byte, error) {
 	var aux = struct {
 		Value  K      `json:"value"`
+		Name   string `json:"name"`
 		Values L      `json:"values"`
 		Alias  string `json:"alias"`
 	}{
 		Value:  e.value,
+		Name:   e.String(),
 		Values: e.values,
 	}
 
@@ -82,7 +95,15 @@ func (e Enum[K, L]) MarshalJSON() ([]byte, error) {
 }
 
 // UnmarshalJSON unmarshals the enum value from JSON.
+// The value is either the string accepted by Set (e.g. "ja-JP") or the document produced by MarshalJSON.
+// If the allowed values are set, the value must be any of them and the allowed values and the alias are kept,
+// otherwise they are restored from the document.
 func (e *Enum[K, L]) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		return e.Set(name)
+	}
+
 	var aux struct {
 		Value  K      `json:"value"`
 		Values L      `json:"values"`
@@ -92,6 +113,15 @@ func (e *Enum[K, L]) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	if len(e.values) > 0 {
+		if !slices.Contains(e.values, aux.Value) {
+			return fmt.Errorf("unknown value: %v, allowed values are: %v", aux.Value, e.values)
+		}
+
+		e.value = aux.Value
+		return nil
+	}
+
 	e.value = aux.Value
 	e.values = aux.Values
 
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 
@@ -25,6 +26,20 @@ func (p *Percent) Set(value string) error {
 		return err
 	}
 
+	return p.set(f)
+}
+
+// UnmarshalJSON unmarshals the percent value from JSON applying the same rules as Set.
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	var f float64
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+
+	return p.set(f)
+}
+
+func (p *Percent) set(f float64) error {
 	if f < 0.0 || f > 100.0 {
 		return fmt.Errorf("percent value must be between 0.0 and 100.0")
 	}
@@ -2,6 +2,18 @@ package types
 
 import "strings"
 
+var AllowedPositions = Positions{
+	PositionTopLeft,
+	PositionTopCenter,
+	PositionTopRight,
+	PositionCenterLeft,
+	PositionCenter,
+	PositionCenterRight,
+	PositionBottomLeft,
+	PositionBottomCenter,
+	PositionBottomRight,
+}
+
 const (
 	PositionTopLeft Position = iota
 	PositionTopRight
@@ -40,6 +52,17 @@ func (p Position) String() string {
 // Positions is a slice of Position.
 type Positions []Position
 
+// Contains checks if the Positions contains the given Position.
+func (p Positions) Contains(position Position) bool {
+	for _, v := range p {
+		if v == position {
+			return true
+		}
+	}
+
+	return false
+}
+
 // String returns the string representation of the Positions.
 func (p Positions) String() string {
 	var s []string